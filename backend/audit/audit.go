@@ -0,0 +1,283 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultMaxSize    = 100 << 20
+	defaultMaxBackups = 10
+
+	// max size of single record when reading log
+	maxLineSize = 64 << 10
+)
+
+var (
+	ErrWrite      = errors.New("unable to write audit record")
+	ErrBrokenLink = errors.New("audit chain is broken")
+)
+
+// Record is audit log line. Hash is SHA-256 of record encoded with empty Hash,
+// PrevHash is hash of previous record, so removed or modified records are detected.
+// Chain continues across rotated files.
+type Record struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	model.AuditEvent
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// Log writes audit records to file, file is rotated when it exceeds max size,
+// rotated files get .1, .2, ... suffixes, files above max backups are removed.
+type Log struct {
+	logger zerolog.Logger
+	path   string
+
+	maxSize    int64
+	maxBackups int
+
+	mx       *sync.Mutex
+	f        *os.File
+	size     int64
+	seq      uint64
+	lastHash string
+}
+
+type Config struct {
+	Logger *zerolog.Logger
+	Path   string
+
+	// MaxSize is file size in bytes that triggers rotation.
+	MaxSize    int64
+	MaxBackups int
+}
+
+// NewLog opens audit log, chain is continued from last record of existing file.
+func NewLog(cfg Config) (*Log, error) {
+	l := &Log{
+		logger:     cfg.Logger.With().Str("component", "audit").Logger(),
+		path:       cfg.Path,
+		maxSize:    cfg.MaxSize,
+		maxBackups: cfg.MaxBackups,
+		mx:         &sync.Mutex{},
+	}
+	if l.maxSize == 0 {
+		l.maxSize = defaultMaxSize
+	}
+	if l.maxBackups == 0 {
+		l.maxBackups = defaultMaxBackups
+	}
+	last, err := lastRecord(l.path)
+	if err == nil && last == nil {
+		// log could be just rotated
+		last, err = lastRecord(backupPath(l.path, 1))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		l.seq, l.lastHash = last.Seq, last.Hash
+	}
+	if err = l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Audit writes event to audit log. Write errors are logged, since callers cannot handle them.
+func (l *Log) Audit(ev model.AuditEvent) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	rec := &Record{
+		Seq:        l.seq + 1,
+		Time:       time.Now().UTC(),
+		AuditEvent: ev,
+		PrevHash:   l.lastHash,
+	}
+	b, err := seal(rec)
+	if err != nil {
+		l.logger.Error().Err(errors.Join(ErrWrite, err)).Str("action", ev.Action).Msg("cannot encode audit record")
+		return
+	}
+	if l.size > 0 && l.size+int64(len(b)) > l.maxSize {
+		if err = l.rotate(); err != nil {
+			l.logger.Error().Err(err).Msg("cannot rotate audit log")
+		}
+	}
+	n, err := l.f.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		l.logger.Error().Err(errors.Join(ErrWrite, err)).Str("action", ev.Action).Msg("cannot write audit record")
+		return
+	}
+	l.seq, l.lastHash = rec.Seq, rec.Hash
+}
+
+// Close closes audit log file.
+func (l *Log) Close() error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.f.Close()
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	l.f, l.size = f, fi.Size()
+	return nil
+}
+
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(backupPath(l.path, l.maxBackups))
+	for i := l.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(backupPath(l.path, i), backupPath(l.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(l.path, backupPath(l.path, 1)); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// seal sets record hash and returns encoded record line.
+func seal(rec *Record) ([]byte, error) {
+	rec.Hash = ""
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	rec.Hash = hex.EncodeToString(sum[:])
+	b, err = json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func lastRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var (
+		last *Record
+		sc   = bufio.NewScanner(f)
+	)
+	sc.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var rec Record
+		if err = json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("cannot parse last audit record: %w", err)
+		}
+		last = &rec
+	}
+	return last, sc.Err()
+}
+
+// Verify checks hash chain of records read from r starting after record with prevHash
+// (empty for the first file of chain). It returns hash of last record and number of records.
+func Verify(r io.Reader, prevHash string) (string, int, error) {
+	var (
+		n  int
+		sc = bufio.NewScanner(r)
+	)
+	sc.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		n++
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return prevHash, n, fmt.Errorf("record %d: %w", n, err)
+		}
+		hash := rec.Hash
+		if rec.PrevHash != prevHash {
+			return prevHash, n, fmt.Errorf("%w: record %d (seq %d) does not follow previous record", ErrBrokenLink, n, rec.Seq)
+		}
+		if _, err := seal(&rec); err != nil {
+			return prevHash, n, fmt.Errorf("record %d: %w", n, err)
+		}
+		if rec.Hash != hash {
+			return prevHash, n, fmt.Errorf("%w: record %d (seq %d) is modified", ErrBrokenLink, n, rec.Seq)
+		}
+		prevHash = hash
+	}
+	return prevHash, n, sc.Err()
+}
+
+// VerifyFiles checks hash chain across files ordered from oldest to newest.
+// Oldest file could be already removed by rotation, so chain start is not checked.
+func VerifyFiles(paths ...string) (string, int, error) {
+	var (
+		hash  string
+		total int
+	)
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return hash, total, err
+		}
+		if i == 0 {
+			var first Record
+			if err = json.NewDecoder(f).Decode(&first); err != nil && !errors.Is(err, io.EOF) {
+				_ = f.Close()
+				return hash, total, fmt.Errorf("%s: %w", path, err)
+			}
+			hash = first.PrevHash
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				_ = f.Close()
+				return hash, total, err
+			}
+		}
+		var n int
+		hash, n, err = Verify(f, hash)
+		_ = f.Close()
+		total += n
+		if err != nil {
+			return hash, total, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return hash, total, nil
+}
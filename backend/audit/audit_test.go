@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestLog(t *testing.T, path string, maxSize int64) *Log {
+	t.Helper()
+	logger := zerolog.Nop()
+	l, err := NewLog(Config{Logger: &logger, Path: path, MaxSize: maxSize, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func writeRecords(t *testing.T, path string, n int) []string {
+	t.Helper()
+	l := newTestLog(t, path, 0)
+	for i := 0; i < n; i++ {
+		l.Audit(model.AuditEvent{Action: model.AuditActionModerate, Actor: "host", RoomID: "room"})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestVerify(t *testing.T) {
+	lines := writeRecords(t, filepath.Join(t.TempDir(), "audit.log"), 3)
+
+	tests := []struct {
+		name    string
+		lines   []string
+		wantN   int
+		wantErr error
+	}{
+		{"intact", lines, 3, nil},
+		{"empty", nil, 0, nil},
+		{"removed record", []string{lines[0], lines[2]}, 2, ErrBrokenLink},
+		{"reordered records", []string{lines[1], lines[0], lines[2]}, 1, ErrBrokenLink},
+		{"modified record", []string{lines[0], strings.Replace(lines[1], `"actor":"host"`, `"actor":"alice"`, 1), lines[2]}, 2, ErrBrokenLink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, n, err := Verify(strings.NewReader(strings.Join(tt.lines, "")), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if n != tt.wantN {
+				t.Errorf("Verify() = %d records, want %d", n, tt.wantN)
+			}
+		})
+	}
+}
+
+func TestChainContinues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeRecords(t, path, 2)
+	lines := writeRecords(t, path, 1)
+
+	if len(lines) != 3 {
+		t.Fatalf("log has %d records, want 3", len(lines))
+	}
+	if !strings.Contains(lines[2], `"seq":3`) {
+		t.Errorf("record after reopen = %s, want seq 3", lines[2])
+	}
+	if _, _, err := VerifyFiles(path); err != nil {
+		t.Errorf("VerifyFiles() error = %v", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// every record exceeds max size, so each write rotates log
+	l := newTestLog(t, path, 1)
+	for i := 0; i < 5; i++ {
+		l.Audit(model.AuditEvent{Action: model.AuditActionModerate, Actor: "host"})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backupPath(path, 3)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("backup above max backups is kept: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantN   int
+		wantErr error
+	}{
+		{"all files", []string{backupPath(path, 2), backupPath(path, 1), path}, 3, nil},
+		{"rotated file is missing", []string{backupPath(path, 2), path}, 2, ErrBrokenLink},
+		{"wrong order", []string{path, backupPath(path, 1)}, 2, ErrBrokenLink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, n, err := VerifyFiles(tt.paths...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyFiles() error = %v, want %v", err, tt.wantErr)
+			}
+			if n != tt.wantN {
+				t.Errorf("VerifyFiles() = %d records, want %d", n, tt.wantN)
+			}
+		})
+	}
+}
+
+func TestNewLogAfterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeRecords(t, path, 2)
+	if err := os.Rename(path, backupPath(path, 1)); err != nil {
+		t.Fatal(err)
+	}
+	writeRecords(t, path, 1)
+
+	_, n, err := VerifyFiles(backupPath(path, 1), path)
+	if err != nil {
+		t.Fatalf("VerifyFiles() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("VerifyFiles() = %d records, want 3", n)
+	}
+	b, _ := os.ReadFile(path)
+	if !bytes.Contains(b, []byte(`"seq":3`)) {
+		t.Errorf("record after rotation = %s, want seq 3", b)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+// Authentication modes.
+const (
+	ModeAnonymous = "anonymous"
+	ModeAPIKey    = "apikey"
+	ModeJWT       = "jwt"
+)
+
+// TokenQueryParam carries credential of clients that cannot set headers,
+// i.e. browser websocket and event source.
+const TokenQueryParam = "access_token"
+
+var (
+	ErrNoCredential      = errors.New("credential is not provided")
+	ErrInvalidCredential = errors.New("invalid credential")
+	ErrUserMismatch      = errors.New("user id does not match authenticated user")
+	ErrNoUserID          = errors.New("user id is not provided")
+)
+
+// Identity is authenticated participant. Anonymous identity has empty UserID,
+// participant id is taken from request then.
+type Identity struct {
+	UserID string
+
+	// ExpiresAt is when credential expires, zero if it does not.
+	// Signaling sessions are ended when their credential expires.
+	ExpiresAt time.Time
+}
+
+// Authenticator verifies request credentials.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// ResolveUserID returns participant id of request: verified id of authenticated identity
+// or requested id of anonymous one. Requested id may be omitted by authenticated clients,
+// otherwise it must match verified id.
+func ResolveUserID(id *Identity, requested string) (string, error) {
+	if id.UserID == "" {
+		if requested == "" {
+			return "", ErrNoUserID
+		}
+		return requested, nil
+	}
+	if requested != "" && requested != id.UserID {
+		return "", ErrUserMismatch
+	}
+	return id.UserID, nil
+}
+
+// Credential returns bearer token of request, token query param is used
+// if Authorization header is not set.
+func Credential(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get(TokenQueryParam)
+}
+
+// Anonymous trusts participant id provided by client.
+type Anonymous struct{}
+
+func (Anonymous) Authenticate(*http.Request) (*Identity, error) {
+	return &Identity{}, nil
+}
+
+// APIKeys authenticates participants with static keys, every key belongs to single participant.
+type APIKeys struct {
+	// keys are indexed by hash, so lookup does not depend on key contents
+	users map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys creates authenticator from key to participant id mapping.
+func NewAPIKeys(keys map[string]string) *APIKeys {
+	ak := &APIKeys{
+		users: make(map[[sha256.Size]byte]string, len(keys)),
+	}
+	for key, userID := range keys {
+		ak.users[sha256.Sum256([]byte(key))] = userID
+	}
+	return ak
+}
+
+func (ak *APIKeys) Authenticate(r *http.Request) (*Identity, error) {
+	key := Credential(r)
+	if key == "" {
+		return nil, ErrNoCredential
+	}
+	userID, ok := ak.users[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, ErrInvalidCredential
+	}
+	return &Identity{UserID: userID}, nil
+}
+
+// Auditor receives security-relevant events.
+type Auditor interface {
+	Audit(model.AuditEvent)
+}
+
+type audited struct {
+	Authenticator
+	auditor Auditor
+}
+
+// WithAudit returns authenticator that audits authentication failures.
+func WithAudit(authenticator Authenticator, auditor Auditor) Authenticator {
+	return &audited{
+		Authenticator: authenticator,
+		auditor:       auditor,
+	}
+}
+
+func (a *audited) Authenticate(r *http.Request) (*Identity, error) {
+	id, err := a.Authenticator.Authenticate(r)
+	if err != nil {
+		a.auditor.Audit(model.AuditEvent{
+			Action:     model.AuditActionAuth,
+			Outcome:    model.AuditFailure,
+			Detail:     r.Method + " " + r.URL.Path,
+			Reason:     err.Error(),
+			RemoteAddr: r.RemoteAddr,
+		})
+	}
+	return id, err
+}
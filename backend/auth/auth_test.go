@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeysAuthenticate(t *testing.T) {
+	ak := NewAPIKeys(map[string]string{"key-a": "alice", "key-b": "bob"})
+	tests := []struct {
+		name    string
+		header  string
+		query   string
+		want    string
+		wantErr error
+	}{
+		{"header", "Bearer key-a", "", "alice", nil},
+		{"query", "", "key-b", "bob", nil},
+		{"header wins", "Bearer key-a", "key-b", "alice", nil},
+		{"unknown key", "Bearer key-c", "", "", ErrInvalidCredential},
+		{"no key", "", "", "", ErrNoCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?"+TokenQueryParam+"="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			id, err := ak.Authenticate(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (id.UserID != tt.want || !id.ExpiresAt.IsZero()) {
+				t.Errorf("Authenticate() = %+v, want user %q without expiration", id, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveUserID(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        Identity
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{"anonymous", Identity{}, "alice", "alice", nil},
+		{"anonymous without id", Identity{}, "", "", ErrNoUserID},
+		{"authenticated", Identity{UserID: "alice"}, "", "alice", nil},
+		{"authenticated same id", Identity{UserID: "alice"}, "alice", "alice", nil},
+		{"authenticated other id", Identity{UserID: "alice"}, "bob", "", ErrUserMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveUserID(&tt.id, tt.requested)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("ResolveUserID() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
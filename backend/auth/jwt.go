@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	// hash functions of supported algorithms
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	defaultUserClaim = "sub"
+	defaultLeeway    = time.Minute
+)
+
+var (
+	ErrJWKS         = errors.New("unable to load jwks")
+	ErrTokenExpired = errors.New("token is expired")
+)
+
+var algorithms = map[string]struct {
+	kty  string
+	hash crypto.Hash
+}{
+	"HS256": {"oct", crypto.SHA256},
+	"HS384": {"oct", crypto.SHA384},
+	"HS512": {"oct", crypto.SHA512},
+	"RS256": {"RSA", crypto.SHA256},
+	"RS384": {"RSA", crypto.SHA384},
+	"RS512": {"RSA", crypto.SHA512},
+}
+
+// JWT authenticates participants with JSON Web Tokens signed with HMAC (HS*)
+// or RSA (RS*) keys from JWKS file. Participant id is taken from user claim.
+type JWT struct {
+	keys      []jwk
+	issuer    string
+	audience  string
+	userClaim string
+	leeway    time.Duration
+}
+
+type JWTConfig struct {
+	// JWKSFile is JSON Web Key Set with oct and RSA keys.
+	JWKSFile string
+
+	// Issuer and Audience are checked against iss and aud claims if set.
+	Issuer   string
+	Audience string
+
+	// UserClaim is claim with participant id, sub by default.
+	UserClaim string
+
+	// Leeway is allowed clock skew for exp and nbf claims.
+	Leeway time.Duration
+}
+
+type jwk struct {
+	kid    string
+	kty    string
+	alg    string
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// NewJWT creates authenticator with keys from JWKS file.
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	keys, err := loadJWKS(cfg.JWKSFile)
+	if err != nil {
+		return nil, errors.Join(ErrJWKS, err)
+	}
+	j := &JWT{
+		keys:      keys,
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		userClaim: cfg.UserClaim,
+		leeway:    cfg.Leeway,
+	}
+	if j.userClaim == "" {
+		j.userClaim = defaultUserClaim
+	}
+	if j.leeway == 0 {
+		j.leeway = defaultLeeway
+	}
+	return j, nil
+}
+
+func (j *JWT) Authenticate(r *http.Request) (*Identity, error) {
+	token := Credential(r)
+	if token == "" {
+		return nil, ErrNoCredential
+	}
+	claims, err := j.verify(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	userID, _ := claims[j.userClaim].(string)
+	if userID == "" {
+		return nil, fmt.Errorf("%w: %s claim is missing", ErrInvalidCredential, j.userClaim)
+	}
+	exp, _ := claims["exp"].(float64)
+	return &Identity{
+		UserID:    userID,
+		ExpiresAt: time.Unix(int64(exp), 0).Add(j.leeway),
+	}, nil
+}
+
+// verify checks token signature and registered claims and returns token claims.
+func (j *JWT) verify(token string, now time.Time) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredential
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidCredential
+	}
+	alg, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredential, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	if !j.verifySignature(header.Kid, header.Alg, alg.kty, alg.hash, signed, sig) {
+		return nil, fmt.Errorf("%w: signature is not valid", ErrInvalidCredential)
+	}
+
+	var claims map[string]any
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidCredential
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%w: exp claim is missing", ErrInvalidCredential)
+	}
+	if now.Add(-j.leeway).Unix() >= int64(exp) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(j.leeway).Unix() < int64(nbf) {
+		return nil, fmt.Errorf("%w: token is not valid yet", ErrInvalidCredential)
+	}
+	if j.issuer != "" && claims["iss"] != j.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredential)
+	}
+	if j.audience != "" && !hasAudience(claims["aud"], j.audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidCredential)
+	}
+	return claims, nil
+}
+
+// verifySignature tries keys with matching id, or every key if token has no key id.
+// Key type must match algorithm, so public RSA key cannot be used as HMAC secret.
+func (j *JWT) verifySignature(kid, algName, kty string, hash crypto.Hash, signed, sig []byte) bool {
+	for _, key := range j.keys {
+		if key.kty != kty || (kid != "" && key.kid != kid) || (key.alg != "" && key.alg != algName) {
+			continue
+		}
+		switch kty {
+		case "oct":
+			mac := hmac.New(hash.New, key.secret)
+			mac.Write(signed)
+			if hmac.Equal(sig, mac.Sum(nil)) {
+				return true
+			}
+		case "RSA":
+			h := hash.New()
+			h.Write(signed)
+			if rsa.VerifyPKCS1v15(key.public, hash, h.Sum(nil), sig) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAudience(aud any, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []any:
+		return slices.Contains(v, any(audience))
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func loadJWKS(path string) ([]jwk, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			K   string `json:"k"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err = json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	keys := make([]jwk, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key := jwk{kid: k.Kid, kty: k.Kty, alg: k.Alg}
+		switch k.Kty {
+		case "oct":
+			if key.secret, err = base64.RawURLEncoding.DecodeString(k.K); err != nil || len(key.secret) == 0 {
+				return nil, fmt.Errorf("key %d: invalid k", i)
+			}
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("key %d: invalid n or e", i)
+			}
+			key.public = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		default:
+			return nil, fmt.Errorf("key %d: unsupported key type %q", i, k.Kty)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func writeJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	b, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signToken creates token signed with HMAC secret or RSA private key.
+func signToken(t *testing.T, header, claims map[string]any, key any) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(h) + "." + b64(c)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(crypto.SHA256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sum := crypto.SHA256.New()
+		sum.Write([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum.Sum(nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + b64(sig)
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := writeJWKS(t,
+		map[string]string{"kid": "hs", "kty": "oct", "k": b64(testSecret)},
+		map[string]string{"kid": "rs", "kty": "RSA", "alg": "RS256",
+			"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+	)
+	j, err := NewJWT(JWTConfig{
+		JWKSFile: jwks,
+		Issuer:   "https://idp.example.com",
+		Audience: "webrtc",
+		Leeway:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	exp := now.Add(time.Hour).Unix()
+	valid := func() map[string]any {
+		return map[string]any{"sub": "alice", "exp": exp, "iss": "https://idp.example.com", "aud": "webrtc"}
+	}
+	with := func(k string, v any) map[string]any {
+		c := valid()
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+		return c
+	}
+	hs := map[string]any{"alg": "HS256", "kid": "hs"}
+	rs := map[string]any{"alg": "RS256", "kid": "rs"}
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"hs256", signToken(t, hs, valid(), testSecret), nil},
+		{"rs256", signToken(t, rs, valid(), rsaKey), nil},
+		{"no kid", signToken(t, map[string]any{"alg": "HS256"}, valid(), testSecret), nil},
+		{"audience list", signToken(t, hs, with("aud", []string{"other", "webrtc"}), testSecret), nil},
+		{"no token", "", ErrNoCredential},
+		{"malformed", "abc.def", ErrInvalidCredential},
+		{"wrong secret", signToken(t, hs, valid(), []byte("wrong")), ErrInvalidCredential},
+		{"wrong rsa key", signToken(t, rs, valid(), otherRSA), ErrInvalidCredential},
+		{"unknown kid", signToken(t, map[string]any{"alg": "HS256", "kid": "x"}, valid(), testSecret), ErrInvalidCredential},
+		{"alg none", signToken(t, map[string]any{"alg": "none"}, valid(), testSecret), ErrInvalidCredential},
+		{"rsa key as hmac secret", signToken(t, map[string]any{"alg": "HS256", "kid": "rs"}, valid(), rsaKey.N.Bytes()), ErrInvalidCredential},
+		{"expired", signToken(t, hs, with("exp", now.Add(-time.Minute).Unix()), testSecret), ErrTokenExpired},
+		{"no exp", signToken(t, hs, with("exp", nil), testSecret), ErrInvalidCredential},
+		{"not yet valid", signToken(t, hs, with("nbf", now.Add(time.Minute).Unix()), testSecret), ErrInvalidCredential},
+		{"wrong issuer", signToken(t, hs, with("iss", "https://evil.com"), testSecret), ErrInvalidCredential},
+		{"wrong audience", signToken(t, hs, with("aud", "other"), testSecret), ErrInvalidCredential},
+		{"no user claim", signToken(t, hs, with("sub", nil), testSecret), ErrInvalidCredential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			id, err := j.Authenticate(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if id.UserID != "alice" {
+				t.Errorf("Authenticate() user = %q, want alice", id.UserID)
+			}
+			if want := time.Unix(exp, 0).Add(time.Second); !id.ExpiresAt.Equal(want) {
+				t.Errorf("Authenticate() expires at %v, want %v", id.ExpiresAt, want)
+			}
+		})
+	}
+}
+
+func TestLoadJWKS(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []map[string]string
+		want    int
+		wantErr bool
+	}{
+		{"oct", []map[string]string{{"kty": "oct", "k": b64(testSecret)}}, 1, false},
+		{"encryption key skipped", []map[string]string{
+			{"kty": "oct", "k": b64(testSecret)},
+			{"kty": "oct", "use": "enc", "k": b64(testSecret)},
+		}, 1, false},
+		{"no signing keys", []map[string]string{{"kty": "oct", "use": "enc", "k": b64(testSecret)}}, 0, true},
+		{"empty secret", []map[string]string{{"kty": "oct"}}, 0, true},
+		{"invalid rsa", []map[string]string{{"kty": "RSA", "n": "!", "e": "AQAB"}}, 0, true},
+		{"unsupported type", []map[string]string{{"kty": "EC"}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := loadJWKS(writeJWKS(t, tt.keys...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadJWKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(keys) != tt.want {
+				t.Errorf("loadJWKS() got %d keys, want %d", len(keys), tt.want)
+			}
+		})
+	}
+}
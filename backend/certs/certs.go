@@ -0,0 +1,164 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultReloadInterval = 10 * time.Second
+)
+
+var (
+	ErrLoad     = errors.New("unable to load certificate")
+	ErrClientCA = errors.New("unable to load client ca")
+)
+
+type Config struct {
+	Logger   *zerolog.Logger
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables verification of client certificates
+	// which are required by RequireClientCert handlers.
+	ClientCAFile   string
+	ReloadInterval time.Duration
+}
+
+// Reloader keeps TLS certificate and reloads it when cert or key file is changed on disk.
+type Reloader struct {
+	logger   zerolog.Logger
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mx      *sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	clientCAs *x509.CertPool
+}
+
+func NewReloader(cfg Config) (*Reloader, error) {
+	r := &Reloader{
+		logger:   cfg.Logger.With().Str("component", "certs").Logger(),
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		interval: cfg.ReloadInterval,
+		mx:       &sync.RWMutex{},
+	}
+	if r.interval == 0 {
+		r.interval = defaultReloadInterval
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if cfg.ClientCAFile != "" {
+		b, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, errors.Join(ErrClientCA, err)
+		}
+		r.clientCAs = x509.NewCertPool()
+		if !r.clientCAs.AppendCertsFromPEM(b) {
+			return nil, errors.Join(ErrClientCA, errors.New("no certificates found"))
+		}
+	}
+	return r, nil
+}
+
+// TLSConfig returns server TLS config that uses reloaded certificate.
+// HTTP/2 is negotiated by http.Server automatically.
+func (r *Reloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+	if r.clientCAs != nil {
+		cfg.ClientCAs = r.clientCAs
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg
+}
+
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	return r.cert, nil
+}
+
+// Run periodically checks cert and key files and reloads certificate if they are changed.
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, err := r.lastModified()
+			if err != nil {
+				r.logger.Error().Err(err).Msg("unable to check certificate files")
+				continue
+			}
+			r.mx.RLock()
+			changed := modTime.After(r.modTime)
+			r.mx.RUnlock()
+			if !changed {
+				continue
+			}
+			if err = r.load(); err != nil {
+				r.logger.Error().Err(err).Msg("certificate reload failed, keeping previous one")
+				continue
+			}
+			r.logger.Info().Str("cert", r.certFile).Msg("certificate reloaded")
+		}
+	}
+}
+
+func (r *Reloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return errors.Join(ErrLoad, err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Join(ErrLoad, err)
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *Reloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return last, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+// RequireClientCert allows only requests with verified client certificate.
+func RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
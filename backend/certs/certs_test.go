@@ -0,0 +1,127 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// writeCert generates self-signed certificate to dir and sets modification time of its files.
+func writeCert(t *testing.T, dir string, modTime time.Time) (string, string) {
+	t.Helper()
+	cert, key, err := GenerateSelfSigned([]string{"localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for name, b := range map[string][]byte{certFile: cert, keyFile: key} {
+		if err = os.WriteFile(name, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestGenerateSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatalf("key pair is invalid: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if err = cert.VerifyHostname(host); err != nil {
+			t.Errorf("VerifyHostname(%s) error = %v", host, err)
+		}
+	}
+	if len(cert.IPAddresses) != 1 || !cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("IPAddresses = %v, want [127.0.0.1]", cert.IPAddresses)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, time.Now().Add(-time.Minute))
+	logger := zerolog.Nop()
+	r, err := NewReloader(Config{
+		Logger:         &logger,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ReloadInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := r.GetCertificate(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	// broken file is not loaded
+	if err = os.WriteFile(certFile, []byte("broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if cert, _ := r.GetCertificate(nil); cert != first {
+		t.Fatal("broken certificate replaced previous one")
+	}
+
+	writeCert(t, dir, time.Now())
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		cert, _ := r.GetCertificate(nil)
+		if !bytes.Equal(cert.Certificate[0], first.Certificate[0]) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("certificate is not reloaded")
+}
+
+func TestRequireClientCert(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    *tls.ConnectionState
+		wantCode int
+	}{
+		{"plain http", nil, http.StatusForbidden},
+		{"no client certificate", &tls.ConnectionState{}, http.StatusForbidden},
+		{"verified client certificate", &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{}}},
+		}, http.StatusOK},
+	}
+	h := RequireClientCert(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = tt.state
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
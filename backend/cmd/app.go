@@ -2,65 +2,283 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 
+	"github.com/adwski/webrtc-playground/backend/audit"
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/certs"
+	"github.com/adwski/webrtc-playground/backend/config"
+	"github.com/adwski/webrtc-playground/backend/events"
+	"github.com/adwski/webrtc-playground/backend/invite"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/recorder"
+	"github.com/adwski/webrtc-playground/backend/server/cors"
 	httpServer "github.com/adwski/webrtc-playground/backend/server/http"
+	"github.com/adwski/webrtc-playground/backend/server/ratelimit"
+	"github.com/adwski/webrtc-playground/backend/server/sse"
+	"github.com/adwski/webrtc-playground/backend/server/static"
 	websocketServer "github.com/adwski/webrtc-playground/backend/server/websocket"
 	"github.com/adwski/webrtc-playground/backend/service"
+	"github.com/adwski/webrtc-playground/backend/stats"
 	store "github.com/adwski/webrtc-playground/backend/storage/memory"
 	sw "github.com/adwski/webrtc-playground/backend/switch"
+	"github.com/adwski/webrtc-playground/backend/tracing"
+	"github.com/adwski/webrtc-playground/backend/webhook"
+	"github.com/adwski/webrtc-playground/peerchat"
 	"github.com/rs/zerolog"
 	"github.com/spf13/pflag"
 )
 
 func main() {
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
-	fs := pflag.NewFlagSet("main", pflag.ContinueOnError)
 
-	var (
-		apiListenAddr = fs.StringP("api-listen-addr", "a", ":8080", "api listen address")
-		wsListenAddr  = fs.StringP("ws-listen-addr", "w", ":8888", "websocket signaling listen address")
-		logLevel      = fs.StringP("log-level", "l", "debug", "log level")
-	)
-	if err := fs.Parse(os.Args[1:]); err != nil {
+	loader, err := config.NewLoader(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return
+		}
 		logger.Fatal().Err(err).Msg("failed to parse command line arguments")
 	}
+	cfg, err := loader.Load()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to load configuration")
+	}
+	if loader.PrintConfig() {
+		if err = cfg.Dump(os.Stdout); err != nil {
+			logger.Fatal().Err(err).Msg("failed to print configuration")
+		}
+		return
+	}
 
-	lvl, err := zerolog.ParseLevel(*logLevel)
+	lvl, err := zerolog.ParseLevel(cfg.Log.Level)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("failed to parse loglevel")
 	}
-	logger = logger.Level(lvl)
+	// global level is used, so it could be changed on reload
+	zerolog.SetGlobalLevel(lvl)
 
-	svc := service.NewService(service.Config{
-		RoomStore: store.NewMemStore(),
-		Switch:    sw.NewSwitch(&logger),
-		Logger:    &logger,
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var tlsCfg *tls.Config
+	if cfg.TLS.Enabled() {
+		reloader, errR := certs.NewReloader(certs.Config{
+			Logger:         &logger,
+			CertFile:       cfg.TLS.CertFile,
+			KeyFile:        cfg.TLS.KeyFile,
+			ClientCAFile:   cfg.TLS.ClientCAFile,
+			ReloadInterval: cfg.TLS.ReloadInterval,
+		})
+		if errR != nil {
+			logger.Fatal().Err(errR).Msg("failed to load tls certificate")
+		}
+		go reloader.Run(ctx)
+		tlsCfg = reloader.TLSConfig()
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
 	})
-	httpSrv := httpServer.NewServer(httpServer.Config{
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to setup tracing")
+	}
+	defer func() {
+		// pending spans are exported on exit
+		shCtx, shCancel := context.WithTimeout(context.Background(), cfg.API.ShutdownTimeout)
+		defer shCancel()
+		if errT := shutdownTracing(shCtx); errT != nil {
+			logger.Error().Err(errT).Msg("failed to shutdown tracing")
+		}
+	}()
+
+	corsCfg := corsConfig(cfg)
+	bus := events.NewBus()
+	invites, err := invite.NewIssuer(cfg.Invites.Secret)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create invite issuer")
+	}
+	var signalingRecorder sw.Recorder
+	if cfg.Switch.RecordDir != "" {
+		rec, errR := recorder.NewRecorder(recorder.Config{
+			Logger: &logger,
+			Dir:    cfg.Switch.RecordDir,
+		})
+		if errR != nil {
+			logger.Fatal().Err(errR).Msg("failed to create signaling recorder")
+		}
+		defer rec.Close()
+		signalingRecorder = rec
+	}
+	var (
+		svcAuditor  service.Auditor
+		httpAuditor httpServer.Auditor
+		authAuditor auth.Auditor
+	)
+	if cfg.Audit.Path != "" {
+		auditLog, errA := audit.NewLog(audit.Config{
+			Logger:     &logger,
+			Path:       cfg.Audit.Path,
+			MaxSize:    int64(cfg.Audit.MaxSizeMB) << 20,
+			MaxBackups: cfg.Audit.MaxBackups,
+		})
+		if errA != nil {
+			logger.Fatal().Err(errA).Msg("failed to open audit log")
+		}
+		defer func() {
+			_ = auditLog.Close()
+		}()
+		svcAuditor, httpAuditor, authAuditor = auditLog, auditLog, auditLog
+	}
+	authenticator, err := newAuthenticator(&cfg.Auth)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create authenticator")
+	}
+	if authAuditor != nil {
+		authenticator = auth.WithAudit(authenticator, authAuditor)
+	}
+	hooks, err := webhook.NewDispatcher(webhook.Config{
 		Logger:      &logger,
-		RoomService: svc,
-		ListenAddr:  *apiListenAddr,
+		Endpoints:   webhookEndpoints(cfg),
+		QueueDir:    cfg.Webhooks.QueueDir,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Timeout:     cfg.Webhooks.Timeout,
+		MinBackoff:  cfg.Webhooks.MinBackoff,
+		MaxBackoff:  cfg.Webhooks.MaxBackoff,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to create webhook dispatcher")
+	}
+	hooksDone := make(chan struct{})
+	go func() {
+		hooks.Run(ctx)
+		close(hooksDone)
+	}()
+	statsStore := stats.NewStore(stats.Config{
+		Retention:  cfg.Stats.Retention,
+		MaxReports: cfg.Stats.MaxReports,
+	})
+	go statsStore.Run(ctx)
+	memStore := store.NewMemStore(store.Config{
+		MaxParticipants: cfg.Store.MaxParticipants,
+		MaxViewers:      cfg.Store.MaxViewers,
+		MaxChatHistory:  cfg.Store.MaxChatHistory,
+
+		MaxBroadcastViewers: cfg.Store.MaxBroadcastViewers,
+	})
+	svc := service.NewService(service.Config{
+		RoomStore: memStore,
+		Switch: sw.NewSwitch(sw.Config{
+			Logger:         &logger,
+			ForwardTimeout: cfg.Switch.ForwardTimeout,
+			Events:         bus,
+			Recorder:       signalingRecorder,
+		}),
+		Logger:     &logger,
+		ICEServers: iceServers(cfg),
+		Events:     events.Publishers{bus, hooks},
+
+		Invites:      invites,
+		InviteTTL:    cfg.Invites.DefaultTTL,
+		InviteMaxTTL: cfg.Invites.MaxTTL,
+
+		Stats: statsStore,
+		Audit: svcAuditor,
 	})
+	rateLimiter := ratelimit.New(rateLimits(&cfg.Signaling.RateLimit))
+	var signalingRoutes map[string]http.Handler
+	if cfg.Signaling.SSE.Enabled {
+		signalingRoutes = sse.NewHandler(sse.Config{
+			Logger:            &logger,
+			SignalingService:  svc,
+			Authenticator:     authenticator,
+			KeepaliveInterval: cfg.Signaling.SSE.KeepaliveInterval,
+			ReconnectTimeout:  cfg.Signaling.SSE.ReconnectTimeout,
+			PollTimeout:       cfg.Signaling.SSE.PollTimeout,
+			BufferSize:        cfg.Signaling.SSE.BufferSize,
+			MaxMessageSize:    cfg.Signaling.MaxMessageSize,
+			RateLimiter:       rateLimiter,
+		}).Routes()
+	}
 	wsSrv := websocketServer.NewServer(websocketServer.Config{
-		Logger:           &logger,
-		SignalingService: svc,
-		ListenAddr:       *wsListenAddr,
+		Logger:              &logger,
+		SignalingService:    svc,
+		Authenticator:       authenticator,
+		ListenAddr:          cfg.Signaling.ListenAddr,
+		CORS:                corsCfg,
+		TLS:                 tlsCfg,
+		RateLimiter:         rateLimiter,
+		ShutdownTimeout:     cfg.Signaling.ShutdownTimeout,
+		SessionCloseTimeout: cfg.Signaling.SessionCloseTimeout,
+		ReadBufferSize:      cfg.Signaling.ReadBufferSize,
+		WriteBufferSize:     cfg.Signaling.WriteBufferSize,
+		MaxMessageSize:      cfg.Signaling.MaxMessageSize,
+		HandshakeTimeout:    cfg.Signaling.HandshakeTimeout,
+		WriteTimeout:        cfg.Signaling.WriteTimeout,
+		CloseWriteTimeout:   cfg.Signaling.CloseWriteTimeout,
+		PingInterval:        cfg.Signaling.PingInterval,
+		PongWait:            cfg.Signaling.PongWait,
+		Routes:              signalingRoutes,
+	})
+	var routes map[string]http.Handler
+	if cfg.API.SinglePort {
+		routes = map[string]http.Handler{
+			"/signal/":         wsSrv.Handler,
+			"/peerchat/":       static.NewHandler(peerchat.Assets, "/peerchat/"),
+			"GET /favicon.ico": static.FileHandler(peerchat.Assets, "icons/favicon.ico"),
+		}
+	}
+	httpSrv := httpServer.NewServer(httpServer.Config{
+		Logger:          &logger,
+		RoomService:     svc,
+		Authenticator:   authenticator,
+		ListenAddr:      cfg.API.ListenAddr,
+		CORS:            corsCfg,
+		ShutdownTimeout: cfg.API.ShutdownTimeout,
+		TLS:             tlsCfg,
+		Routes:          routes,
+		AdminRoutes:     events.NewStreamHandler(bus, cfg.Signaling.SSE.KeepaliveInterval, &logger).Routes(),
+		AdminService:    svc,
+		AdminToken:      cfg.Admin.Token,
+		Auditor:         httpAuditor,
+		Webhooks:        hooks,
 	})
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+	apply := func(cfg *config.Config) {
+		level, _ := zerolog.ParseLevel(cfg.Log.Level) // already validated
+		zerolog.SetGlobalLevel(level)
+		httpSrv.SetCORS(corsConfig(cfg))
+		httpSrv.SetAdminToken(cfg.Admin.Token)
+		wsSrv.SetCORS(corsConfig(cfg))
+		rateLimiter.Update(rateLimits(&cfg.Signaling.RateLimit))
+		svc.SetICEServers(iceServers(cfg))
+		svc.SetInviteTTL(cfg.Invites.DefaultTTL, cfg.Invites.MaxTTL)
+		memStore.SetMaxParticipants(cfg.Store.MaxParticipants)
+		memStore.SetMaxViewers(cfg.Store.MaxViewers)
+		memStore.SetMaxBroadcastViewers(cfg.Store.MaxBroadcastViewers)
+		memStore.SetMaxChatHistory(cfg.Store.MaxChatHistory)
+		hooks.SetEndpoints(webhookEndpoints(cfg))
+	}
+	go reloadOnHangup(ctx, loader, cfg, apply, &logger)
 
 	var (
 		wg   = &sync.WaitGroup{}
 		errc = make(chan error, 2)
 	)
-	wg.Add(2)
+	wg.Add(1)
 	go httpSrv.Run(ctx, wg, errc)
-	go wsSrv.Run(ctx, wg, errc)
+	if !cfg.API.SinglePort {
+		wg.Add(1)
+		go wsSrv.Run(ctx, wg, errc)
+	}
 
 	select {
 	case err = <-errc:
@@ -68,6 +286,125 @@ func main() {
 	case <-ctx.Done():
 		logger.Warn().Msg("interrupted")
 	}
+	svc.Drain()
 	cancel()
 	wg.Wait()
+	<-hooksDone
+	if cfg.API.SinglePort {
+		shCtx, shCancel := context.WithTimeout(context.Background(), cfg.Signaling.ShutdownTimeout)
+		defer shCancel()
+		wsSrv.WaitSessions(shCtx)
+	}
+}
+
+// reloadOnHangup re-reads configuration on SIGHUP and applies runtime-changeable options.
+func reloadOnHangup(
+	ctx context.Context,
+	loader *config.Loader,
+	cfg *config.Config,
+	apply func(*config.Config),
+	logger *zerolog.Logger,
+) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		next, err := loader.Load()
+		if err != nil {
+			logger.Error().Err(err).Msg("configuration reload rejected")
+			continue
+		}
+		applied, changes := config.Reload(cfg, next)
+		for _, change := range changes {
+			if change.Reloadable {
+				logger.Info().
+					Str("option", change.Option).
+					Any("old", change.Old).
+					Any("new", change.New).
+					Msg("option changed")
+			} else {
+				logger.Warn().
+					Str("option", change.Option).
+					Any("old", change.Old).
+					Any("new", change.New).
+					Msg("option cannot be changed in runtime, restart is required")
+			}
+		}
+		apply(applied)
+		cfg = applied
+		logger.Info().Int("changes", len(changes)).Msg("configuration reloaded")
+	}
+}
+
+func newAuthenticator(cfg *config.Auth) (auth.Authenticator, error) {
+	switch cfg.Mode {
+	case auth.ModeAPIKey:
+		keys := make(map[string]string, len(cfg.APIKeys))
+		for _, key := range cfg.APIKeys {
+			keys[key.Key] = key.UserID
+		}
+		return auth.NewAPIKeys(keys), nil
+	case auth.ModeJWT:
+		return auth.NewJWT(auth.JWTConfig{
+			JWKSFile:  cfg.JWT.JWKSFile,
+			Issuer:    cfg.JWT.Issuer,
+			Audience:  cfg.JWT.Audience,
+			UserClaim: cfg.JWT.UserClaim,
+			Leeway:    cfg.JWT.Leeway,
+		})
+	}
+	return auth.Anonymous{}, nil
+}
+
+func corsConfig(cfg *config.Config) cors.Config {
+	return cors.Config{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+	}
+}
+
+func iceServers(cfg *config.Config) []model.ICEServer {
+	servers := make([]model.ICEServer, 0, len(cfg.ICEServers))
+	for _, srv := range cfg.ICEServers {
+		servers = append(servers, model.ICEServer{
+			URLs:       srv.URLs,
+			Username:   srv.Username,
+			Credential: srv.Credential,
+		})
+	}
+	return servers
+}
+
+func webhookEndpoints(cfg *config.Config) []webhook.Endpoint {
+	endpoints := make([]webhook.Endpoint, 0, len(cfg.Webhooks.Endpoints))
+	for _, ep := range cfg.Webhooks.Endpoints {
+		endpoints = append(endpoints, webhook.Endpoint{
+			URL:    ep.URL,
+			Secret: ep.Secret,
+			Events: ep.Events,
+		})
+	}
+	return endpoints
+}
+
+func rateLimits(cfg *config.RateLimit) ratelimit.Config {
+	return ratelimit.Config{
+		Action:             cfg.Action,
+		ConnMessagesPerSec: cfg.ConnMessagesPerSec,
+		ConnMessagesBurst:  cfg.ConnMessagesBurst,
+		ConnBytesPerSec:    cfg.ConnBytesPerSec,
+		ConnBytesBurst:     cfg.ConnBytesBurst,
+		RoomMessagesPerSec: cfg.RoomMessagesPerSec,
+		RoomMessagesBurst:  cfg.RoomMessagesBurst,
+		RoomBytesPerSec:    cfg.RoomBytesPerSec,
+		RoomBytesBurst:     cfg.RoomBytesBurst,
+		MaxConnsPerIP:      cfg.MaxConnsPerIP,
+		TrustedProxies:     cfg.TrustedProxies,
+	}
 }
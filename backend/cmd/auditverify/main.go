@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/adwski/webrtc-playground/backend/audit"
+	"github.com/rs/zerolog"
+)
+
+// auditverify checks hash chain of audit log files,
+// files are passed from oldest to newest, e.g. audit.log.2 audit.log.1 audit.log.
+func main() {
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	if len(os.Args) < 2 {
+		logger.Fatal().Msg("usage: auditverify FILE...")
+	}
+	hash, n, err := audit.VerifyFiles(os.Args[1:]...)
+	if err != nil {
+		logger.Fatal().Err(err).Int("verified", n).Msg("audit log verification failed")
+	}
+	logger.Info().Int("records", n).Str("last_hash", hash).Msg("audit log is intact")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/audit"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+// main is run in subprocess, since it exits on failure
+func TestMain(m *testing.M) {
+	if args := os.Getenv("AUDITVERIFY_ARGS"); args != "" {
+		os.Args = append([]string{"auditverify"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAuditVerify(t *testing.T) {
+	dir := t.TempDir()
+	intact := filepath.Join(dir, "intact.log")
+	writeLog(t, intact)
+
+	b, err := os.ReadFile(intact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := filepath.Join(dir, "modified.log")
+	if err = os.WriteFile(modified, []byte(strings.Replace(string(b), `"actor":"host"`, `"actor":"alice"`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     string
+		wantErr  bool
+		wantText string
+	}{
+		{"intact", intact, false, "audit log is intact"},
+		{"modified", modified, true, "audit chain is broken"},
+		{"missing file", filepath.Join(dir, "missing.log"), true, "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0])
+			cmd.Env = append(os.Environ(), "AUDITVERIFY_ARGS="+tt.args)
+			out, err := cmd.CombinedOutput()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("auditverify error = %v, want error %v, output: %s", err, tt.wantErr, out)
+			}
+			if !strings.Contains(string(out), tt.wantText) {
+				t.Errorf("auditverify output = %s, want %q", out, tt.wantText)
+			}
+		})
+	}
+}
+
+func writeLog(t *testing.T, path string) {
+	t.Helper()
+	logger := zerolog.Nop()
+	l, err := audit.NewLog(audit.Config{Logger: &logger, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		l.Audit(model.AuditEvent{Action: model.AuditActionModerate, Actor: "host", RoomID: "room"})
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/certs"
+	"github.com/rs/zerolog"
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	fs := pflag.NewFlagSet("gencert", pflag.ContinueOnError)
+
+	var (
+		certFile = fs.String("cert", "cert.pem", "certificate output file")
+		keyFile  = fs.String("key", "key.pem", "private key output file")
+		hosts    = fs.StringSlice("hosts", []string{"localhost", "127.0.0.1", "::1"}, "certificate DNS names and IP addresses")
+		validFor = fs.Duration("valid-for", 365*24*time.Hour, "certificate validity period")
+	)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return
+		}
+		logger.Fatal().Err(err).Msg("failed to parse command line arguments")
+	}
+
+	certPEM, keyPEM, err := certs.GenerateSelfSigned(*hosts, *validFor)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to generate certificate")
+	}
+	if err = os.WriteFile(*certFile, certPEM, 0o644); err != nil {
+		logger.Fatal().Err(err).Msg("failed to write certificate")
+	}
+	if err = os.WriteFile(*keyFile, keyPEM, 0o600); err != nil {
+		logger.Fatal().Err(err).Msg("failed to write private key")
+	}
+	logger.Info().
+		Str("cert", *certFile).
+		Str("key", *keyFile).
+		Strs("hosts", *hosts).
+		Msg("self-signed certificate generated")
+}
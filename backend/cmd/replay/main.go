@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/recorder"
+	sw "github.com/adwski/webrtc-playground/backend/switch"
+	"github.com/rs/zerolog"
+	"github.com/spf13/pflag"
+)
+
+// endpointBufferSize is capacity of fake endpoint TX, so forwarding never blocks
+// and received announcements are collected after each replayed record.
+const endpointBufferSize = 1024
+
+// Delivery is announcement received by fake endpoint, it is printed as JSON line.
+type Delivery struct {
+	Step         int                `json:"step"`
+	Time         time.Time          `json:"time"`
+	Room         string             `json:"room"`
+	Endpoint     string             `json:"endpoint"`
+	Announcement model.Announcement `json:"announcement"`
+}
+
+// replay feeds recording through fresh switch with fake endpoints. Records are applied
+// one by one and deliveries are collected in endpoint order after each of them,
+// so output is the same for the same recording.
+func main() {
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+	fs := pflag.NewFlagSet("replay", pflag.ContinueOnError)
+
+	var (
+		file  = fs.String("file", "", "recording file")
+		speed = fs.Float64("speed", 0, "replay speed relative to recording, 0 replays without delays")
+		level = fs.String("log-level", "info", "log level")
+	)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return
+		}
+		logger.Fatal().Err(err).Msg("failed to parse command line arguments")
+	}
+	lvl, err := zerolog.ParseLevel(*level)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to parse loglevel")
+	}
+	logger = logger.Level(lvl)
+	if *file == "" {
+		logger.Fatal().Msg("recording file is not set")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to open recording")
+	}
+	records, err := recorder.Read(f)
+	_ = f.Close()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to read recording")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		swLogger     = logger.Level(zerolog.WarnLevel)
+		replaySwitch = sw.NewSwitch(sw.Config{Logger: &swLogger})
+		endpoints    = make(map[string]map[string]model.Wire)
+		out          = json.NewEncoder(os.Stdout)
+
+		deliveries, dropped int
+	)
+	collect := func(step int, r *recorder.Record) {
+		ids := make([]string, 0, len(endpoints[r.Room]))
+		for id := range endpoints[r.Room] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			tx := endpoints[r.Room][id].TX
+			for len(tx) > 0 {
+				deliveries++
+				if errE := out.Encode(&Delivery{
+					Step:         step,
+					Time:         r.Time,
+					Room:         r.Room,
+					Endpoint:     id,
+					Announcement: <-tx,
+				}); errE != nil {
+					logger.Fatal().Err(errE).Msg("failed to write delivery")
+				}
+			}
+		}
+	}
+
+	for i := range records {
+		r := &records[i]
+		if *speed > 0 && i > 0 {
+			time.Sleep(time.Duration(float64(r.Time.Sub(records[i-1].Time)) / *speed))
+		}
+		switch r.Event {
+		case recorder.EventConnected:
+			wire := model.NewWire()
+			wire.TX = make(chan model.Announcement, endpointBufferSize)
+			if _, ok := endpoints[r.Room]; !ok {
+				endpoints[r.Room] = make(map[string]model.Wire)
+			}
+			endpoints[r.Room][r.SRC] = wire
+			_ = replaySwitch.Connect(ctx, r.Room, r.SRC, wire)
+		case recorder.EventDisconnected:
+			_ = replaySwitch.Disconnect(r.Room, r.SRC)
+			collect(i, r)
+			delete(endpoints[r.Room], r.SRC)
+		case recorder.EventAnnouncement:
+			ann := model.Announcement{
+				SRC:  r.SRC,
+				DST:  r.DST,
+				Type: r.Type,
+			}
+			if len(r.Payload) > 0 {
+				ann.Payload = r.Payload
+			}
+			if ann.DST == "" {
+				_ = replaySwitch.Broadcast(ctx, ann, r.Room)
+			} else if errS := replaySwitch.Send(ctx, ann, r.Room); errS != nil {
+				dropped++
+				logger.Warn().
+					Int("step", i).
+					Str("room", r.Room).
+					Str("src", r.SRC).
+					Str("dst", r.DST).
+					Str("type", r.Type).
+					Msg("announcement is not delivered, dst is not connected")
+			}
+			collect(i, r)
+		default:
+			logger.Warn().Int("step", i).Str("event", r.Event).Msg("unknown record event")
+		}
+	}
+	logger.Info().
+		Int("records", len(records)).
+		Int("deliveries", deliveries).
+		Int("dropped", dropped).
+		Msg("recording replayed")
+}
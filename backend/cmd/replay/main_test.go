@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// main is run in subprocess, since it exits on failure
+func TestMain(m *testing.M) {
+	if args := os.Getenv("REPLAY_ARGS"); args != "" {
+		os.Args = append([]string{"replay"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func replay(t *testing.T, args string) ([]string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "REPLAY_ARGS="+args)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+
+	var deliveries []string
+	sc := bufio.NewScanner(&stdout)
+	for sc.Scan() {
+		var d Delivery
+		if errD := json.Unmarshal(sc.Bytes(), &d); errD != nil {
+			t.Fatalf("invalid delivery %s: %v", sc.Text(), errD)
+		}
+		deliveries = append(deliveries, fmt.Sprintf("%d %s %s>%s", d.Step, d.Endpoint, d.Announcement.SRC, d.Announcement.Type))
+	}
+	return deliveries, err
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	recording := func(name string, lines ...string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	call := recording("call.jsonl",
+		`{"time":"2026-01-01T00:00:00Z","room":"room","event":"connected","src":"alice"}`,
+		`{"time":"2026-01-01T00:00:01Z","room":"room","event":"connected","src":"bob"}`,
+		`{"time":"2026-01-01T00:00:02Z","room":"room","event":"connected","src":"carol"}`,
+		`{"time":"2026-01-01T00:00:03Z","room":"room","event":"announcement","src":"alice","type":"state","payload":{"muted":true}}`,
+		`{"time":"2026-01-01T00:00:04Z","room":"room","event":"announcement","src":"alice","dst":"bob","type":"offer","payload":{"sdp":"v=0"}}`,
+		`{"time":"2026-01-01T00:00:05Z","room":"room","event":"announcement","src":"alice","dst":"dave","type":"offer"}`,
+		`{"time":"2026-01-01T00:00:06Z","room":"room","event":"disconnected","src":"bob"}`,
+		`{"time":"2026-01-01T00:00:07Z","room":"room","event":"announcement","src":"carol","type":"state"}`,
+	)
+
+	tests := []struct {
+		name    string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "call",
+			args: "--file " + call,
+			want: []string{
+				"3 bob alice>state",
+				"3 carol alice>state",
+				"4 bob alice>offer",
+				"7 alice carol>state",
+			},
+		},
+		{
+			name: "unknown event",
+			args: "--file " + recording("unknown.jsonl",
+				`{"room":"room","event":"connected","src":"alice"}`,
+				`{"room":"room","event":"paused"}`,
+			),
+		},
+		{
+			name:    "invalid recording",
+			args:    "--file " + recording("invalid.jsonl", "{"),
+			wantErr: true,
+		},
+		{
+			name:    "file is not set",
+			args:    "--log-level info",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries, err := replay(t, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("replay error = %v, want error %v", err, tt.wantErr)
+			}
+			if strings.Join(deliveries, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("deliveries =\n%s\nwant\n%s", strings.Join(deliveries, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
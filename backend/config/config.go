@@ -0,0 +1,478 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Config is the backend configuration.
+//
+// Every option can be set in config file, with environment variable and with command line flag.
+// Flag name is defined by flag tag, environment variable name is derived from it
+// by upper-casing, replacing dashes with underscores and adding EnvPrefix.
+// Options without flag tag can be set only in config file.
+//
+// Options marked with reload tag can be changed in runtime, see Reload.
+type Config struct {
+	Log        Log         `yaml:"log" toml:"log"`
+	API        API         `yaml:"api" toml:"api"`
+	Signaling  Signaling   `yaml:"signaling" toml:"signaling"`
+	CORS       CORS        `yaml:"cors" toml:"cors" reload:"true"`
+	TLS        TLS         `yaml:"tls" toml:"tls"`
+	Switch     Switch      `yaml:"switch" toml:"switch"`
+	Store      Store       `yaml:"store" toml:"store"`
+	Admin      Admin       `yaml:"admin" toml:"admin" reload:"true" secret:"true"`
+	Auth       Auth        `yaml:"auth" toml:"auth"`
+	Invites    Invites     `yaml:"invites" toml:"invites"`
+	Tracing    Tracing     `yaml:"tracing" toml:"tracing"`
+	Stats      Stats       `yaml:"stats" toml:"stats"`
+	Audit      Audit       `yaml:"audit" toml:"audit"`
+	Webhooks   Webhooks    `yaml:"webhooks" toml:"webhooks"`
+	ICEServers []ICEServer `yaml:"ice_servers" toml:"ice_servers" reload:"true" secret:"true"`
+}
+
+type Log struct {
+	Level string `yaml:"level" toml:"level" flag:"log-level" short:"l" usage:"log level" reload:"true"`
+}
+
+type API struct {
+	ListenAddr      string        `yaml:"listen_addr" toml:"listen_addr" flag:"api-listen-addr" short:"a" usage:"api listen address"`
+	SinglePort      bool          `yaml:"single_port" toml:"single_port" flag:"single-port" usage:"serve api, signaling and embedded peerchat on api listen address"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" flag:"api-shutdown-timeout" usage:"api server graceful shutdown timeout"`
+}
+
+type Signaling struct {
+	ListenAddr          string        `yaml:"listen_addr" toml:"listen_addr" flag:"ws-listen-addr" short:"w" usage:"websocket signaling listen address"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" flag:"ws-shutdown-timeout" usage:"signaling server graceful shutdown timeout"`
+	SessionCloseTimeout time.Duration `yaml:"session_close_timeout" toml:"session_close_timeout" flag:"ws-session-close-timeout" usage:"signaling session deletion timeout"`
+	ReadBufferSize      int           `yaml:"read_buffer_size" toml:"read_buffer_size" flag:"ws-read-buffer-size" usage:"websocket read buffer size"`
+	WriteBufferSize     int           `yaml:"write_buffer_size" toml:"write_buffer_size" flag:"ws-write-buffer-size" usage:"websocket write buffer size"`
+	MaxMessageSize      int64         `yaml:"max_message_size" toml:"max_message_size" flag:"ws-max-message-size" usage:"max inbound websocket message size"`
+	HandshakeTimeout    time.Duration `yaml:"handshake_timeout" toml:"handshake_timeout" flag:"ws-handshake-timeout" usage:"websocket handshake timeout"`
+	WriteTimeout        time.Duration `yaml:"write_timeout" toml:"write_timeout" flag:"ws-write-timeout" usage:"websocket message write timeout"`
+	CloseWriteTimeout   time.Duration `yaml:"close_write_timeout" toml:"close_write_timeout" flag:"ws-close-write-timeout" usage:"websocket close frame write timeout"`
+	PingInterval        time.Duration `yaml:"ping_interval" toml:"ping_interval" flag:"ws-ping-interval" usage:"websocket ping interval"`
+	PongWait            time.Duration `yaml:"pong_wait" toml:"pong_wait" flag:"ws-pong-wait" usage:"websocket pong wait, must be greater than ping interval"`
+	RateLimit           RateLimit     `yaml:"rate_limit" toml:"rate_limit" reload:"true"`
+	SSE                 SSE           `yaml:"sse" toml:"sse"`
+}
+
+// SSE is fallback signaling transport for clients that cannot use websocket.
+type SSE struct {
+	Enabled           bool          `yaml:"enabled" toml:"enabled" flag:"sse-enabled" usage:"enable server-sent events and long polling signaling transport"`
+	KeepaliveInterval time.Duration `yaml:"keepalive_interval" toml:"keepalive_interval" flag:"sse-keepalive-interval" usage:"interval of keepalive comments in event stream"`
+	ReconnectTimeout  time.Duration `yaml:"reconnect_timeout" toml:"reconnect_timeout" flag:"sse-reconnect-timeout" usage:"how long session is kept after client disconnects"`
+	PollTimeout       time.Duration `yaml:"poll_timeout" toml:"poll_timeout" flag:"sse-poll-timeout" usage:"long polling request timeout"`
+	BufferSize        int           `yaml:"buffer_size" toml:"buffer_size" flag:"sse-buffer-size" usage:"number of events kept for replay on reconnect"`
+}
+
+type RateLimit struct {
+	Action             string   `yaml:"action" toml:"action" flag:"ws-rate-limit-action" usage:"action on rate limit violation: drop, warn or disconnect"`
+	ConnMessagesPerSec float64  `yaml:"conn_msgs_per_sec" toml:"conn_msgs_per_sec" flag:"ws-conn-msgs-per-sec" usage:"per connection inbound messages rate limit, 0 disables"`
+	ConnMessagesBurst  int      `yaml:"conn_msgs_burst" toml:"conn_msgs_burst" flag:"ws-conn-msgs-burst" usage:"per connection inbound messages burst"`
+	ConnBytesPerSec    float64  `yaml:"conn_bytes_per_sec" toml:"conn_bytes_per_sec" flag:"ws-conn-bytes-per-sec" usage:"per connection inbound bytes rate limit, 0 disables"`
+	ConnBytesBurst     int      `yaml:"conn_bytes_burst" toml:"conn_bytes_burst" flag:"ws-conn-bytes-burst" usage:"per connection inbound bytes burst"`
+	RoomMessagesPerSec float64  `yaml:"room_msgs_per_sec" toml:"room_msgs_per_sec" flag:"ws-room-msgs-per-sec" usage:"per room inbound messages rate limit, 0 disables"`
+	RoomMessagesBurst  int      `yaml:"room_msgs_burst" toml:"room_msgs_burst" flag:"ws-room-msgs-burst" usage:"per room inbound messages burst"`
+	RoomBytesPerSec    float64  `yaml:"room_bytes_per_sec" toml:"room_bytes_per_sec" flag:"ws-room-bytes-per-sec" usage:"per room inbound bytes rate limit, 0 disables"`
+	RoomBytesBurst     int      `yaml:"room_bytes_burst" toml:"room_bytes_burst" flag:"ws-room-bytes-burst" usage:"per room inbound bytes burst"`
+	MaxConnsPerIP      int      `yaml:"max_conns_per_ip" toml:"max_conns_per_ip" flag:"ws-max-conns-per-ip" usage:"max concurrent websocket connections per ip, 0 disables"`
+	TrustedProxies     []string `yaml:"trusted_proxies" toml:"trusted_proxies" flag:"ws-trusted-proxies" usage:"addresses or CIDRs of reverse proxies, client ip is taken from their forwarding headers"`
+}
+
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" toml:"allowed_origins" flag:"allowed-origins" usage:"allowed cross-origin request origins, e.g. https://example.com or https://*.example.com"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" flag:"cors-allow-credentials" usage:"allow credentials in cross-origin requests"`
+}
+
+// TLS is enabled for both servers if cert and key files are set.
+type TLS struct {
+	CertFile       string        `yaml:"cert_file" toml:"cert_file" flag:"tls-cert-file" usage:"tls certificate file, enables https and wss"`
+	KeyFile        string        `yaml:"key_file" toml:"key_file" flag:"tls-key-file" usage:"tls private key file"`
+	ClientCAFile   string        `yaml:"client_ca_file" toml:"client_ca_file" flag:"tls-client-ca-file" usage:"ca file to verify client certificates, enables mtls for admin routes"`
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval" flag:"tls-reload-interval" usage:"interval of certificate files change checks"`
+}
+
+// Enabled reports whether TLS is configured.
+func (t *TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type Switch struct {
+	ForwardTimeout time.Duration `yaml:"forward_timeout" toml:"forward_timeout" flag:"switch-forward-timeout" usage:"announcement forwarding timeout"`
+	RecordDir      string        `yaml:"record_dir" toml:"record_dir" flag:"switch-record-dir" usage:"directory for signaling recordings, empty disables recording"`
+}
+
+type Store struct {
+	MaxParticipants int `yaml:"max_participants" toml:"max_participants" flag:"room-max-participants" usage:"max host and publishers in room" reload:"true"`
+	MaxViewers      int `yaml:"max_viewers" toml:"max_viewers" flag:"room-max-viewers" usage:"max viewers in room" reload:"true"`
+	MaxChatHistory  int `yaml:"max_chat_history" toml:"max_chat_history" flag:"room-max-chat-history" usage:"chat messages kept per room" reload:"true"`
+
+	MaxBroadcastViewers int `yaml:"max_broadcast_viewers" toml:"max_broadcast_viewers" flag:"room-max-broadcast-viewers" usage:"max viewers in broadcast room" reload:"true"`
+}
+
+// Admin is admin API configuration, admin API is disabled if token is empty.
+type Admin struct {
+	Token string `yaml:"token" toml:"token" flag:"admin-token" usage:"bearer token of admin api, empty disables admin api"`
+}
+
+// Auth is participant authentication configuration. In anonymous mode
+// participant ids provided by clients are trusted.
+type Auth struct {
+	Mode    string   `yaml:"mode" toml:"mode" flag:"auth-mode" usage:"participant authentication: anonymous, apikey or jwt"`
+	APIKeys []APIKey `yaml:"api_keys" toml:"api_keys" secret:"true"`
+	JWT     JWT      `yaml:"jwt" toml:"jwt"`
+}
+
+// APIKey is static key of single participant.
+type APIKey struct {
+	Key    string `yaml:"key" toml:"key"`
+	UserID string `yaml:"user_id" toml:"user_id"`
+}
+
+// JWT are JSON Web Token validation settings.
+type JWT struct {
+	JWKSFile  string        `yaml:"jwks_file" toml:"jwks_file" flag:"auth-jwks-file" usage:"jwks file with token signing keys"`
+	Issuer    string        `yaml:"issuer" toml:"issuer" flag:"auth-jwt-issuer" usage:"required token issuer, not checked if empty"`
+	Audience  string        `yaml:"audience" toml:"audience" flag:"auth-jwt-audience" usage:"required token audience, not checked if empty"`
+	UserClaim string        `yaml:"user_claim" toml:"user_claim" flag:"auth-jwt-user-claim" usage:"token claim with participant id"`
+	Leeway    time.Duration `yaml:"leeway" toml:"leeway" flag:"auth-jwt-leeway" usage:"allowed clock skew of token time claims"`
+}
+
+// Invites are signed room invite tokens settings.
+type Invites struct {
+	Secret     string        `yaml:"secret" toml:"secret" flag:"invite-secret" usage:"invite tokens signing secret, random if empty (invites do not survive restart)" secret:"true"`
+	DefaultTTL time.Duration `yaml:"default_ttl" toml:"default_ttl" flag:"invite-default-ttl" usage:"invite token ttl if not requested" reload:"true"`
+	MaxTTL     time.Duration `yaml:"max_ttl" toml:"max_ttl" flag:"invite-max-ttl" usage:"max requested invite token ttl" reload:"true"`
+}
+
+// Audit is audit log configuration, audit log is disabled if path is empty.
+type Audit struct {
+	Path       string `yaml:"path" toml:"path" flag:"audit-path" usage:"audit log file, empty disables audit log"`
+	MaxSizeMB  int    `yaml:"max_size_mb" toml:"max_size_mb" flag:"audit-max-size-mb" usage:"audit log size in megabytes that triggers rotation"`
+	MaxBackups int    `yaml:"max_backups" toml:"max_backups" flag:"audit-max-backups" usage:"number of kept rotated audit logs"`
+}
+
+// Webhooks are room lifecycle notifications, they are sent if endpoints are configured.
+type Webhooks struct {
+	Endpoints   []WebhookEndpoint `yaml:"endpoints" toml:"endpoints" reload:"true" secret:"true"`
+	QueueDir    string            `yaml:"queue_dir" toml:"queue_dir" flag:"webhook-queue-dir" usage:"directory of pending webhook deliveries, empty keeps them in memory"`
+	MaxAttempts int               `yaml:"max_attempts" toml:"max_attempts" flag:"webhook-max-attempts" usage:"max webhook delivery attempts"`
+	Timeout     time.Duration     `yaml:"timeout" toml:"timeout" flag:"webhook-timeout" usage:"webhook delivery attempt timeout"`
+	MinBackoff  time.Duration     `yaml:"min_backoff" toml:"min_backoff" flag:"webhook-min-backoff" usage:"delay before first webhook delivery retry, doubles with every retry"`
+	MaxBackoff  time.Duration     `yaml:"max_backoff" toml:"max_backoff" flag:"webhook-max-backoff" usage:"max delay between webhook delivery retries"`
+}
+
+// WebhookEndpoint receives events of listed types, if events are empty
+// room created, participant joined and left, room empty and room closed events are sent.
+type WebhookEndpoint struct {
+	URL    string   `yaml:"url" toml:"url"`
+	Secret string   `yaml:"secret" toml:"secret"`
+	Events []string `yaml:"events,omitempty" toml:"events"`
+}
+
+// Stats are client call quality reports settings.
+type Stats struct {
+	Retention  time.Duration `yaml:"retention" toml:"retention" flag:"stats-retention" usage:"how long call quality reports are kept"`
+	MaxReports int           `yaml:"max_reports" toml:"max_reports" flag:"stats-max-reports" usage:"max call quality reports kept per room"`
+}
+
+// Tracing is OpenTelemetry tracing configuration.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter" flag:"tracing-exporter" usage:"trace exporter: none, otlp or stdout"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" flag:"tracing-endpoint" usage:"otlp/http collector address, e.g. localhost:4318"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure" flag:"tracing-insecure" usage:"export traces to collector over plain http"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" flag:"tracing-sample-ratio" usage:"fraction of sampled traces"`
+}
+
+// ICEServer is STUN or TURN server that is advertised to clients.
+type ICEServer struct {
+	URLs       []string `yaml:"urls" toml:"urls"`
+	Username   string   `yaml:"username,omitempty" toml:"username"`
+	Credential string   `yaml:"credential,omitempty" toml:"credential"`
+}
+
+// Default returns configuration with default values.
+func Default() *Config {
+	return &Config{
+		Log: Log{
+			Level: "debug",
+		},
+		API: API{
+			ListenAddr:      ":8080",
+			ShutdownTimeout: 10 * time.Second,
+		},
+		Signaling: Signaling{
+			ListenAddr:          ":8888",
+			ShutdownTimeout:     10 * time.Second,
+			SessionCloseTimeout: 2 * time.Second,
+			ReadBufferSize:      10000,
+			WriteBufferSize:     10000,
+			MaxMessageSize:      9000,
+			HandshakeTimeout:    3 * time.Second,
+			WriteTimeout:        5 * time.Second,
+			CloseWriteTimeout:   2 * time.Second,
+			PingInterval:        5 * time.Second,
+			PongWait:            7 * time.Second,
+			RateLimit: RateLimit{
+				Action:             "drop",
+				ConnMessagesPerSec: 50,
+				ConnMessagesBurst:  100,
+				RoomMessagesPerSec: 200,
+				RoomMessagesBurst:  400,
+				MaxConnsPerIP:      20,
+			},
+			SSE: SSE{
+				Enabled:           true,
+				KeepaliveInterval: 15 * time.Second,
+				ReconnectTimeout:  15 * time.Second,
+				PollTimeout:       25 * time.Second,
+				BufferSize:        256,
+			},
+		},
+		TLS: TLS{
+			ReloadInterval: 10 * time.Second,
+		},
+		Switch: Switch{
+			ForwardTimeout: time.Second,
+		},
+		Store: Store{
+			MaxParticipants: 2,
+			MaxViewers:      100,
+			MaxChatHistory:  100,
+
+			MaxBroadcastViewers: 1000,
+		},
+		Auth: Auth{
+			Mode: "anonymous",
+			JWT: JWT{
+				UserClaim: "sub",
+				Leeway:    time.Minute,
+			},
+		},
+		Invites: Invites{
+			DefaultTTL: time.Hour,
+			MaxTTL:     7 * 24 * time.Hour,
+		},
+		Stats: Stats{
+			Retention:  time.Hour,
+			MaxReports: 1000,
+		},
+		Audit: Audit{
+			MaxSizeMB:  100,
+			MaxBackups: 10,
+		},
+		Webhooks: Webhooks{
+			MaxAttempts: 8,
+			Timeout:     10 * time.Second,
+			MinBackoff:  time.Second,
+			MaxBackoff:  5 * time.Minute,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		ICEServers: []ICEServer{
+			{URLs: []string{"stun:stun1.l.google.com:19302", "stun:stun2.l.google.com:19302"}},
+		},
+	}
+}
+
+// Validate checks configuration consistency and returns all found errors.
+func (cfg *Config) Validate() error {
+	var errs []error
+	if _, err := zerolog.ParseLevel(cfg.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if cfg.API.ListenAddr == "" {
+		errs = append(errs, errors.New("api.listen_addr: must not be empty"))
+	}
+	if cfg.Signaling.ListenAddr == "" {
+		errs = append(errs, errors.New("signaling.listen_addr: must not be empty"))
+	}
+	for _, opt := range []struct {
+		name string
+		val  int64
+	}{
+		{"api.shutdown_timeout", int64(cfg.API.ShutdownTimeout)},
+		{"signaling.shutdown_timeout", int64(cfg.Signaling.ShutdownTimeout)},
+		{"signaling.session_close_timeout", int64(cfg.Signaling.SessionCloseTimeout)},
+		{"signaling.read_buffer_size", int64(cfg.Signaling.ReadBufferSize)},
+		{"signaling.write_buffer_size", int64(cfg.Signaling.WriteBufferSize)},
+		{"signaling.max_message_size", cfg.Signaling.MaxMessageSize},
+		{"signaling.handshake_timeout", int64(cfg.Signaling.HandshakeTimeout)},
+		{"signaling.write_timeout", int64(cfg.Signaling.WriteTimeout)},
+		{"signaling.close_write_timeout", int64(cfg.Signaling.CloseWriteTimeout)},
+		{"signaling.ping_interval", int64(cfg.Signaling.PingInterval)},
+		{"signaling.pong_wait", int64(cfg.Signaling.PongWait)},
+		{"signaling.sse.keepalive_interval", int64(cfg.Signaling.SSE.KeepaliveInterval)},
+		{"signaling.sse.reconnect_timeout", int64(cfg.Signaling.SSE.ReconnectTimeout)},
+		{"signaling.sse.poll_timeout", int64(cfg.Signaling.SSE.PollTimeout)},
+		{"signaling.sse.buffer_size", int64(cfg.Signaling.SSE.BufferSize)},
+		{"switch.forward_timeout", int64(cfg.Switch.ForwardTimeout)},
+		{"invites.default_ttl", int64(cfg.Invites.DefaultTTL)},
+		{"invites.max_ttl", int64(cfg.Invites.MaxTTL)},
+		{"stats.retention", int64(cfg.Stats.Retention)},
+		{"stats.max_reports", int64(cfg.Stats.MaxReports)},
+		{"audit.max_size_mb", int64(cfg.Audit.MaxSizeMB)},
+		{"audit.max_backups", int64(cfg.Audit.MaxBackups)},
+		{"webhooks.max_attempts", int64(cfg.Webhooks.MaxAttempts)},
+		{"webhooks.timeout", int64(cfg.Webhooks.Timeout)},
+		{"webhooks.min_backoff", int64(cfg.Webhooks.MinBackoff)},
+		{"webhooks.max_backoff", int64(cfg.Webhooks.MaxBackoff)},
+	} {
+		if opt.val <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be positive", opt.name))
+		}
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if cfg.CORS.AllowCredentials && strings.TrimSpace(origin) == "*" {
+			errs = append(errs, errors.New("cors.allow_credentials: must not be used with \"*\" origin"))
+		}
+	}
+	if cfg.Signaling.PongWait <= cfg.Signaling.PingInterval {
+		errs = append(errs, errors.New("signaling.pong_wait: must be greater than ping_interval"))
+	}
+	errs = append(errs, cfg.Signaling.RateLimit.validate()...)
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls: both cert_file and key_file must be set"))
+	}
+	if cfg.TLS.ClientCAFile != "" && !cfg.TLS.Enabled() {
+		errs = append(errs, errors.New("tls.client_ca_file: requires cert_file and key_file"))
+	}
+	if cfg.TLS.ReloadInterval <= 0 {
+		errs = append(errs, errors.New("tls.reload_interval: must be positive"))
+	}
+	if cfg.Invites.DefaultTTL > cfg.Invites.MaxTTL {
+		errs = append(errs, errors.New("invites.default_ttl: must not be greater than max_ttl"))
+	}
+	if cfg.Invites.Secret != "" && len(cfg.Invites.Secret) < 32 {
+		errs = append(errs, errors.New("invites.secret: must be at least 32 bytes long"))
+	}
+	if cfg.Store.MaxParticipants < 2 {
+		errs = append(errs, errors.New("store.max_participants: must be at least 2"))
+	}
+	switch cfg.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unknown exporter %q", cfg.Tracing.Exporter))
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio: must be between 0 and 1"))
+	}
+	if cfg.Store.MaxViewers <= 0 {
+		errs = append(errs, errors.New("store.max_viewers: must be positive"))
+	}
+	if cfg.Store.MaxBroadcastViewers <= 0 {
+		errs = append(errs, errors.New("store.max_broadcast_viewers: must be positive"))
+	}
+	if cfg.Store.MaxChatHistory <= 0 {
+		errs = append(errs, errors.New("store.max_chat_history: must be positive"))
+	}
+	errs = append(errs, cfg.Auth.validate()...)
+	if cfg.Webhooks.MinBackoff > cfg.Webhooks.MaxBackoff {
+		errs = append(errs, errors.New("webhooks.min_backoff: must not be greater than max_backoff"))
+	}
+	urls := make(map[string]bool)
+	for i, ep := range cfg.Webhooks.Endpoints {
+		if u, err := url.Parse(ep.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].url: must be absolute http(s) url", i))
+		}
+		if urls[ep.URL] {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].url: duplicate url", i))
+		}
+		urls[ep.URL] = true
+		if ep.Secret == "" {
+			errs = append(errs, fmt.Errorf("webhooks.endpoints[%d].secret: must not be empty", i))
+		}
+	}
+	for i, srv := range cfg.ICEServers {
+		if len(srv.URLs) == 0 {
+			errs = append(errs, fmt.Errorf("ice_servers[%d].urls: must not be empty", i))
+		}
+		for _, u := range srv.URLs {
+			if !strings.HasPrefix(u, "stun:") && !strings.HasPrefix(u, "turn:") && !strings.HasPrefix(u, "turns:") {
+				errs = append(errs, fmt.Errorf("ice_servers[%d].urls: invalid url %q", i, u))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Auth) validate() []error {
+	var errs []error
+	switch a.Mode {
+	case "anonymous":
+	case "apikey":
+		if len(a.APIKeys) == 0 {
+			errs = append(errs, errors.New("auth.api_keys: must not be empty in apikey mode"))
+		}
+	case "jwt":
+		if a.JWT.JWKSFile == "" {
+			errs = append(errs, errors.New("auth.jwt.jwks_file: must be set in jwt mode"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.mode: unknown mode %q", a.Mode))
+	}
+	keys := make(map[string]bool)
+	for i, key := range a.APIKeys {
+		if len(key.Key) < 16 {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].key: must be at least 16 bytes long", i))
+		}
+		if key.UserID == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].user_id: must not be empty", i))
+		}
+		if keys[key.Key] {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d].key: duplicate key", i))
+		}
+		keys[key.Key] = true
+	}
+	if a.JWT.UserClaim == "" {
+		errs = append(errs, errors.New("auth.jwt.user_claim: must not be empty"))
+	}
+	if a.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("auth.jwt.leeway: must not be negative"))
+	}
+	return errs
+}
+
+func (rl *RateLimit) validate() []error {
+	var errs []error
+	switch rl.Action {
+	case "drop", "warn", "disconnect":
+	default:
+		errs = append(errs, fmt.Errorf("signaling.rate_limit.action: unknown action %q", rl.Action))
+	}
+	for _, opt := range []struct {
+		name string
+		val  float64
+	}{
+		{"conn_msgs_per_sec", rl.ConnMessagesPerSec},
+		{"conn_msgs_burst", float64(rl.ConnMessagesBurst)},
+		{"conn_bytes_per_sec", rl.ConnBytesPerSec},
+		{"conn_bytes_burst", float64(rl.ConnBytesBurst)},
+		{"room_msgs_per_sec", rl.RoomMessagesPerSec},
+		{"room_msgs_burst", float64(rl.RoomMessagesBurst)},
+		{"room_bytes_per_sec", rl.RoomBytesPerSec},
+		{"room_bytes_burst", float64(rl.RoomBytesBurst)},
+		{"max_conns_per_ip", float64(rl.MaxConnsPerIP)},
+	} {
+		if opt.val < 0 {
+			errs = append(errs, fmt.Errorf("signaling.rate_limit.%s: must not be negative", opt.name))
+		}
+	}
+	for _, proxy := range rl.TrustedProxies {
+		_, errP := netip.ParsePrefix(proxy)
+		_, errA := netip.ParseAddr(proxy)
+		if errP != nil && errA != nil {
+			errs = append(errs, fmt.Errorf("signaling.rate_limit.trusted_proxies: invalid address or CIDR %q", proxy))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{"any origin", []string{"*"}, false, false},
+		{"credentials with listed origins", []string{"https://example.com", "https://*.example.com"}, true, false},
+		{"credentials with any origin", []string{"https://example.com", " * "}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.CORS = CORS{AllowedOrigins: tt.origins, AllowCredentials: tt.credentials}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
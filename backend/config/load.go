@@ -0,0 +1,289 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to environment variable names.
+const EnvPrefix = "WEBRTCPG_"
+
+var (
+	ErrParse  = errors.New("unable to parse configuration")
+	ErrFile   = errors.New("unable to read config file")
+	ErrFormat = errors.New("unknown config file format")
+	ErrValid  = errors.New("invalid configuration")
+)
+
+// Loader builds configuration from defaults, config file,
+// environment variables and command line flags (later ones take precedence).
+// Flags are parsed once, so Load can be called again to re-read config file.
+type Loader struct {
+	fs     *pflag.FlagSet
+	values map[string]*flagValue
+	file   string
+	print  bool
+}
+
+// NewLoader defines flags for every config option and parses command line arguments.
+func NewLoader(args []string) (*Loader, error) {
+	l := &Loader{
+		fs:     pflag.NewFlagSet("main", pflag.ContinueOnError),
+		values: make(map[string]*flagValue),
+	}
+	l.fs.StringVarP(&l.file, "config", "c", os.Getenv(EnvPrefix+"CONFIG"), "config file path (yaml or toml)")
+	l.fs.BoolVar(&l.print, "print-config", false, "print effective configuration and exit")
+
+	walk(reflect.ValueOf(Default()).Elem(), func(field reflect.Value, sf reflect.StructField) {
+		name := sf.Tag.Get("flag")
+		fv := &flagValue{
+			typ: field.Type(),
+			def: formatValue(field),
+		}
+		l.values[name] = fv
+		f := l.fs.VarPF(fv, name, sf.Tag.Get("short"), sf.Tag.Get("usage"))
+		if field.Kind() == reflect.Bool {
+			f.NoOptDefVal = "true"
+		}
+	})
+
+	if err := l.fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// PrintConfig reports whether effective configuration should be printed.
+func (l *Loader) PrintConfig() bool {
+	return l.print
+}
+
+// File returns config file path, it is empty if no config file is used.
+func (l *Loader) File() string {
+	return l.file
+}
+
+// Load builds and validates configuration.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+	if l.file != "" {
+		if err := readFile(l.file, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	walk(reflect.ValueOf(cfg).Elem(), func(field reflect.Value, sf reflect.StructField) {
+		name := sf.Tag.Get("flag")
+		env := envName(name)
+		if s, ok := os.LookupEnv(env); ok {
+			if err := setValue(field, s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			}
+		}
+		if l.fs.Changed(name) {
+			if err := setValue(field, l.values[name].value()); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", name, err))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(ErrParse, errors.Join(errs...))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Join(ErrValid, err)
+	}
+	return cfg, nil
+}
+
+// Dump writes configuration in yaml format, values of secret options are hidden.
+func (cfg *Config) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(redact(reflect.ValueOf(*cfg), false).Interface()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// redact returns copy of value where non-empty strings of secret options are hidden.
+// Secret tag applies to nested structs and slices.
+func redact(v reflect.Value, secret bool) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			fieldSecret := secret || v.Type().Field(i).Tag.Get("secret") == "true"
+			out.Field(i).Set(redact(v.Field(i), fieldSecret))
+		}
+		return out
+	case reflect.Slice:
+		if !secret || v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redact(v.Index(i), secret))
+		}
+		return out
+	case reflect.String:
+		if secret && v.Len() > 0 {
+			return reflect.ValueOf(hidden).Convert(v.Type())
+		}
+	}
+	return v
+}
+
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Join(ErrFile, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return errors.Join(ErrFile, err)
+		}
+	case ".toml":
+		md, errT := toml.NewDecoder(f).Decode(cfg)
+		if errT != nil {
+			return errors.Join(ErrFile, errT)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return errors.Join(ErrFile, fmt.Errorf("unknown fields: %v", undecoded))
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrFormat, path)
+	}
+	return nil
+}
+
+func envName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// walk calls fn for every struct field that has flag tag.
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			walk(field, fn)
+			continue
+		}
+		if sf.Tag.Get("flag") != "" {
+			fn(field, sf)
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(field reflect.Value, s string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(s)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported option type %s", field.Type())
+	}
+	return nil
+}
+
+func formatValue(field reflect.Value) string {
+	switch {
+	case field.Type() == durationType:
+		return time.Duration(field.Int()).String()
+	case field.Kind() == reflect.Slice:
+		return strings.Join(field.Interface().([]string), ",")
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
+
+// flagValue keeps raw flag values, so they can be applied on top of file and env values.
+type flagValue struct {
+	typ reflect.Type
+	def string
+	raw []string
+}
+
+func (fv *flagValue) String() string {
+	return fv.def
+}
+
+func (fv *flagValue) Set(s string) error {
+	if err := setValue(reflect.New(fv.typ).Elem(), s); err != nil {
+		return err
+	}
+	fv.raw = append(fv.raw, s)
+	return nil
+}
+
+// value returns string to apply, repeated slice flags are merged.
+func (fv *flagValue) value() string {
+	if fv.typ.Kind() == reflect.Slice {
+		return strings.Join(fv.raw, ",")
+	}
+	return fv.raw[len(fv.raw)-1]
+}
+
+func (fv *flagValue) Type() string {
+	if fv.typ == durationType {
+		return "duration"
+	}
+	if fv.typ.Kind() == reflect.Slice {
+		return "strings"
+	}
+	return fv.typ.Kind().String()
+}
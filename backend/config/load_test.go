@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDumpHidesSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Token = "admin-token-value"
+	cfg.Invites.Secret = "invite-secret-value"
+	cfg.Auth.APIKeys = []APIKey{{Key: "api-key-value", UserID: "alice"}}
+	cfg.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://hooks.example.com", Secret: "webhook-secret-value"}}
+	cfg.ICEServers = []ICEServer{{
+		URLs:       []string{"turn:turn.example.com"},
+		Username:   "turn-user-value",
+		Credential: "turn-credential-value",
+	}}
+
+	var buf bytes.Buffer
+	if err := cfg.Dump(&buf); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	out := buf.String()
+
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"admin token", cfg.Admin.Token},
+		{"invite secret", cfg.Invites.Secret},
+		{"api key", cfg.Auth.APIKeys[0].Key},
+		{"webhook secret", cfg.Webhooks.Endpoints[0].Secret},
+		{"turn username", cfg.ICEServers[0].Username},
+		{"turn credential", cfg.ICEServers[0].Credential},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if strings.Contains(out, tt.secret) {
+				t.Errorf("dump contains %s %q", tt.name, tt.secret)
+			}
+		})
+	}
+	if !strings.Contains(out, hidden) {
+		t.Errorf("dump has no hidden values:\n%s", out)
+	}
+	if !strings.Contains(out, cfg.API.ListenAddr) {
+		t.Errorf("dump has no api listen address %q:\n%s", cfg.API.ListenAddr, out)
+	}
+}
+
+func TestDumpKeepsConfig(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Token = "admin-token-value"
+	cfg.Auth.APIKeys = []APIKey{{Key: "api-key-value", UserID: "alice"}}
+
+	if err := cfg.Dump(&bytes.Buffer{}); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if cfg.Admin.Token != "admin-token-value" || cfg.Auth.APIKeys[0].Key != "api-key-value" {
+		t.Errorf("Dump() modified configuration: %+v %+v", cfg.Admin, cfg.Auth.APIKeys)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// hidden replaces values of secret options.
+const hidden = "<hidden>"
+
+// Change is a difference in single option between two configurations.
+type Change struct {
+	Option     string
+	Old        any
+	New        any
+	Reloadable bool
+}
+
+// Reload compares current and new configuration and returns configuration
+// where only runtime-changeable options are taken from new one.
+// All found changes are returned, so caller could report ignored ones.
+func Reload(cur, next *Config) (*Config, []Change) {
+	applied := *cur
+	var changes []Change
+	diff(reflect.ValueOf(&applied).Elem(), reflect.ValueOf(next).Elem(), "", false, false, &changes)
+	return &applied, changes
+}
+
+func diff(cur, next reflect.Value, prefix string, reload, secret bool, changes *[]Change) {
+	t := cur.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fieldReload := reload || sf.Tag.Get("reload") == "true"
+		fieldSecret := secret || sf.Tag.Get("secret") == "true"
+
+		if sf.Type.Kind() == reflect.Struct {
+			diff(cur.Field(i), next.Field(i), name, fieldReload, fieldSecret, changes)
+			continue
+		}
+		if reflect.DeepEqual(cur.Field(i).Interface(), next.Field(i).Interface()) {
+			continue
+		}
+		change := Change{
+			Option:     name,
+			Old:        cur.Field(i).Interface(),
+			New:        next.Field(i).Interface(),
+			Reloadable: fieldReload,
+		}
+		if fieldSecret {
+			change.Old, change.New = hidden, hidden
+		}
+		*changes = append(*changes, change)
+		if fieldReload {
+			cur.Field(i).Set(next.Field(i))
+		}
+	}
+}
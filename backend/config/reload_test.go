@@ -0,0 +1,79 @@
+package config
+
+import "testing"
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name           string
+		change         func(cfg *Config)
+		wantOption     string
+		wantReloadable bool
+		wantHidden     bool
+	}{
+		{
+			name:           "reloadable option",
+			change:         func(cfg *Config) { cfg.Log.Level = "trace" },
+			wantOption:     "log.level",
+			wantReloadable: true,
+		},
+		{
+			name:           "reloadable section",
+			change:         func(cfg *Config) { cfg.CORS.AllowedOrigins = []string{"https://example.com"} },
+			wantOption:     "cors.allowed_origins",
+			wantReloadable: true,
+		},
+		{
+			name:       "static option",
+			change:     func(cfg *Config) { cfg.API.ListenAddr = ":9090" },
+			wantOption: "api.listen_addr",
+		},
+		{
+			name: "secret option",
+			change: func(cfg *Config) {
+				cfg.ICEServers = []ICEServer{{URLs: []string{"turn:turn.example.com"}, Credential: "pass"}}
+			},
+			wantOption:     "ice_servers",
+			wantReloadable: true,
+			wantHidden:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur, next := Default(), Default()
+			tt.change(next)
+
+			applied, changes := Reload(cur, next)
+			if len(changes) != 1 {
+				t.Fatalf("Reload() changes = %+v, want single change", changes)
+			}
+			change := changes[0]
+			if change.Option != tt.wantOption || change.Reloadable != tt.wantReloadable {
+				t.Errorf("Reload() change = %s reloadable %v, want %s reloadable %v",
+					change.Option, change.Reloadable, tt.wantOption, tt.wantReloadable)
+			}
+			if hidden := change.New == "<hidden>"; hidden != tt.wantHidden {
+				t.Errorf("new value = %v, want hidden %v", change.New, tt.wantHidden)
+			}
+
+			// applied configuration has only reloadable changes
+			want := cur
+			if tt.wantReloadable {
+				want = next
+			}
+			if _, left := Reload(applied, want); len(left) != 0 {
+				t.Errorf("applied configuration differs: %+v", left)
+			}
+		})
+	}
+}
+
+func TestReloadNoChanges(t *testing.T) {
+	cur := Default()
+	applied, changes := Reload(cur, Default())
+	if len(changes) != 0 {
+		t.Errorf("Reload() changes = %+v, want none", changes)
+	}
+	if applied == cur {
+		t.Error("Reload() returned current configuration, want copy")
+	}
+}
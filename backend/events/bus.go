@@ -0,0 +1,98 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+const (
+	defaultSubscriptionBuffer = 64
+)
+
+// Bus delivers room events to subscribers. Publishing never blocks,
+// events are dropped for subscribers that are not keeping up.
+type Bus struct {
+	mx   *sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription receives events of single room or of all rooms if room is empty.
+type Subscription struct {
+	bus     *Bus
+	roomID  string
+	ch      chan model.Event
+	mx      *sync.Mutex
+	dropped int
+}
+
+// Publisher receives events.
+type Publisher interface {
+	Publish(model.Event)
+}
+
+// Publishers passes events to every publisher.
+type Publishers []Publisher
+
+func (ps Publishers) Publish(ev model.Event) {
+	for _, p := range ps {
+		p.Publish(ev)
+	}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		mx:   &sync.RWMutex{},
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Publish(ev model.Event) {
+	b.mx.RLock()
+	defer b.mx.RUnlock()
+
+	for sub := range b.subs {
+		if sub.roomID != "" && sub.roomID != ev.RoomID {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			sub.mx.Lock()
+			sub.dropped++
+			sub.mx.Unlock()
+		}
+	}
+}
+
+func (b *Bus) Subscribe(roomID string) *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		roomID: roomID,
+		ch:     make(chan model.Event, defaultSubscriptionBuffer),
+		mx:     &sync.Mutex{},
+	}
+	b.mx.Lock()
+	b.subs[sub] = struct{}{}
+	b.mx.Unlock()
+	return sub
+}
+
+func (s *Subscription) Events() <-chan model.Event {
+	return s.ch
+}
+
+// Dropped returns and resets number of events dropped since last call.
+func (s *Subscription) Dropped() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
+
+func (s *Subscription) Close() {
+	s.bus.mx.Lock()
+	delete(s.bus.subs, s)
+	s.bus.mx.Unlock()
+}
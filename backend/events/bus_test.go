@@ -0,0 +1,91 @@
+package events
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+// received returns rooms of events buffered in subscription.
+func received(sub *Subscription) []string {
+	var rooms []string
+	for len(sub.Events()) > 0 {
+		rooms = append(rooms, (<-sub.Events()).RoomID)
+	}
+	return rooms
+}
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe("")
+	room := bus.Subscribe("room")
+	closed := bus.Subscribe("room")
+	closed.Close()
+
+	bus.Publish(model.NewEvent(model.EventTypeRoomCreated, "room", "alice", nil))
+	bus.Publish(model.NewEvent(model.EventTypeRoomCreated, "other", "bob", nil))
+
+	if rooms := received(all); strings.Join(rooms, ",") != "room,other" {
+		t.Errorf("subscription to all rooms received %v", rooms)
+	}
+	if rooms := received(room); strings.Join(rooms, ",") != "room" {
+		t.Errorf("subscription to room received %v", rooms)
+	}
+	if rooms := received(closed); len(rooms) != 0 {
+		t.Errorf("closed subscription received %v", rooms)
+	}
+}
+
+func TestBusDropsEvents(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe("")
+	for i := 0; i < defaultSubscriptionBuffer+3; i++ {
+		bus.Publish(model.NewEvent(model.EventTypeRoomCreated, "room", "", nil))
+	}
+	if n := sub.Dropped(); n != 3 {
+		t.Errorf("Dropped() = %d, want 3", n)
+	}
+	if n := sub.Dropped(); n != 0 {
+		t.Errorf("Dropped() after reset = %d, want 0", n)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	bus := NewBus()
+	logger := zerolog.Nop()
+	mux := http.NewServeMux()
+	for pattern, h := range NewStreamHandler(bus, time.Hour, &logger).Routes() {
+		mux.Handle(pattern, h)
+	}
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events/room/room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %s, want text/event-stream", ct)
+	}
+
+	// subscription is made before headers are sent
+	bus.Publish(model.NewEvent(model.EventTypeRoomCreated, "other", "bob", nil))
+	bus.Publish(model.NewEvent(model.EventTypeParticipantJoined, "room", "", nil))
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "event: " + model.EventTypeParticipantJoined + "\n"; line != want {
+		t.Errorf("first line = %q, want %q", line, want)
+	}
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultKeepaliveInterval = 15 * time.Second
+)
+
+// StreamHandler streams bus events to observers as Server-Sent Events.
+type StreamHandler struct {
+	logger    zerolog.Logger
+	bus       *Bus
+	keepalive time.Duration
+}
+
+func NewStreamHandler(bus *Bus, keepalive time.Duration, logger *zerolog.Logger) *StreamHandler {
+	h := &StreamHandler{
+		logger:    logger.With().Str("component", "events").Logger(),
+		bus:       bus,
+		keepalive: keepalive,
+	}
+	if h.keepalive == 0 {
+		h.keepalive = defaultKeepaliveInterval
+	}
+	return h
+}
+
+// Routes returns ServeMux patterns for server-wide and per-room streams.
+func (h *StreamHandler) Routes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /events":               http.HandlerFunc(h.stream),
+		"GET /events/room/{roomID}": http.HandlerFunc(h.stream),
+	}
+}
+
+func (h *StreamHandler) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	roomID := r.PathValue("roomID")
+	sub := h.bus.Subscribe(roomID)
+	defer sub.Close()
+
+	h.logger.Debug().Str("roomID", roomID).Msg("observer connected")
+	defer h.logger.Debug().Str("roomID", roomID).Msg("observer disconnected")
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(h.keepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case ev := <-sub.Events():
+			if n := sub.Dropped(); n > 0 {
+				if _, err := fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", n); err != nil {
+					return
+				}
+			}
+			b, err := json.Marshal(&ev)
+			if err != nil {
+				h.logger.Error().Err(err).Msg("failed to marshall event")
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,141 @@
+package invite
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	secretSize = 32
+	idSize     = 16
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid invite token")
+	ErrExpired      = errors.New("invite token is expired")
+	ErrWrongRoom    = errors.New("invite token is issued for another room")
+	ErrUsed         = errors.New("invite token is already used")
+)
+
+// Claims are signed invite token contents.
+type Claims struct {
+	ID        string `json:"jti"`
+	RoomID    string `json:"room"`
+	RoomNonce string `json:"nonce"`
+	ExpiresAt int64  `json:"exp"`
+	SingleUse bool   `json:"single_use,omitempty"`
+}
+
+// Issuer issues and redeems invite tokens. Token is base64 encoded claims
+// and their HMAC-SHA256 signature. Used single-use tokens are remembered until they expire.
+type Issuer struct {
+	secret []byte
+
+	mx   *sync.Mutex
+	used map[string]time.Time
+}
+
+// NewIssuer creates issuer with provided secret, if secret is empty
+// random one is generated, so tokens are valid only until restart.
+func NewIssuer(secret string) (*Issuer, error) {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, secretSize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{
+		secret: key,
+		mx:     &sync.Mutex{},
+		used:   make(map[string]time.Time),
+	}, nil
+}
+
+// Issue creates invite token for room and returns it with its expiration time.
+// Room nonce binds token to room instance, so it is not valid in room recreated with the same id.
+func (is *Issuer) Issue(roomID, roomNonce string, ttl time.Duration, singleUse bool) (string, time.Time, error) {
+	id := make([]byte, idSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	b, err := json.Marshal(&Claims{
+		ID:        hex.EncodeToString(id),
+		RoomID:    roomID,
+		RoomNonce: roomNonce,
+		ExpiresAt: expiresAt.Unix(),
+		SingleUse: singleUse,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(is.sign(payload)), expiresAt, nil
+}
+
+// Redeem checks invite token for room, single-use token cannot be redeemed again.
+func (is *Issuer) Redeem(token, roomID, roomNonce string) error {
+	claims, err := is.verify(token)
+	if err != nil {
+		return err
+	}
+	if claims.RoomID != roomID || claims.RoomNonce != roomNonce {
+		return ErrWrongRoom
+	}
+	if !claims.SingleUse {
+		return nil
+	}
+
+	is.mx.Lock()
+	defer is.mx.Unlock()
+
+	now := time.Now()
+	for id, exp := range is.used {
+		if now.After(exp) {
+			delete(is.used, id)
+		}
+	}
+	if _, ok := is.used[claims.ID]; ok {
+		return ErrUsed
+	}
+	is.used[claims.ID] = time.Unix(claims.ExpiresAt, 0)
+	return nil
+}
+
+func (is *Issuer) verify(token string) (*Claims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, is.sign(payload)) {
+		return nil, ErrInvalidToken
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err = json.Unmarshal(b, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+func (is *Issuer) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, is.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
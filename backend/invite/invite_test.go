@@ -0,0 +1,84 @@
+package invite
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssuerRedeem(t *testing.T) {
+	is, err := NewIssuer("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewIssuer("other secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := func(is *Issuer, roomID string, ttl time.Duration, singleUse bool) string {
+		token, _, errI := is.Issue(roomID, "nonce", ttl, singleUse)
+		if errI != nil {
+			t.Fatal(errI)
+		}
+		return token
+	}
+	valid := issue(is, "room", time.Hour, false)
+
+	tests := []struct {
+		name      string
+		token     string
+		roomID    string
+		roomNonce string
+		wantErr   error
+	}{
+		{"valid", valid, "room", "nonce", nil},
+		{"wrong room", valid, "another", "nonce", ErrWrongRoom},
+		{"recreated room", valid, "room", "other nonce", ErrWrongRoom},
+		{"expired", issue(is, "room", -time.Second, false), "room", "nonce", ErrExpired},
+		{"other secret", issue(other, "room", time.Hour, false), "room", "nonce", ErrInvalidToken},
+		{"tampered signature", valid[:len(valid)-2] + "AA", "room", "nonce", ErrInvalidToken},
+		{"tampered claims", "e30" + valid[3:], "room", "nonce", ErrInvalidToken},
+		{"no signature", valid[:len(valid)-44], "room", "nonce", ErrInvalidToken},
+		{"garbage", "garbage", "room", "nonce", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := is.Redeem(tt.token, tt.roomID, tt.roomNonce); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Redeem() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssuerRedeemSingleUse(t *testing.T) {
+	is, err := NewIssuer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, _, err := is.Issue("room", "nonce", time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, _, err := is.Issue("room", "nonce", time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"multi use", multi, nil},
+		{"multi use again", multi, nil},
+		{"single use", single, nil},
+		{"single use again", single, ErrUsed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := is.Redeem(tt.token, "room", "nonce"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Redeem() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "webrtcpg"
+
+var (
+	OriginRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "origin_rejections_total",
+		Help:      "Number of requests rejected because of disallowed origin.",
+	}, []string{"server"})
+
+	SessionsClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signaling_sessions_closed_total",
+		Help:      "Number of closed signaling sessions by close code, 0 means no code was sent.",
+	}, []string{"code"})
+
+	CallRTT = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_rtt_seconds",
+		Help:      "Round trip time of selected candidate pair reported by clients.",
+		Buckets:   []float64{.01, .025, .05, .1, .15, .2, .3, .5, 1, 2},
+	})
+
+	CallJitter = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_jitter_seconds",
+		Help:      "Inbound jitter reported by clients.",
+		Buckets:   []float64{.001, .005, .01, .02, .03, .05, .1, .2},
+	})
+
+	CallPacketLoss = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_packet_loss_ratio",
+		Help:      "Fraction of lost inbound packets reported by clients.",
+		Buckets:   []float64{0, .001, .005, .01, .02, .05, .1, .2, .5},
+	})
+
+	CallBitrate = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "call_bitrate_bits_per_second",
+		Help:      "Media bitrate reported by clients by direction.",
+		Buckets:   prometheus.ExponentialBuckets(32_000, 2, 10),
+	}, []string{"direction"})
+
+	CallCandidatePairs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "call_candidate_pairs_total",
+		Help:      "Number of stats reports by selected candidate pair types.",
+	}, []string{"local", "remote"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Number of finished webhook deliveries by status.",
+	}, []string{"status"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
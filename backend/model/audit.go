@@ -0,0 +1,31 @@
+package model
+
+// Audit outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// Audit actions.
+const (
+	AuditActionJoin         = "room.join"
+	AuditActionConnect      = "signaling.connect"
+	AuditActionModerate     = "room.moderate"
+	AuditActionAdmin        = "admin.request"
+	AuditActionAdminAuth    = "admin.auth"
+	AuditActionCreateInvite = "room.invite"
+	AuditActionAuth         = "auth.authenticate"
+)
+
+// AuditEvent is security-relevant action, Actor is user or admin that performed it,
+// UserID is affected user if any.
+type AuditEvent struct {
+	Action     string `json:"action"`
+	Outcome    string `json:"outcome"`
+	Actor      string `json:"actor,omitempty"`
+	RoomID     string `json:"room_id,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
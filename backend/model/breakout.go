@@ -0,0 +1,10 @@
+package model
+
+// AnnouncementTypeMove instructs participant to reconnect its signaling session
+// to another room, i.e. to breakout room and back to parent room.
+const AnnouncementTypeMove = "move"
+
+// MovePayload is payload of move announcement.
+type MovePayload struct {
+	RoomID string `json:"room_id"`
+}
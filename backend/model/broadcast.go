@@ -0,0 +1,8 @@
+package model
+
+// Room types. In broadcast room host is broadcaster that negotiates separate
+// peer connection with every viewer, other participants are viewers.
+const (
+	RoomTypeConference = "conference"
+	RoomTypeBroadcast  = "broadcast"
+)
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Chat announcement types. Participant sends chat with ChatMessage payload containing only text,
+// server assigns id and time and broadcasts it to the room. Chat history is sent to participant
+// when it connects to signaling.
+const (
+	AnnouncementTypeChat        = "chat"
+	AnnouncementTypeChatHistory = "chat_history"
+)
+
+// ChatMessage is text message in room, ids are increasing within room.
+type ChatMessage struct {
+	ID     int64     `json:"id"`
+	UserID string    `json:"user_id"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// ChatHistory is page of room messages ordered by id, HasMore reports if older messages exist.
+type ChatHistory struct {
+	Messages []ChatMessage `json:"messages"`
+	HasMore  bool          `json:"has_more"`
+}
@@ -0,0 +1,72 @@
+package model
+
+import "errors"
+
+// CloseReason is sent to client when server ends signaling session.
+// Application codes are in 4000-4999 range.
+type CloseReason struct {
+	Code int    `json:"code"`
+	Text string `json:"reason"`
+}
+
+// Application close codes.
+const (
+	CloseCodeInternalError   = 4000
+	CloseCodeRoomFull        = 4001
+	CloseCodeRoomNotFound    = 4002
+	CloseCodeNotAMember      = 4003
+	CloseCodeKicked          = 4004
+	CloseCodeBanned          = 4005
+	CloseCodeRoomClosed      = 4006
+	CloseCodeServerDraining  = 4007
+	CloseCodeAuthExpired     = 4009
+	CloseCodeAdmissionDenied = 4010
+)
+
+var (
+	CloseInternalError   = CloseReason{Code: CloseCodeInternalError, Text: "internal error"}
+	CloseRoomFull        = CloseReason{Code: CloseCodeRoomFull, Text: "room is full"}
+	CloseRoomNotFound    = CloseReason{Code: CloseCodeRoomNotFound, Text: "room not found"}
+	CloseNotAMember      = CloseReason{Code: CloseCodeNotAMember, Text: "not a member"}
+	CloseKicked          = CloseReason{Code: CloseCodeKicked, Text: "kicked"}
+	CloseBanned          = CloseReason{Code: CloseCodeBanned, Text: "banned"}
+	CloseRoomClosed      = CloseReason{Code: CloseCodeRoomClosed, Text: "room closed"}
+	CloseServerDraining  = CloseReason{Code: CloseCodeServerDraining, Text: "server draining"}
+	CloseAuthExpired     = CloseReason{Code: CloseCodeAuthExpired, Text: "auth expired"}
+	CloseAdmissionDenied = CloseReason{Code: CloseCodeAdmissionDenied, Text: "admission denied"}
+)
+
+// WithText returns copy of close reason with different text.
+func (cr CloseReason) WithText(text string) CloseReason {
+	cr.Text = text
+	return cr
+}
+
+// CloseError attaches close reason to an error,
+// so transport could tell client why session is refused.
+type CloseError struct {
+	Reason CloseReason
+	Err    error
+}
+
+func NewCloseError(reason CloseReason, err error) error {
+	return &CloseError{Reason: reason, Err: err}
+}
+
+func (ce *CloseError) Error() string {
+	return ce.Err.Error()
+}
+
+func (ce *CloseError) Unwrap() error {
+	return ce.Err
+}
+
+// CloseReasonOf extracts close reason from error chain.
+// If there is none, CloseInternalError is returned.
+func CloseReasonOf(err error) CloseReason {
+	var ce *CloseError
+	if errors.As(err, &ce) {
+		return ce.Reason
+	}
+	return CloseInternalError
+}
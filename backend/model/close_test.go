@@ -0,0 +1,29 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCloseReasonOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want CloseReason
+	}{
+		{"close error", NewCloseError(CloseKicked, errors.New("kicked")), CloseKicked},
+		{"wrapped", fmt.Errorf("session: %w", NewCloseError(CloseRoomNotFound, errors.New("no room"))), CloseRoomNotFound},
+		{"joined", errors.Join(errors.New("other"), NewCloseError(CloseBanned.WithText("spam"), errors.New("banned"))),
+			CloseReason{Code: CloseCodeBanned, Text: "spam"}},
+		{"plain error", errors.New("plain"), CloseInternalError},
+		{"nil", nil, CloseInternalError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CloseReasonOf(tt.err); got != tt.want {
+				t.Errorf("CloseReasonOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
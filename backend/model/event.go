@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Room lifecycle event types.
+const (
+	EventTypeRoomCreated            = "room_created"
+	EventTypeParticipantJoined      = "participant_joined"
+	EventTypeSignalingConnected     = "signaling_connected"
+	EventTypeSignalingDisconnected  = "signaling_disconnected"
+	EventTypeAnnouncementsForwarded = "announcements_forwarded"
+	EventTypeParticipantKicked      = "participant_kicked"
+	EventTypeRoomClosed             = "room_closed"
+	EventTypeParticipantBanned      = "participant_banned"
+	EventTypeRoomStateChanged       = "room_state_changed"
+	EventTypeParticipantKnocked     = "participant_knocked"
+	EventTypeParticipantAdmitted    = "participant_admitted"
+	EventTypeParticipantDenied      = "participant_denied"
+	EventTypeParticipantLeft        = "participant_left"
+	EventTypeRoomEmpty              = "room_empty"
+	EventTypeRoleChanged            = "role_changed"
+	EventTypeBreakoutsStarted       = "breakouts_started"
+	EventTypeBreakoutsEnded         = "breakouts_ended"
+)
+
+// Event describes room lifecycle change for observers.
+// It must never carry announcement payloads.
+type Event struct {
+	Type   string    `json:"type"`
+	RoomID string    `json:"room_id"`
+	UserID string    `json:"user_id,omitempty"`
+	Time   time.Time `json:"time"`
+	Data   any       `json:"data,omitempty"`
+}
+
+func NewEvent(typ, roomID, userID string, data any) Event {
+	return Event{
+		Type:   typ,
+		RoomID: roomID,
+		UserID: userID,
+		Time:   time.Now().UTC(),
+		Data:   data,
+	}
+}
@@ -3,16 +3,116 @@ package model
 type Room struct {
 	ID           string                 `json:"room_id"`
 	Participants map[string]Participant `json:"participants"`
+
+	// Host is participant with moderation rights, initially it is room creator.
+	Host   string              `json:"host"`
+	Locked bool                `json:"locked"`
+	Banned map[string]struct{} `json:"-"`
+
+	// RequireAdmission puts joiners to Lobby until host admits them.
+	RequireAdmission bool                   `json:"require_admission"`
+	Lobby            map[string]Participant `json:"lobby"`
+
+	// PasscodeHash is bcrypt hash of room passcode, it is empty if room has no passcode.
+	PasscodeHash []byte `json:"-"`
+
+	// DefaultRole is role of new participants, publisher or viewer.
+	DefaultRole string `json:"default_role"`
+
+	// Type is conference or broadcast.
+	Type string `json:"type"`
+
+	// Parent is id of room that breakout room is spawned from.
+	Parent string `json:"parent,omitempty"`
+
+	// Nonce is random value set when room is created,
+	// it tells apart rooms that are created with the same id.
+	Nonce string `json:"-"`
+}
+
+// RoomOptions are applied when room is created and ignored when room already exists.
+type RoomOptions struct {
+	RequireAdmission bool
+	PasscodeHash     []byte
+
+	// DefaultRole is role of joining users, publisher if empty.
+	DefaultRole string
+
+	// Type is room type, conference if empty.
+	Type string
+
+	// Parent makes room breakout room of parent room.
+	Parent string
+}
+
+// JoinOptions are options of single join request.
+type JoinOptions struct {
+	Create RoomOptions
+
+	// Admitted user does not wait in lobby, e.g. if it is invited.
+	Admitted bool
+
+	// Profile of joining user, it replaces existing profile if user is already a participant.
+	Profile Profile
+
+	// Viewer joins as viewer regardless of room default role.
+	Viewer bool
+
+	// Check is called under store lock before user that is not a participant yet
+	// joins existing room, join fails if it returns error. Room must not be modified.
+	Check func(room *Room) error
+}
+
+// Clone returns deep copy of room, so it can be read without store lock.
+func (r *Room) Clone() *Room {
+	participants := make(map[string]Participant, len(r.Participants))
+	for id, p := range r.Participants {
+		participants[id] = p
+	}
+	banned := make(map[string]struct{}, len(r.Banned))
+	for id := range r.Banned {
+		banned[id] = struct{}{}
+	}
+	lobby := make(map[string]Participant, len(r.Lobby))
+	for id, p := range r.Lobby {
+		lobby[id] = p
+	}
+	return &Room{
+		ID:               r.ID,
+		Participants:     participants,
+		Host:             r.Host,
+		Locked:           r.Locked,
+		Banned:           banned,
+		RequireAdmission: r.RequireAdmission,
+		Lobby:            lobby,
+		PasscodeHash:     r.PasscodeHash,
+		DefaultRole:      r.DefaultRole,
+		Type:             r.Type,
+		Parent:           r.Parent,
+		Nonce:            r.Nonce,
+	}
 }
 
 type Participant struct {
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Role string `json:"role"`
+	Profile
+	State MediaState `json:"state"`
+}
+
+// ICEServer is passed to client's RTCPeerConnection configuration.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
 }
 
 // Global announcement types that sent by server.
 const (
-	AnnouncementTypeJoined = "joined"
-	AnnouncementTypeLeft   = "left"
+	AnnouncementTypeJoined      = "joined"
+	AnnouncementTypeLeft        = "left"
+	AnnouncementTypeRateLimited = "rate_limited"
+	AnnouncementTypeNotice      = "notice"
 )
 
 type Announcement struct {
@@ -20,16 +120,33 @@ type Announcement struct {
 	SRC     string `json:"src"` // for inbound messages server re-assigns this based on websocket session
 	Type    string `json:"type"`
 	Payload any    `json:"payload"`
+
+	// Session is signaling session of viewer in broadcast room, it is set by server
+	// for viewer announcements, broadcaster sets it to address particular viewer session.
+	Session string `json:"session,omitempty"`
 }
 
 type Wire struct {
 	RX chan Announcement
 	TX chan Announcement
+
+	// Close is used by server side to end session with particular reason.
+	Close chan CloseReason
 }
 
 func NewWire() Wire {
 	return Wire{
-		RX: make(chan Announcement),
-		TX: make(chan Announcement),
+		RX:    make(chan Announcement),
+		TX:    make(chan Announcement),
+		Close: make(chan CloseReason, 1),
+	}
+}
+
+// Terminate asks transport to end session. It never blocks,
+// only first reason is delivered if called multiple times.
+func (w Wire) Terminate(reason CloseReason) {
+	select {
+	case w.Close <- reason:
+	default:
 	}
 }
@@ -0,0 +1,60 @@
+package model
+
+// Moderation announcement types that are sent by host and handled by server.
+const (
+	AnnouncementTypeKick         = "kick"
+	AnnouncementTypeBan          = "ban"
+	AnnouncementTypeLock         = "lock"
+	AnnouncementTypeUnlock       = "unlock"
+	AnnouncementTypeTransferHost = "transfer_host"
+	AnnouncementTypeAdmit        = "admit"
+	AnnouncementTypeDeny         = "deny"
+)
+
+// Announcement types that are sent by server in response to moderation.
+const (
+	AnnouncementTypeRoomState = "room_state"
+	AnnouncementTypeError     = "error"
+
+	// AnnouncementTypeKnock is sent to host when user is waiting in lobby.
+	AnnouncementTypeKnock = "knock"
+	// AnnouncementTypeAdmitted is sent to lobby user when host admits it.
+	AnnouncementTypeAdmitted = "admitted"
+)
+
+// IsModeration reports whether announcement type is moderation action.
+func IsModeration(typ string) bool {
+	switch typ {
+	case AnnouncementTypeKick, AnnouncementTypeBan, AnnouncementTypeLock,
+		AnnouncementTypeUnlock, AnnouncementTypeTransferHost,
+		AnnouncementTypeAdmit, AnnouncementTypeDeny, AnnouncementTypeSetRole:
+		return true
+	}
+	return false
+}
+
+// ModerationPayload is payload of moderation announcement, UserID is
+// target participant (or lobby user), it is ignored by lock and unlock.
+// Role is used only by set_role.
+type ModerationPayload struct {
+	UserID string `json:"user_id,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Role   string `json:"role,omitempty"`
+}
+
+// RoomState is sent to participants when host or lock state changes.
+type RoomState struct {
+	Host             string `json:"host"`
+	Locked           bool   `json:"locked"`
+	RequireAdmission bool   `json:"require_admission"`
+}
+
+// KnockPayload is payload of knock announcement.
+type KnockPayload struct {
+	UserID string `json:"user_id"`
+}
+
+// ErrorPayload is sent to participant whose request was rejected.
+type ErrorPayload struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,35 @@
+package model
+
+// Profile announcement type is sent by participant to update its profile
+// and by server to propagate updated profile to other participants.
+const (
+	AnnouncementTypeProfile = "profile"
+	AnnouncementTypeRoster  = "roster"
+)
+
+// Profile is participant metadata that is shown to other participants.
+type Profile struct {
+	DisplayName  string       `json:"display_name,omitempty"`
+	AvatarURL    string       `json:"avatar_url,omitempty"`
+	Client       string       `json:"client,omitempty"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities are media and data features declared by participant's client.
+type Capabilities struct {
+	Audio       bool `json:"audio"`
+	Video       bool `json:"video"`
+	Screen      bool `json:"screen"`
+	DataChannel bool `json:"datachannel"`
+}
+
+// RosterEntry is participant with its signaling session status.
+type RosterEntry struct {
+	Participant
+	Connected bool `json:"connected"`
+}
+
+// Roster is sent to participant when its signaling session is connected.
+type Roster struct {
+	Participants []RosterEntry `json:"participants"`
+}
@@ -0,0 +1,39 @@
+package model
+
+// Participant roles. Host and publishers send and receive media,
+// viewers only receive it and are limited separately from publishers.
+const (
+	RoleHost      = "host"
+	RolePublisher = "publisher"
+	RoleViewer    = "viewer"
+)
+
+const (
+	// AnnouncementTypeSetRole is moderation action that changes participant role.
+	AnnouncementTypeSetRole = "set_role"
+	// AnnouncementTypeRole is sent to participants when someone's role changes.
+	AnnouncementTypeRole = "role"
+)
+
+// Session description announcement types, they are checked against sender role.
+const (
+	AnnouncementTypeOffer  = "offer"
+	AnnouncementTypeAnswer = "answer"
+)
+
+// RolePayload is payload of role announcement.
+type RolePayload struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// SessionDescription is payload of offer and answer announcements.
+type SessionDescription struct {
+	Type string `json:"type"`
+	SDP  string `json:"sdp"`
+}
+
+// IsPublishing reports whether participant with role may send media.
+func IsPublishing(role string) bool {
+	return role != RoleViewer
+}
@@ -0,0 +1,14 @@
+package model
+
+// AnnouncementTypeState is sent by participant when its media or presence state
+// changes, server records it and forwards to other participants.
+const AnnouncementTypeState = "state"
+
+// MediaState is participant's media and presence state.
+type MediaState struct {
+	Muted         bool `json:"muted"`
+	CameraOff     bool `json:"camera_off"`
+	ScreenSharing bool `json:"screen_sharing"`
+	HandRaised    bool `json:"hand_raised"`
+	Speaking      bool `json:"speaking"`
+}
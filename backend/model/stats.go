@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// AnnouncementTypeStats is sent by participant with StatsReport payload,
+// it is not forwarded to other participants.
+const AnnouncementTypeStats = "stats"
+
+// ICE candidate types.
+const (
+	CandidateTypeHost  = "host"
+	CandidateTypeSrflx = "srflx"
+	CandidateTypePrflx = "prflx"
+	CandidateTypeRelay = "relay"
+)
+
+// StatsReport is call quality summary of participant's peer connection
+// made by client from getStats() results. RoomID, UserID and ReceivedAt are set by server.
+type StatsReport struct {
+	RoomID     string    `json:"room_id"`
+	UserID     string    `json:"user_id"`
+	ReceivedAt time.Time `json:"received_at"`
+
+	// PeerID is remote participant of peer connection.
+	PeerID string `json:"peer_id"`
+
+	RTTMs           float64 `json:"rtt_ms"`
+	JitterMs        float64 `json:"jitter_ms"`
+	PacketLoss      float64 `json:"packet_loss"` // fraction of lost inbound packets
+	InboundBitrate  float64 `json:"inbound_bitrate"`
+	OutboundBitrate float64 `json:"outbound_bitrate"`
+
+	// Selected candidate pair types, e.g. relay if TURN is used.
+	LocalCandidateType  string `json:"local_candidate_type"`
+	RemoteCandidateType string `json:"remote_candidate_type"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Webhook delivery statuses.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDelivery is room event delivery to webhook endpoint.
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"`
+	Event          Event      `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
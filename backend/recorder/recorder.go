@@ -0,0 +1,238 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+const (
+	fileExt = ".jsonl"
+
+	defaultBufferSize = 1024
+
+	// max size of single recorded line when reading recording
+	maxLineSize = 1 << 20
+)
+
+// Record events.
+const (
+	EventConnected    = "connected"
+	EventDisconnected = "disconnected"
+	EventAnnouncement = "announcement"
+)
+
+var (
+	ErrRecord = errors.New("unable to write record")
+	ErrRead   = errors.New("unable to read recording")
+)
+
+// Record is single line of recording. Connected and disconnected
+// records have endpoint in SRC, announcement records have announcement fields.
+type Record struct {
+	Time    time.Time       `json:"time"`
+	Room    string          `json:"room"`
+	Event   string          `json:"event"`
+	SRC     string          `json:"src,omitempty"`
+	DST     string          `json:"dst,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Recorder writes switch activity to JSON Lines file per room.
+// Files are opened on first record and closed when room has no endpoints,
+// recording continues in the same file if room is used again.
+//
+// Records are queued in calling order and written by single goroutine,
+// so recording does not block switch. Records are dropped if queue is full.
+type Recorder struct {
+	logger zerolog.Logger
+	dir    string
+
+	mx      *sync.RWMutex
+	closed  bool
+	records chan entry
+	done    chan struct{}
+
+	// files are accessed only by writer goroutine
+	files map[string]*os.File
+}
+
+// entry is queued record, last closes room file after record is written.
+type entry struct {
+	record *Record
+	last   bool
+}
+
+type Config struct {
+	Logger *zerolog.Logger
+
+	// Dir is directory where recordings are written, it is created if not exists.
+	Dir string
+
+	// BufferSize is number of records that are queued for writing.
+	BufferSize int
+}
+
+func NewRecorder(cfg Config) (*Recorder, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, err
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	rec := &Recorder{
+		logger:  cfg.Logger.With().Str("component", "recorder").Logger(),
+		dir:     cfg.Dir,
+		mx:      &sync.RWMutex{},
+		records: make(chan entry, cfg.BufferSize),
+		done:    make(chan struct{}),
+		files:   make(map[string]*os.File),
+	}
+	go rec.run()
+	return rec, nil
+}
+
+// Connected records endpoint connection.
+func (rec *Recorder) Connected(instance, endpoint string) {
+	rec.enqueue(&Record{Room: instance, Event: EventConnected, SRC: endpoint}, false)
+}
+
+// Disconnected records endpoint disconnection, room file is closed
+// if it was the last endpoint.
+func (rec *Recorder) Disconnected(instance, endpoint string, last bool) {
+	rec.enqueue(&Record{Room: instance, Event: EventDisconnected, SRC: endpoint}, last)
+}
+
+// Announcement records announcement that is being forwarded.
+func (rec *Recorder) Announcement(instance string, ann model.Announcement) {
+	r := &Record{
+		Room:  instance,
+		Event: EventAnnouncement,
+		SRC:   ann.SRC,
+		DST:   ann.DST,
+		Type:  ann.Type,
+	}
+	if ann.Payload != nil {
+		payload, err := json.Marshal(ann.Payload)
+		if err != nil {
+			rec.logger.Error().Err(err).
+				Str("room", instance).
+				Str("type", ann.Type).
+				Msg("cannot encode payload")
+			return
+		}
+		r.Payload = payload
+	}
+	rec.enqueue(r, false)
+}
+
+// Close writes queued records and closes all recording files.
+// Records are ignored after Close.
+func (rec *Recorder) Close() {
+	rec.mx.Lock()
+	if !rec.closed {
+		rec.closed = true
+		close(rec.records)
+	}
+	rec.mx.Unlock()
+	<-rec.done
+}
+
+// Path returns recording file path of room.
+func (rec *Recorder) Path(instance string) string {
+	return filepath.Join(rec.dir, url.PathEscape(instance)+fileExt)
+}
+
+// enqueue timestamps record and queues it for writing.
+func (rec *Recorder) enqueue(r *Record, last bool) {
+	r.Time = time.Now().UTC()
+
+	rec.mx.RLock()
+	defer rec.mx.RUnlock()
+	if rec.closed {
+		return
+	}
+	select {
+	case rec.records <- entry{record: r, last: last}:
+	default:
+		rec.logger.Error().
+			Str("room", r.Room).
+			Str("event", r.Event).
+			Msg("record is dropped, queue is full")
+	}
+}
+
+func (rec *Recorder) run() {
+	defer close(rec.done)
+	for e := range rec.records {
+		rec.write(e.record)
+		if f, ok := rec.files[e.record.Room]; ok && e.last {
+			rec.closeFile(e.record.Room, f)
+		}
+	}
+	for instance, f := range rec.files {
+		rec.closeFile(instance, f)
+	}
+}
+
+func (rec *Recorder) write(r *Record) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		rec.logger.Error().Err(err).Str("room", r.Room).Msg("cannot encode record")
+		return
+	}
+	b = append(b, '\n')
+
+	f, ok := rec.files[r.Room]
+	if !ok {
+		if f, err = os.OpenFile(rec.Path(r.Room), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+			rec.logger.Error().Err(errors.Join(ErrRecord, err)).Str("room", r.Room).Msg("cannot open recording")
+			return
+		}
+		rec.files[r.Room] = f
+	}
+	if _, err = f.Write(b); err != nil {
+		rec.logger.Error().Err(errors.Join(ErrRecord, err)).Str("room", r.Room).Msg("cannot write record")
+	}
+}
+
+func (rec *Recorder) closeFile(instance string, f *os.File) {
+	if err := f.Close(); err != nil {
+		rec.logger.Error().Err(err).Str("room", instance).Msg("cannot close recording")
+	}
+	delete(rec.files, instance)
+}
+
+// Read reads recording records in written order.
+func Read(r io.Reader) ([]Record, error) {
+	var (
+		records []Record
+		sc      = bufio.NewScanner(r)
+	)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrRead, line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Join(ErrRead, err)
+	}
+	return records, nil
+}
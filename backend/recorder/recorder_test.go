@@ -0,0 +1,87 @@
+package recorder
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+func TestRecorder(t *testing.T) {
+	logger := zerolog.Nop()
+	rec, err := NewRecorder(Config{Logger: &logger, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Connected("room/1", "alice")
+	rec.Connected("room/1", "bob")
+	rec.Announcement("room/1", model.Announcement{SRC: "alice", DST: "bob", Type: "offer", Payload: map[string]string{"sdp": "v=0"}})
+	rec.Announcement("room/1", model.Announcement{SRC: "bob", Type: "joined"})
+	rec.Disconnected("room/1", "bob", false)
+	rec.Disconnected("room/1", "alice", true)
+	rec.Connected("room/1", "carol")
+	rec.Close()
+	// records after close are ignored
+	rec.Connected("room/1", "dave")
+
+	f, err := os.Open(rec.Path("room/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	records, err := Read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Record{
+		{Event: EventConnected, SRC: "alice"},
+		{Event: EventConnected, SRC: "bob"},
+		{Event: EventAnnouncement, SRC: "alice", DST: "bob", Type: "offer", Payload: []byte(`{"sdp":"v=0"}`)},
+		{Event: EventAnnouncement, SRC: "bob", Type: "joined"},
+		{Event: EventDisconnected, SRC: "bob"},
+		{Event: EventDisconnected, SRC: "alice"},
+		{Event: EventConnected, SRC: "carol"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for i, r := range records {
+		w := want[i]
+		if r.Room != "room/1" || r.Event != w.Event || r.SRC != w.SRC || r.DST != w.DST ||
+			r.Type != w.Type || string(r.Payload) != string(w.Payload) || r.Time.IsZero() {
+			t.Errorf("record %d = %+v, want %+v", i, r, w)
+		}
+		if i > 0 && r.Time.Before(records[i-1].Time) {
+			t.Errorf("record %d is out of order", i)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"records", `{"event":"connected","src":"a"}` + "\n\n" + `{"event":"disconnected","src":"a"}` + "\n", 2, false},
+		{"invalid line", `{"event":"connected"}` + "\n" + "{", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Read(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(records) != tt.want {
+				t.Errorf("Read() got %d records, want %d", len(records), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/metrics"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultAllowMethods = "POST, GET, OPTIONS"
+	defaultAllowHeaders = "Origin, Content-Type, Accept, Authorization"
+	defaultMaxAge       = 24 * time.Hour
+)
+
+// Config defines origin policy.
+//
+// AllowedOrigins entries are either exact origins like "https://example.com",
+// wildcard subdomain origins like "https://*.example.com", or "*" which allows any origin.
+// Wildcard without port matches subdomains on any port, wildcard with port matches only that port.
+// Requests without Origin header and same-origin requests are always allowed.
+// Credentials are never allowed together with "*", since it would let any site
+// make authenticated requests.
+type Config struct {
+	AllowedOrigins   []string
+	AllowCredentials bool
+}
+
+// Policy checks request origins against allowlist and sets CORS headers.
+// Policy rules can be updated in runtime.
+type Policy struct {
+	logger zerolog.Logger
+	server string
+	rules  atomic.Pointer[rules]
+}
+
+type rules struct {
+	exact       map[string]struct{}
+	wildcards   []wildcard
+	any         bool
+	credentials bool
+}
+
+type wildcard struct {
+	scheme string
+	suffix string // host suffix with leading dot
+	port   string // empty matches any port
+}
+
+func NewPolicy(cfg Config, server string, logger *zerolog.Logger) *Policy {
+	p := &Policy{
+		logger: logger.With().Str("component", "cors").Logger(),
+		server: server,
+	}
+	p.Update(cfg)
+	return p
+}
+
+// Update replaces policy rules.
+func (p *Policy) Update(cfg Config) {
+	p.rules.Store(newRules(cfg))
+}
+
+func newRules(cfg Config) *rules {
+	p := &rules{
+		exact: make(map[string]struct{}),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "":
+		case origin == "*":
+			p.any = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			suffix, port, _ := strings.Cut(host, ":")
+			p.wildcards = append(p.wildcards, wildcard{scheme: scheme, suffix: suffix, port: port})
+		default:
+			p.exact[origin] = struct{}{}
+		}
+	}
+	p.credentials = cfg.AllowCredentials && !p.any
+	return p
+}
+
+// Allowed checks if origin is allowed by configured list.
+func (p *Policy) Allowed(origin string) bool {
+	return p.rules.Load().allowed(origin)
+}
+
+func (p *rules) allowed(origin string) bool {
+	if p.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := p.exact[origin]; ok {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	for _, w := range p.wildcards {
+		if u.Scheme == w.scheme && strings.HasSuffix(host, w.suffix) && len(host) > len(w.suffix) &&
+			(w.port == "" || w.port == u.Port()) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrigin can be used as websocket.Upgrader.CheckOrigin.
+// Rejections are logged and counted.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || sameOrigin(origin, r.Host) || p.Allowed(origin) {
+		return true
+	}
+	p.logger.Warn().
+		Str("server", p.server).
+		Str("origin", origin).
+		Str("remote", r.RemoteAddr).
+		Msg("origin is not allowed")
+	metrics.OriginRejections.WithLabelValues(p.server).Inc()
+	return false
+}
+
+// Handler enforces origin policy, sets CORS headers and responds to preflight requests.
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !p.CheckOrigin(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if p.rules.Load().credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", defaultAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", defaultAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(defaultMaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
@@ -0,0 +1,71 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestPolicyAllowed(t *testing.T) {
+	logger := zerolog.Nop()
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"exact", []string{"https://example.com"}, "https://example.com", true},
+		{"exact case and slash", []string{"HTTPS://Example.com/"}, "https://example.com", true},
+		{"exact other port", []string{"https://example.com"}, "https://example.com:8443", false},
+		{"any", []string{"*"}, "https://evil.com", true},
+		{"not listed", []string{"https://example.com"}, "https://evil.com", false},
+		{"wildcard", []string{"https://*.example.com"}, "https://a.example.com", true},
+		{"wildcard nested", []string{"https://*.example.com"}, "https://a.b.example.com", true},
+		{"wildcard any port", []string{"https://*.example.com"}, "https://a.example.com:8443", true},
+		{"wildcard apex", []string{"https://*.example.com"}, "https://example.com", false},
+		{"wildcard scheme", []string{"https://*.example.com"}, "http://a.example.com", false},
+		{"wildcard lookalike", []string{"https://*.example.com"}, "https://aexample.com", false},
+		{"wildcard suffix attack", []string{"https://*.example.com"}, "https://a.example.com.evil.com", false},
+		{"wildcard port", []string{"https://*.example.com:8443"}, "https://a.example.com:8443", true},
+		{"wildcard port mismatch", []string{"https://*.example.com:8443"}, "https://a.example.com", false},
+		{"wildcard other port", []string{"https://*.example.com:8443"}, "https://a.example.com:9443", false},
+		{"invalid origin", []string{"https://*.example.com"}, "://a.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPolicy(Config{AllowedOrigins: tt.allowed}, "test", &logger)
+			if got := p.Allowed(tt.origin); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCredentials(t *testing.T) {
+	logger := zerolog.Nop()
+	tests := []struct {
+		name    string
+		allowed []string
+		want    string
+	}{
+		{"exact", []string{"https://app.example.com"}, "true"},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "true"},
+		{"any", []string{"*"}, ""},
+		{"any with exact", []string{"https://app.example.com", "*"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPolicy(Config{AllowedOrigins: tt.allowed, AllowCredentials: true}, "test", &logger)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Origin", "https://app.example.com")
+			w := httptest.NewRecorder()
+			p.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.want {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/service"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// AdminService is used by admin API to inspect and manage live state.
+// Errors are returned only if room or participant is not found.
+type AdminService interface {
+	ListRooms(filter service.RoomFilter) ([]service.RoomSummary, int)
+	GetRoomDetails(roomID string) (*service.RoomDetails, error)
+	KickParticipant(roomID, userID, reason string) error
+	CloseRoom(roomID, reason string) error
+	SendNotice(ctx context.Context, roomID, text string) error
+	RoomStats(roomID, userID string, since time.Time, limit int) []model.StatsReport
+}
+
+// Auditor receives security-relevant events.
+type Auditor interface {
+	Audit(model.AuditEvent)
+}
+
+type RoomsResponse struct {
+	Rooms  []service.RoomSummary `json:"rooms"`
+	Total  int                   `json:"total"`
+	Offset int                   `json:"offset"`
+	Limit  int                   `json:"limit"`
+}
+
+type AdminActionRequest struct {
+	Reason string `json:"reason"`
+	Text   string `json:"text"`
+}
+
+func (srv *Server) adminRoutes(r *http.ServeMux, h func(http.HandlerFunc) http.Handler) {
+	r.Handle("GET /admin/rooms", h(srv.listRooms))
+	r.Handle("GET /admin/rooms/{roomID}", h(srv.getRoom))
+	r.Handle("GET /admin/rooms/{roomID}/stats", h(srv.roomStats))
+	r.Handle("POST /admin/rooms/{roomID}/close", h(srv.closeRoom))
+	r.Handle("POST /admin/rooms/{roomID}/notice", h(srv.sendNotice))
+	r.Handle("POST /admin/rooms/{roomID}/participants/{userID}/kick", h(srv.kickParticipant))
+	if srv.webhooks != nil {
+		r.Handle("GET /admin/webhooks/deliveries", h(srv.listDeliveries))
+		r.Handle("GET /admin/webhooks/deliveries/{deliveryID}", h(srv.getDelivery))
+		r.Handle("POST /admin/webhooks/deliveries/{deliveryID}/retry", h(srv.retryDelivery))
+	}
+}
+
+// SetAdminToken updates admin API token, empty token disables admin API.
+func (srv *Server) SetAdminToken(token string) {
+	srv.adminToken.Store(&token)
+}
+
+// adminAuth checks bearer token of admin API request. Authentication failures
+// and state-changing requests are audited.
+func (srv *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := *srv.adminToken.Load()
+		if token == "" {
+			writeError(w, http.StatusForbidden, "admin api is disabled")
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			srv.audit(r, model.AuditActionAdminAuth, http.StatusUnauthorized)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		if r.Method == http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		srv.audit(r, model.AuditActionAdmin, sw.status)
+	})
+}
+
+// statusWriter remembers response status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (srv *Server) audit(r *http.Request, action string, status int) {
+	if srv.auditor == nil {
+		return
+	}
+	ev := model.AuditEvent{
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		Actor:      "admin",
+		RoomID:     r.PathValue("roomID"),
+		UserID:     r.PathValue("userID"),
+		Detail:     r.Method + " " + r.URL.Path,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if status >= http.StatusBadRequest {
+		ev.Outcome = model.AuditFailure
+		ev.Reason = http.StatusText(status)
+	}
+	srv.auditor.Audit(ev)
+}
+
+func (srv *Server) listRooms(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := service.RoomFilter{
+		Prefix: q.Get("prefix"),
+		Limit:  defaultPageLimit,
+	}
+	for _, param := range []struct {
+		name string
+		dst  *int
+	}{
+		{"min_participants", &filter.MinParticipants},
+		{"offset", &filter.Offset},
+		{"limit", &filter.Limit},
+	} {
+		s := q.Get(param.name)
+		if s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid "+param.name)
+			return
+		}
+		*param.dst = n
+	}
+	if filter.Limit == 0 || filter.Limit > maxPageLimit {
+		filter.Limit = maxPageLimit
+	}
+
+	rooms, total := srv.adminSvc.ListRooms(filter)
+	writeJSON(w, http.StatusOK, &GenericResponse{
+		Message: "OK",
+		Data: &RoomsResponse{
+			Rooms:  rooms,
+			Total:  total,
+			Offset: filter.Offset,
+			Limit:  filter.Limit,
+		},
+	})
+}
+
+func (srv *Server) getRoom(w http.ResponseWriter, r *http.Request) {
+	details, err := srv.adminSvc.GetRoomDetails(r.PathValue("roomID"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK", Data: details})
+}
+
+// roomStats returns call quality reports of room, they are available after room is closed
+// until retention period ends. Query params are user_id, since (RFC 3339 time) and limit.
+func (srv *Server) roomStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var (
+		since time.Time
+		limit int
+		err   error
+	)
+	if s := q.Get("since"); s != "" {
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+	reports := srv.adminSvc.RoomStats(r.PathValue("roomID"), q.Get("user_id"), since, limit)
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK", Data: reports})
+}
+
+func (srv *Server) kickParticipant(w http.ResponseWriter, r *http.Request) {
+	var req AdminActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := srv.adminSvc.KickParticipant(r.PathValue("roomID"), r.PathValue("userID"), req.Reason); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+}
+
+func (srv *Server) closeRoom(w http.ResponseWriter, r *http.Request) {
+	var req AdminActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := srv.adminSvc.CloseRoom(r.PathValue("roomID"), req.Reason); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+}
+
+func (srv *Server) sendNotice(w http.ResponseWriter, r *http.Request) {
+	var req AdminActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Text == "" {
+		writeError(w, http.StatusBadRequest, "text must not be empty")
+		return
+	}
+	if err := srv.adminSvc.SendNotice(r.Context(), r.PathValue("roomID"), req.Text); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, &GenericResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, resp *GenericResponse) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeBytes(w, code, b)
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/service"
+	"github.com/rs/zerolog"
+)
+
+// testAdminService records requests of admin API.
+type testAdminService struct {
+	filter service.RoomFilter
+	closed string
+}
+
+func (ts *testAdminService) ListRooms(filter service.RoomFilter) ([]service.RoomSummary, int) {
+	ts.filter = filter
+	return []service.RoomSummary{}, 0
+}
+
+func (ts *testAdminService) GetRoomDetails(string) (*service.RoomDetails, error) {
+	return nil, errors.New("not found")
+}
+
+func (ts *testAdminService) KickParticipant(string, string, string) error {
+	return nil
+}
+
+func (ts *testAdminService) CloseRoom(roomID, _ string) error {
+	ts.closed = roomID
+	return nil
+}
+
+func (ts *testAdminService) SendNotice(context.Context, string, string) error {
+	return nil
+}
+
+func (ts *testAdminService) RoomStats(string, string, time.Time, int) []model.StatsReport {
+	return nil
+}
+
+type testAuditor struct {
+	events []model.AuditEvent
+}
+
+func (ta *testAuditor) Audit(ev model.AuditEvent) {
+	ta.events = append(ta.events, ev)
+}
+
+func newAdminTestServer(token string) (*Server, *testAdminService, *testAuditor) {
+	logger := zerolog.Nop()
+	adminSvc, auditor := &testAdminService{}, &testAuditor{}
+	srv := NewServer(Config{
+		Logger:       &logger,
+		AdminService: adminSvc,
+		AdminToken:   token,
+		Auditor:      auditor,
+	})
+	return srv, adminSvc, auditor
+}
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		adminToken  string
+		method      string
+		token       string
+		wantCode    int
+		wantOutcome string
+	}{
+		{"disabled", "", http.MethodPost, "", http.StatusForbidden, ""},
+		{"no token", "secret", http.MethodPost, "", http.StatusUnauthorized, model.AuditFailure},
+		{"wrong token", "secret", http.MethodGet, "wrong", http.StatusUnauthorized, model.AuditFailure},
+		{"read request", "secret", http.MethodGet, "secret", http.StatusOK, ""},
+		{"change request", "secret", http.MethodPost, "secret", http.StatusOK, model.AuditSuccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, adminSvc, auditor := newAdminTestServer(tt.adminToken)
+			path := "/admin/rooms"
+			if tt.method == http.MethodPost {
+				path += "/room/close"
+			}
+			r := httptest.NewRequest(tt.method, path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if closed := adminSvc.closed != ""; closed != (tt.wantOutcome == model.AuditSuccess) {
+				t.Errorf("room closed = %v with status %d", closed, w.Code)
+			}
+			var outcome string
+			if len(auditor.events) > 0 {
+				outcome = auditor.events[0].Outcome
+			}
+			if outcome != tt.wantOutcome {
+				t.Errorf("audit outcome = %q, want %q", outcome, tt.wantOutcome)
+			}
+		})
+	}
+}
+
+func TestListRoomsFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantCode   int
+		wantFilter service.RoomFilter
+	}{
+		{"defaults", "", http.StatusOK, service.RoomFilter{Limit: defaultPageLimit}},
+		{"filter", "?prefix=team&min_participants=2&offset=10&limit=5", http.StatusOK,
+			service.RoomFilter{Prefix: "team", MinParticipants: 2, Offset: 10, Limit: 5}},
+		{"limit above max", "?limit=100000", http.StatusOK, service.RoomFilter{Limit: maxPageLimit}},
+		{"negative offset", "?offset=-1", http.StatusBadRequest, service.RoomFilter{}},
+		{"invalid limit", "?limit=ten", http.StatusBadRequest, service.RoomFilter{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, adminSvc, _ := newAdminTestServer("secret")
+			r := httptest.NewRequest(http.MethodGet, "/admin/rooms"+tt.query, nil)
+			r.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantCode, strings.TrimSpace(w.Body.String()))
+			}
+			if adminSvc.filter != tt.wantFilter {
+				t.Errorf("filter = %+v, want %+v", adminSvc.filter, tt.wantFilter)
+			}
+		})
+	}
+}
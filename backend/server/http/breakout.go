@@ -0,0 +1,74 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/service"
+)
+
+// BreakoutsRequest is sent by room host. Rooms are participant ids of every breakout room,
+// Duration is duration string, e.g. 15m, participants are moved back after it.
+type BreakoutsRequest struct {
+	UserID   string     `json:"user_id"`
+	Rooms    [][]string `json:"rooms"`
+	Duration string     `json:"duration"`
+}
+
+func (srv *Server) startBreakouts(w http.ResponseWriter, r *http.Request) {
+	var req BreakoutsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var (
+		duration time.Duration
+		err      error
+	)
+	if req.Duration != "" {
+		if duration, err = time.ParseDuration(req.Duration); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid duration")
+			return
+		}
+	}
+	hostID, ok := srv.userID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	breakouts, err := srv.svc.StartBreakouts(r.Context(), r.PathValue("roomID"), hostID, req.Rooms, duration)
+	if err != nil {
+		writeBreakoutsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, &GenericResponse{Message: "OK", Data: breakouts})
+}
+
+func (srv *Server) endBreakouts(w http.ResponseWriter, r *http.Request) {
+	var req BreakoutsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	hostID, ok := srv.userID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	if err := srv.svc.EndBreakouts(r.Context(), r.PathValue("roomID"), hostID); err != nil {
+		writeBreakoutsError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+}
+
+func writeBreakoutsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotHost):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidBreakouts):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrGet), errors.Is(err, service.ErrNoBreakouts):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		writeError(w, http.StatusConflict, err.Error())
+	}
+}
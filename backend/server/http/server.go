@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"io"
@@ -9,27 +10,100 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/certs"
+	"github.com/adwski/webrtc-playground/backend/metrics"
 	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/server/cors"
+	"github.com/adwski/webrtc-playground/backend/service"
+	"github.com/adwski/webrtc-playground/backend/tracing"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	defaultShutdownDeadline = 10 * time.Second
+	maxRequestBodySize      = 4096
 )
 
 var (
 	ErrUnexpected = errors.New("unexpected server error")
 )
 
+var tracer = otel.Tracer("github.com/adwski/webrtc-playground/backend/server/http")
+
 type RoomService interface {
-	JoinRoom(roomID string, userID string) (*model.Room, error)
+	JoinRoom(ctx context.Context, roomID string, userID string, params service.JoinParams) (*model.Room, error)
+	CreateInvite(roomID, hostID string, ttl time.Duration, singleUse bool) (*service.Invite, error)
+	ICEServers() []model.ICEServer
+	Moderate(ctx context.Context, roomID, hostID, action string, payload model.ModerationPayload) error
+	ChatHistory(roomID, userID string, before int64, limit int) (*model.ChatHistory, error)
+	SubmitStats(roomID, userID string, report model.StatsReport) error
+	StartBreakouts(ctx context.Context, roomID, hostID string, assignments [][]string, duration time.Duration) (*service.Breakouts, error)
+	EndBreakouts(ctx context.Context, roomID, hostID string) error
+}
+
+// Join statuses, waiting user is in room lobby until host admits it.
+const (
+	JoinStatusJoined  = "joined"
+	JoinStatusWaiting = "waiting"
+)
+
+type JoinResponse struct {
+	Status     string            `json:"status"`
+	UserID     string            `json:"user_id"`
+	Role       string            `json:"role,omitempty"`
+	ICEServers []model.ICEServer `json:"ice_servers"`
+	Host       string            `json:"host"`
+	Locked     bool              `json:"locked"`
+	Type       string            `json:"type"`
+
+	// Traceparent is trace context of join request, client passes it
+	// to signaling session, so both are in the same trace.
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// ModerateRequest is sent by room host, Action is one of moderation announcement types.
+type ModerateRequest struct {
+	UserID string `json:"user_id"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Reason string `json:"reason"`
+
+	// Role is new role of target, it is used by set_role action.
+	Role string `json:"role"`
 }
 
 type JoinRequest struct {
 	RoomID string `json:"room_id"`
+
+	// UserID can be omitted if request is authenticated, participant id
+	// is taken from credential then. Same applies to other requests with user id.
 	UserID string `json:"user_id"`
+
+	// RequireAdmission and Passcode are applied if room is created by this request.
+	RequireAdmission bool   `json:"require_admission"`
+	Passcode         string `json:"passcode"`
+	Invite           string `json:"invite"`
+
+	// Role is publisher or viewer, new participant gets room default role if it is empty.
+	// DefaultRole and Type (conference or broadcast) are applied if room is created by this request.
+	Role        string `json:"role"`
+	DefaultRole string `json:"default_role"`
+	Type        string `json:"type"`
+
+	Profile model.Profile `json:"profile"`
+}
+
+// InviteRequest is sent by room host, TTL is duration string, e.g. 1h.
+type InviteRequest struct {
+	UserID    string `json:"user_id"`
+	TTL       string `json:"ttl"`
+	SingleUse bool   `json:"single_use"`
 }
 
 type GenericResponse struct {
@@ -41,75 +115,365 @@ type GenericResponse struct {
 type Server struct {
 	logger zerolog.Logger
 	svc    RoomService
+	cors   *cors.Policy
 	*http.Server
+
+	auth       auth.Authenticator
+	adminSvc   AdminService
+	adminToken atomic.Pointer[string]
+	auditor    Auditor
+	webhooks   WebhookService
+
+	shutdownTimeout time.Duration
 }
 
 type Config struct {
-	Logger      *zerolog.Logger
-	RoomService RoomService
-	ListenAddr  string
+	Logger          *zerolog.Logger
+	RoomService     RoomService
+	ListenAddr      string
+	CORS            cors.Config
+	ShutdownTimeout time.Duration
+
+	// TLS enables https if set. If TLS.ClientCAs is set,
+	// admin routes require verified client certificate.
+	TLS *tls.Config
+
+	// Routes are additional handlers mounted on server mux,
+	// keys are ServeMux patterns.
+	Routes map[string]http.Handler
+
+	// AdminRoutes are mounted same way as Routes, but require admin token (and client certificate if mTLS is configured).
+	AdminRoutes map[string]http.Handler
+
+	// AdminService enables admin API under /admin/, it requires AdminToken
+	// as bearer token in addition to admin routes protection.
+	AdminService AdminService
+	AdminToken   string
+
+	// Authenticator identifies participants, client provided ids are trusted if it is not set.
+	Authenticator auth.Authenticator
+
+	// Auditor receives admin API requests and authentication failures, optional.
+	Auditor Auditor
+
+	// Webhooks enables webhook deliveries inspection in admin API, optional.
+	Webhooks WebhookService
 }
 
 func NewServer(cfg Config) *Server {
 	srv := &Server{
 		logger: cfg.Logger.With().Str("component", "api-server").Logger(),
 		svc:    cfg.RoomService,
+		cors:   cors.NewPolicy(cfg.CORS, "api", cfg.Logger),
+		auth:   cfg.Authenticator,
+
+		adminSvc: cfg.AdminService,
+		auditor:  cfg.Auditor,
+		webhooks: cfg.Webhooks,
+
+		shutdownTimeout: cfg.ShutdownTimeout,
 	}
+	if srv.shutdownTimeout == 0 {
+		srv.shutdownTimeout = defaultShutdownDeadline
+	}
+	if srv.auth == nil {
+		srv.auth = auth.Anonymous{}
+	}
+	srv.SetAdminToken(cfg.AdminToken)
 
 	r := http.NewServeMux()
 	r.HandleFunc("POST /api/room", srv.joinRoom)
-	r.HandleFunc("OPTIONS /", corsHandler)
+	r.HandleFunc("POST /api/room/{roomID}/moderate", srv.moderate)
+	r.HandleFunc("POST /api/room/{roomID}/invite", srv.createInvite)
+	r.HandleFunc("GET /api/room/{roomID}/chat", srv.chatHistory)
+	r.HandleFunc("POST /api/room/{roomID}/stats", srv.submitStats)
+	r.HandleFunc("POST /api/room/{roomID}/breakouts", srv.startBreakouts)
+	r.HandleFunc("POST /api/room/{roomID}/breakouts/end", srv.endBreakouts)
+	r.Handle("GET /metrics", srv.admin(cfg.TLS, srv.adminAuth(metrics.Handler())))
+	for pattern, h := range cfg.Routes {
+		r.Handle(pattern, h)
+	}
+	for pattern, h := range cfg.AdminRoutes {
+		r.Handle(pattern, srv.admin(cfg.TLS, srv.adminAuth(h)))
+	}
+	if srv.adminSvc != nil {
+		srv.adminRoutes(r, func(h http.HandlerFunc) http.Handler {
+			return srv.admin(cfg.TLS, srv.adminAuth(h))
+		})
+	}
 
 	srv.Server = &http.Server{
-		Addr:    cfg.ListenAddr,
-		Handler: r,
+		Addr:      cfg.ListenAddr,
+		Handler:   srv.cors.Handler(r),
+		TLSConfig: cfg.TLS,
 	}
 	return srv
 }
 
-func corsHandler(w http.ResponseWriter, _ *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
-	w.Header().Set("Access-Control-Max-Age", "86400")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.WriteHeader(http.StatusNoContent)
+// admin protects handler with client certificate check if mTLS is configured.
+func (srv *Server) admin(tlsCfg *tls.Config, h http.Handler) http.Handler {
+	if tlsCfg == nil || tlsCfg.ClientCAs == nil {
+		return h
+	}
+	return certs.RequireClientCert(h)
+}
+
+// SetCORS updates origin policy.
+func (srv *Server) SetCORS(cfg cors.Config) {
+	srv.cors.Update(cfg)
 }
 
 func (srv *Server) joinRoom(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	var (
-		body    []byte
-		joinReq JoinRequest
-	)
-	body, _ = io.ReadAll(r.Body)
-	defer func() {
-		_ = r.Body.Close()
-	}()
-	if err := json.Unmarshal(body, &joinReq); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	var joinReq JoinRequest
+	if !decodeJSON(w, r, &joinReq) {
+		return
+	}
+	if joinReq.RoomID == "" {
+		writeError(w, http.StatusBadRequest, "room_id is required")
 		return
 	}
 
 	srv.logger.Trace().Any("request", joinReq).Msg("got join request")
 
-	_, err := srv.svc.JoinRoom(joinReq.RoomID, joinReq.UserID)
+	userID, ok := srv.userID(w, r, joinReq.UserID)
+	if !ok {
+		return
+	}
+	if joinReq.Role != "" && joinReq.Role != model.RolePublisher && joinReq.Role != model.RoleViewer {
+		writeError(w, http.StatusBadRequest, service.ErrInvalidRole.Error())
+		return
+	}
+
+	ctx, span := tracer.Start(tracing.Extract(r), "POST /api/room",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			tracing.AttrRoomID.String(joinReq.RoomID),
+			tracing.AttrUserID.String(userID),
+		))
+	defer span.End()
+
+	room, err := srv.svc.JoinRoom(ctx, joinReq.RoomID, userID, service.JoinParams{
+		RequireAdmission: joinReq.RequireAdmission,
+		Passcode:         joinReq.Passcode,
+		InviteToken:      joinReq.Invite,
+		Profile:          joinReq.Profile,
+		DefaultRole:      joinReq.DefaultRole,
+		Type:             joinReq.Type,
+		Viewer:           joinReq.Role == model.RoleViewer,
+	})
 	if err != nil {
+		tracing.RecordError(span, err)
+		code := http.StatusConflict
+		switch {
+		case errors.Is(err, service.ErrPasscode), errors.Is(err, service.ErrInvalidInvite),
+			errors.Is(err, service.ErrBreakoutRoom):
+			code = http.StatusForbidden
+		case errors.Is(err, service.ErrInvalidProfile), errors.Is(err, service.ErrInvalidRole),
+			errors.Is(err, service.ErrInvalidRoomType):
+			code = http.StatusBadRequest
+		}
 		b, errJ := json.Marshal(&GenericResponse{Error: err.Error()})
 		if errJ != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		writeBytes(w, http.StatusConflict, b)
+		writeBytes(w, code, b)
 		return
 	}
 
-	b, err := json.Marshal(&GenericResponse{Message: "OK"})
+	status, code := JoinStatusJoined, http.StatusOK
+	p, ok := room.Participants[userID]
+	if !ok {
+		p = room.Lobby[userID]
+		status, code = JoinStatusWaiting, http.StatusAccepted
+	}
+	b, err := json.Marshal(&GenericResponse{
+		Message: "OK",
+		Data: &JoinResponse{
+			Status:     status,
+			UserID:     userID,
+			Role:       p.Role,
+			ICEServers: srv.svc.ICEServers(),
+			Host:       room.Host,
+			Locked:     room.Locked,
+			Type:       room.Type,
+
+			Traceparent: tracing.Traceparent(ctx),
+		},
+	})
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	writeBytes(w, http.StatusOK, b)
+	writeBytes(w, code, b)
+}
+
+func (srv *Server) moderate(w http.ResponseWriter, r *http.Request) {
+	var req ModerateRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	hostID, ok := srv.userID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	err := srv.svc.Moderate(r.Context(), r.PathValue("roomID"), hostID, req.Action, model.ModerationPayload{
+		UserID: req.Target,
+		Reason: req.Reason,
+		Role:   req.Role,
+	})
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+	case errors.Is(err, service.ErrNotHost):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrUnknownAction), errors.Is(err, service.ErrInvalidTarget),
+		errors.Is(err, service.ErrInvalidRole):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusNotFound, err.Error())
+	}
+}
+
+func (srv *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	var req InviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var (
+		ttl time.Duration
+		err error
+	)
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid ttl")
+			return
+		}
+	}
+	hostID, ok := srv.userID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	inv, err := srv.svc.CreateInvite(r.PathValue("roomID"), hostID, ttl, req.SingleUse)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusCreated, &GenericResponse{Message: "OK", Data: inv})
+	case errors.Is(err, service.ErrNotHost):
+		writeError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInviteTTL):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrGet):
+		writeError(w, http.StatusNotFound, err.Error())
+	default:
+		srv.logger.Error().Err(err).Msg("failed to create invite")
+		writeError(w, http.StatusInternalServerError, ErrUnexpected.Error())
+	}
+}
+
+// chatHistory returns room chat page to room participant. Query params are user_id,
+// before (return messages older than message with this id) and limit.
+func (srv *Server) chatHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var (
+		before int64
+		limit  int
+		err    error
+	)
+	if s := q.Get("before"); s != "" {
+		if before, err = strconv.ParseInt(s, 10, 64); err != nil || before < 0 {
+			writeError(w, http.StatusBadRequest, "invalid before")
+			return
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	userID, ok := srv.userID(w, r, q.Get("user_id"))
+	if !ok {
+		return
+	}
+
+	history, err := srv.svc.ChatHistory(r.PathValue("roomID"), userID, before, limit)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK", Data: history})
+	case errors.Is(err, service.ErrNotAMember):
+		writeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeError(w, http.StatusNotFound, err.Error())
+	}
+}
+
+// submitStats accepts call quality report of room participant, report's user_id is submitter.
+func (srv *Server) submitStats(w http.ResponseWriter, r *http.Request) {
+	var report model.StatsReport
+	if !decodeJSON(w, r, &report) {
+		return
+	}
+
+	userID, ok := srv.userID(w, r, report.UserID)
+	if !ok {
+		return
+	}
+
+	err := srv.svc.SubmitStats(r.PathValue("roomID"), userID, report)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+	case errors.Is(err, service.ErrInvalidStats):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, service.ErrNotAMember):
+		writeError(w, http.StatusForbidden, err.Error())
+	default:
+		writeError(w, http.StatusNotFound, err.Error())
+	}
+}
+
+// userID authenticates request and returns participant id,
+// error response is written if request is not authenticated.
+// In anonymous mode requested id is trusted as it is by signaling,
+// so host actions are equally available with both transports.
+func (srv *Server) userID(w http.ResponseWriter, r *http.Request, requested string) (string, bool) {
+	id, err := srv.auth.Authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return "", false
+	}
+	userID, err := auth.ResolveUserID(id, requested)
+	if err != nil {
+		code := http.StatusForbidden
+		if errors.Is(err, auth.ErrNoUserID) {
+			code = http.StatusBadRequest
+		}
+		writeError(w, code, err.Error())
+		return "", false
+	}
+	return userID, true
+}
+
+// decodeJSON reads limited request body to v, empty body leaves v unchanged.
+// Error response is written if body cannot be decoded.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body is too large")
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+	if err = json.Unmarshal(body, v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	return true
 }
 
 func writeBytes(w http.ResponseWriter, code int, b []byte) {
@@ -129,10 +493,14 @@ func (srv *Server) Run(ctx context.Context, wg *sync.WaitGroup, errc chan<- erro
 
 	hErr := make(chan error)
 	go func() {
-		hErr <- srv.ListenAndServe()
+		if srv.TLSConfig != nil {
+			hErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			hErr <- srv.ListenAndServe()
+		}
 	}()
 
-	srv.logger.Info().Str("addr", srv.Addr).Msg("server started")
+	srv.logger.Info().Str("addr", srv.Addr).Bool("tls", srv.TLSConfig != nil).Msg("server started")
 
 	select {
 	case err := <-hErr:
@@ -140,7 +508,7 @@ func (srv *Server) Run(ctx context.Context, wg *sync.WaitGroup, errc chan<- erro
 			errc <- errors.Join(ErrUnexpected, err)
 		}
 	case <-ctx.Done():
-		shCtx, shCancel := context.WithTimeout(context.Background(), defaultShutdownDeadline)
+		shCtx, shCancel := context.WithTimeout(context.Background(), srv.shutdownTimeout)
 		defer shCancel()
 		if err := srv.Shutdown(shCtx); err != nil {
 			srv.logger.Error().Err(err).Msg("server shutdown failed")
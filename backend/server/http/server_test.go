@@ -0,0 +1,220 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/invite"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/service"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+	sw "github.com/adwski/webrtc-playground/backend/switch"
+	"github.com/rs/zerolog"
+)
+
+// testService records host of host-only requests, other methods are not used.
+type testService struct {
+	RoomService
+	hostID string
+}
+
+func (ts *testService) Moderate(_ context.Context, _, hostID, _ string, _ model.ModerationPayload) error {
+	ts.hostID = hostID
+	return nil
+}
+
+func (ts *testService) CreateInvite(_, hostID string, _ time.Duration, _ bool) (*service.Invite, error) {
+	ts.hostID = hostID
+	return &service.Invite{}, nil
+}
+
+func (ts *testService) StartBreakouts(_ context.Context, _, hostID string, _ [][]string, _ time.Duration) (*service.Breakouts, error) {
+	ts.hostID = hostID
+	return &service.Breakouts{}, nil
+}
+
+func (ts *testService) EndBreakouts(_ context.Context, _, hostID string) error {
+	ts.hostID = hostID
+	return nil
+}
+
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	if auth.Credential(r) != "secret" {
+		return nil, auth.ErrInvalidCredential
+	}
+	return &auth.Identity{UserID: "host"}, nil
+}
+
+func TestHostActions(t *testing.T) {
+	paths := []string{
+		"/api/room/room/moderate",
+		"/api/room/room/invite",
+		"/api/room/room/breakouts",
+		"/api/room/room/breakouts/end",
+	}
+	tests := []struct {
+		name          string
+		authenticator auth.Authenticator
+		token         string
+		body          string
+		wantCode      int
+		wantHost      string
+	}{
+		{
+			name:     "anonymous",
+			body:     `{"user_id":"host"}`,
+			wantHost: "host",
+		},
+		{
+			name:     "anonymous without id",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:          "not authenticated",
+			authenticator: testAuthenticator{},
+			body:          `{"user_id":"host"}`,
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name:          "other user",
+			authenticator: testAuthenticator{},
+			token:         "secret",
+			body:          `{"user_id":"alice"}`,
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:          "authenticated",
+			authenticator: testAuthenticator{},
+			token:         "secret",
+			body:          `{}`,
+			wantHost:      "host",
+		},
+	}
+	for _, tt := range tests {
+		for _, path := range paths {
+			t.Run(tt.name+" "+path, func(t *testing.T) {
+				logger := zerolog.Nop()
+				svc := &testService{}
+				srv := NewServer(Config{
+					Logger:        &logger,
+					RoomService:   svc,
+					Authenticator: tt.authenticator,
+				})
+				r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+				if tt.token != "" {
+					r.Header.Set("Authorization", "Bearer "+tt.token)
+				}
+				w := httptest.NewRecorder()
+				srv.Handler.ServeHTTP(w, r)
+
+				if tt.wantCode != 0 && w.Code != tt.wantCode {
+					t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+				}
+				if svc.hostID != tt.wantHost {
+					t.Errorf("host = %q, want %q", svc.hostID, tt.wantHost)
+				}
+			})
+		}
+	}
+}
+
+func TestAnonymousInvite(t *testing.T) {
+	logger := zerolog.Nop()
+	invites, err := invite.NewIssuer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(Config{
+		Logger: &logger,
+		RoomService: service.NewService(service.Config{
+			RoomStore: memory.NewMemStore(memory.Config{}),
+			Switch:    sw.NewSwitch(sw.Config{Logger: &logger}),
+			Logger:    &logger,
+			Invites:   invites,
+		}),
+	})
+	post := func(path, body string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	if code := post("/api/room", `{"room_id":"room","user_id":"host"}`); code != http.StatusOK {
+		t.Fatalf("join status = %d, want %d", code, http.StatusOK)
+	}
+	if code := post("/api/room/room/invite", `{"user_id":"host"}`); code != http.StatusCreated {
+		t.Errorf("invite by host status = %d, want %d", code, http.StatusCreated)
+	}
+	if code := post("/api/room/room/invite", `{"user_id":"bob"}`); code != http.StatusForbidden {
+		t.Errorf("invite by not a host status = %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestJoinRoomBadRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"invalid", `{"room_id":`, http.StatusBadRequest},
+		{"empty", "", http.StatusBadRequest},
+		{"no room", `{"user_id":"alice"}`, http.StatusBadRequest},
+		{"too large", `{"room_id":"` + strings.Repeat("r", maxRequestBodySize) + `"}`, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := zerolog.Nop()
+			srv := NewServer(Config{Logger: &logger, RoomService: &testService{}})
+			w := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/room", strings.NewReader(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			var resp GenericResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Error == "" {
+				t.Errorf("response %q has no error message", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantOK   bool
+		wantCode int
+		wantTTL  string
+	}{
+		{"empty", "", true, http.StatusOK, ""},
+		{"valid", `{"ttl":"1h"}`, true, http.StatusOK, "1h"},
+		{"invalid", `{"ttl":`, false, http.StatusBadRequest, ""},
+		{"too large", `{"ttl":"` + strings.Repeat("1", maxRequestBodySize) + `"}`, false, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req InviteRequest
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			if ok := decodeJSON(w, r, &req); ok != tt.wantOK {
+				t.Fatalf("decodeJSON() = %v, want %v", ok, tt.wantOK)
+			}
+			if w.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if req.TTL != tt.wantTTL {
+				t.Errorf("ttl = %q, want %q", req.TTL, tt.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/webhook"
+)
+
+// WebhookService provides webhook deliveries status.
+type WebhookService interface {
+	Deliveries(status string, limit int) []model.WebhookDelivery
+	Delivery(id string) (*model.WebhookDelivery, error)
+	Retry(id string) error
+}
+
+// listDeliveries returns latest webhook deliveries, query params are status and limit.
+func (srv *Server) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "", model.WebhookPending, model.WebhookDelivered, model.WebhookFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	limit := defaultPageLimit
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	if limit == 0 || limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{
+		Message: "OK",
+		Data:    srv.webhooks.Deliveries(status, limit),
+	})
+}
+
+func (srv *Server) getDelivery(w http.ResponseWriter, r *http.Request) {
+	delivery, err := srv.webhooks.Delivery(r.PathValue("deliveryID"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK", Data: delivery})
+}
+
+// retryDelivery queues failed delivery again.
+func (srv *Server) retryDelivery(w http.ResponseWriter, r *http.Request) {
+	err := srv.webhooks.Retry(r.PathValue("deliveryID"))
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, &GenericResponse{Message: "OK"})
+	case errors.Is(err, webhook.ErrNotFailed):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusNotFound, err.Error())
+	}
+}
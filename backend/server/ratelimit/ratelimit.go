@@ -0,0 +1,316 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"golang.org/x/time/rate"
+)
+
+// CloseRateLimited ends session that violates limits with disconnect action.
+// It is standard policy violation code rather than application one,
+// since rate limiting is done by signaling transports.
+var CloseRateLimited = model.CloseReason{Code: 1008, Text: "rate limit exceeded"}
+
+// Rate limit violation actions.
+const (
+	ActionDrop       = "drop"
+	ActionWarn       = "warn"
+	ActionDisconnect = "disconnect"
+)
+
+// Config holds flood protection settings.
+// Zero rate disables corresponding limit.
+type Config struct {
+	// Action is taken when message exceeds connection or room limits.
+	Action string
+
+	ConnMessagesPerSec float64
+	ConnMessagesBurst  int
+	ConnBytesPerSec    float64
+	ConnBytesBurst     int
+
+	RoomMessagesPerSec float64
+	RoomMessagesBurst  int
+	RoomBytesPerSec    float64
+	RoomBytesBurst     int
+
+	// MaxConnsPerIP limits concurrent signaling sessions from single ip.
+	MaxConnsPerIP int
+
+	// TrustedProxies are addresses or CIDRs of reverse proxies, client ip is taken
+	// from X-Forwarded-For or X-Real-IP headers only in requests from them.
+	TrustedProxies []string
+}
+
+// limiter is a pair of token buckets for message and byte rates.
+type limiter struct {
+	msgs  *rate.Limiter
+	bytes *rate.Limiter
+}
+
+func newLimiter(msgsPerSec float64, msgsBurst int, bytesPerSec float64, bytesBurst int) *limiter {
+	l := &limiter{
+		msgs:  rate.NewLimiter(rate.Inf, 0),
+		bytes: rate.NewLimiter(rate.Inf, 0),
+	}
+	l.set(msgsPerSec, msgsBurst, bytesPerSec, bytesBurst)
+	return l
+}
+
+func (l *limiter) set(msgsPerSec float64, msgsBurst int, bytesPerSec float64, bytesBurst int) {
+	setBucket(l.msgs, msgsPerSec, msgsBurst)
+	setBucket(l.bytes, bytesPerSec, bytesBurst)
+}
+
+func setBucket(b *rate.Limiter, perSec float64, burst int) {
+	if perSec <= 0 {
+		b.SetLimit(rate.Inf)
+		return
+	}
+	if burst <= 0 {
+		burst = int(perSec)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	b.SetBurst(burst)
+	b.SetLimit(rate.Limit(perSec))
+}
+
+// allow checks whether message of size n fits into both buckets.
+// Tokens are consumed only if message is allowed.
+func (l *limiter) allow(n int) bool {
+	_, ok := l.reserve(n)
+	return ok
+}
+
+// reserve consumes tokens for message of size n if it fits into both buckets.
+// Returned cancel func gives tokens back.
+func (l *limiter) reserve(n int) (func(), bool) {
+	now := time.Now()
+	rm := l.msgs.ReserveN(now, 1)
+	if !rm.OK() || rm.DelayFrom(now) > 0 {
+		rm.CancelAt(now)
+		return nil, false
+	}
+	// byte bucket never allows message larger than its burst
+	rb := l.bytes.ReserveN(now, n)
+	if !rb.OK() || rb.DelayFrom(now) > 0 {
+		rb.CancelAt(now)
+		rm.CancelAt(now)
+		return nil, false
+	}
+	return func() {
+		rb.CancelAt(now)
+		rm.CancelAt(now)
+	}, true
+}
+
+// Limiter keeps track of connection, room and ip limits,
+// so they can be updated in runtime. It is shared by signaling transports.
+type Limiter struct {
+	mx      *sync.Mutex
+	cfg     Config
+	proxies []netip.Prefix
+	rooms   map[string]*roomLimiter
+	conns   map[*limiter]struct{}
+	ips     map[string]int
+}
+
+type roomLimiter struct {
+	*limiter
+	refs int
+}
+
+// Session is connection and room limiters applied to every inbound message.
+type Session struct {
+	rl     *Limiter
+	roomID string
+	conn   *limiter
+	room   *limiter
+}
+
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		mx:      &sync.Mutex{},
+		cfg:     cfg,
+		proxies: parseProxies(cfg.TrustedProxies),
+		rooms:   make(map[string]*roomLimiter),
+		conns:   make(map[*limiter]struct{}),
+		ips:     make(map[string]int),
+	}
+}
+
+// Update applies new limits to existing and future sessions.
+func (rl *Limiter) Update(cfg Config) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	rl.cfg = cfg
+	rl.proxies = parseProxies(cfg.TrustedProxies)
+	for l := range rl.conns {
+		l.set(cfg.ConnMessagesPerSec, cfg.ConnMessagesBurst, cfg.ConnBytesPerSec, cfg.ConnBytesBurst)
+	}
+	for _, l := range rl.rooms {
+		l.set(cfg.RoomMessagesPerSec, cfg.RoomMessagesBurst, cfg.RoomBytesPerSec, cfg.RoomBytesBurst)
+	}
+}
+
+// Action returns configured rate limit violation action.
+func (rl *Limiter) Action() string {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+	return rl.cfg.Action
+}
+
+// OpenSession returns limits of new signaling session in room.
+func (rl *Limiter) OpenSession(roomID string) *Session {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	room, ok := rl.rooms[roomID]
+	if !ok {
+		room = &roomLimiter{
+			limiter: newLimiter(
+				rl.cfg.RoomMessagesPerSec, rl.cfg.RoomMessagesBurst,
+				rl.cfg.RoomBytesPerSec, rl.cfg.RoomBytesBurst),
+		}
+		rl.rooms[roomID] = room
+	}
+	room.refs++
+
+	conn := newLimiter(
+		rl.cfg.ConnMessagesPerSec, rl.cfg.ConnMessagesBurst,
+		rl.cfg.ConnBytesPerSec, rl.cfg.ConnBytesBurst)
+	rl.conns[conn] = struct{}{}
+
+	return &Session{
+		rl:     rl,
+		roomID: roomID,
+		conn:   conn,
+		room:   room.limiter,
+	}
+}
+
+// CloseSession releases session limits.
+func (rl *Limiter) CloseSession(sl *Session) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	delete(rl.conns, sl.conn)
+	room, ok := rl.rooms[sl.roomID]
+	if !ok {
+		return
+	}
+	if room.refs--; room.refs <= 0 {
+		delete(rl.rooms, sl.roomID)
+	}
+}
+
+// AcquireIP checks and counts concurrent connections from remote ip.
+func (rl *Limiter) AcquireIP(ip string) bool {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	if rl.cfg.MaxConnsPerIP > 0 && rl.ips[ip] >= rl.cfg.MaxConnsPerIP {
+		return false
+	}
+	rl.ips[ip]++
+	return true
+}
+
+// ReleaseIP uncounts connection acquired with AcquireIP.
+func (rl *Limiter) ReleaseIP(ip string) {
+	rl.mx.Lock()
+	defer rl.mx.Unlock()
+
+	if rl.ips[ip]--; rl.ips[ip] <= 0 {
+		delete(rl.ips, ip)
+	}
+}
+
+// Allow checks message against connection and room limits.
+// It returns violated limit scope if message is not allowed.
+// Message rejected by room limit does not use up connection tokens.
+func (sl *Session) Allow(n int) (string, bool) {
+	cancel, ok := sl.conn.reserve(n)
+	if !ok {
+		return "connection", false
+	}
+	if !sl.room.allow(n) {
+		cancel()
+		return "room", false
+	}
+	return "", true
+}
+
+// Action returns configured rate limit violation action.
+func (sl *Session) Action() string {
+	return sl.rl.Action()
+}
+
+// RemoteIP returns client ip of request. If request comes from trusted proxy, client is
+// the rightmost X-Forwarded-For address that is not trusted proxy, or X-Real-IP address.
+func (rl *Limiter) RemoteIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	rl.mx.Lock()
+	proxies := rl.proxies
+	rl.mx.Unlock()
+
+	if !isTrusted(proxies, peer) {
+		return peer
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err = netip.ParseAddr(hop); err != nil {
+				break
+			}
+			if i == 0 || !isTrusted(proxies, hop) {
+				return hop
+			}
+		}
+	}
+	if ip, errP := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); errP == nil {
+		return ip.String()
+	}
+	return peer
+}
+
+func isTrusted(proxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxies parses addresses and CIDRs, invalid ones are skipped
+// since they are rejected by configuration validation.
+func parseProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, s := range proxies {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		} else if addr, errA := netip.ParseAddr(s); errA == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
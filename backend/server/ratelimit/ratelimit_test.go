@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteIP(t *testing.T) {
+	rl := New(Config{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "", "203.0.113.5"},
+		{"untrusted peer headers ignored", "203.0.113.5:1234", []string{"198.51.100.7"}, "198.51.100.9", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"trusted single address", "192.0.2.1:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"proxy chain", "10.1.2.3:1234", []string{"198.51.100.7, 10.0.0.2"}, "", "198.51.100.7"},
+		{"spoofed leftmost", "10.1.2.3:1234", []string{"1.1.1.1, 198.51.100.7"}, "", "198.51.100.7"},
+		{"multiple headers", "10.1.2.3:1234", []string{"1.1.1.1", "198.51.100.7"}, "", "198.51.100.7"},
+		{"all trusted", "10.1.2.3:1234", []string{"10.0.0.3, 10.0.0.2"}, "", "10.0.0.3"},
+		{"malformed hop", "10.1.2.3:1234", []string{"garbage"}, "", "10.1.2.3"},
+		{"real ip", "10.1.2.3:1234", nil, "198.51.100.9", "198.51.100.9"},
+		{"invalid real ip", "10.1.2.3:1234", nil, "garbage", "10.1.2.3"},
+		{"ipv6 proxy", "[2001:db8::1]:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"ipv4 mapped proxy", "[::ffff:10.1.2.3]:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := rl.RemoteIP(r); got != tt.want {
+				t.Errorf("RemoteIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteIPNoProxies(t *testing.T) {
+	rl := New(Config{})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.1.2.3:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	if got := rl.RemoteIP(r); got != "10.1.2.3" {
+		t.Errorf("RemoteIP() = %q, want peer address", got)
+	}
+
+	rl.Update(Config{TrustedProxies: []string{"10.0.0.0/8"}})
+	if got := rl.RemoteIP(r); got != "198.51.100.7" {
+		t.Errorf("RemoteIP() after update = %q, want forwarded address", got)
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name        string
+		msgsPerSec  float64
+		msgsBurst   int
+		bytesPerSec float64
+		bytesBurst  int
+		sizes       []int
+		want        []bool
+	}{
+		{"unlimited", 0, 0, 0, 0, []int{100, 100, 100}, []bool{true, true, true}},
+		{"message burst", 1, 2, 0, 0, []int{1, 1, 1}, []bool{true, true, false}},
+		{"default burst is rate", 2, 0, 0, 0, []int{1, 1, 1}, []bool{true, true, false}},
+		{"byte burst", 0, 0, 10, 10, []int{6, 6, 4}, []bool{true, false, true}},
+		{"larger than byte burst", 0, 0, 10, 10, []int{11}, []bool{false}},
+		{"rejected by bytes keeps message token", 1, 1, 10, 10, []int{11, 5}, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiter(tt.msgsPerSec, tt.msgsBurst, tt.bytesPerSec, tt.bytesBurst)
+			for i, n := range tt.sizes {
+				if got := l.allow(n); got != tt.want[i] {
+					t.Errorf("allow(%d) #%d = %v, want %v", n, i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSessions(t *testing.T) {
+	rl := New(Config{
+		ConnMessagesPerSec: 1, ConnMessagesBurst: 2,
+		RoomMessagesPerSec: 1, RoomMessagesBurst: 3,
+	})
+	a := rl.OpenSession("room")
+	b := rl.OpenSession("room")
+	if a.room != b.room {
+		t.Fatal("sessions of one room have different room limiters")
+	}
+
+	steps := []struct {
+		sess  *Session
+		scope string
+		ok    bool
+	}{
+		{a, "", true},
+		{a, "", true},
+		{a, "connection", false},
+		{b, "", true},
+		{b, "room", false},
+		// b did not spend its connection tokens on rejected message
+		{b, "room", false},
+	}
+	for i, s := range steps {
+		if scope, ok := s.sess.Allow(1); scope != s.scope || ok != s.ok {
+			t.Errorf("step %d: Allow() = %q, %v, want %q, %v", i, scope, ok, s.scope, s.ok)
+		}
+	}
+
+	rl.Update(Config{})
+	if _, ok := b.Allow(1); !ok {
+		t.Error("limits are not updated in existing session")
+	}
+
+	rl.CloseSession(a)
+	rl.CloseSession(b)
+	if len(rl.rooms) != 0 || len(rl.conns) != 0 {
+		t.Errorf("limiters are left after sessions are closed: %d rooms, %d conns", len(rl.rooms), len(rl.conns))
+	}
+}
+
+func TestRateLimiterIP(t *testing.T) {
+	rl := New(Config{MaxConnsPerIP: 2})
+	steps := []struct {
+		acquire bool
+		ip      string
+		want    bool
+	}{
+		{true, "a", true},
+		{true, "a", true},
+		{true, "a", false},
+		{true, "b", true},
+		{false, "a", true},
+		{true, "a", true},
+	}
+	for i, s := range steps {
+		if !s.acquire {
+			rl.ReleaseIP(s.ip)
+			continue
+		}
+		if got := rl.AcquireIP(s.ip); got != s.want {
+			t.Errorf("step %d: AcquireIP(%q) = %v, want %v", i, s.ip, got, s.want)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/metrics"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/server/ratelimit"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultKeepaliveInterval   = 15 * time.Second
+	defaultReconnectTimeout    = 15 * time.Second
+	defaultPollTimeout         = 25 * time.Second
+	defaultSessionCloseTimeout = 2 * time.Second
+	defaultSendTimeout         = 5 * time.Second
+	defaultBufferSize          = 256
+	defaultMaxMessageSize      = 9000
+
+	reconnectCheckInterval = time.Second
+	retryInterval          = 2 * time.Second
+)
+
+type (
+	SignalingService interface {
+		CreateSignalingSession(context.Context, string, string, model.Wire) error
+		DeleteSignalingSession(context.Context, string, string) error
+	}
+
+	// Config is SSE transport config, zero values are replaced with defaults.
+	Config struct {
+		Logger           *zerolog.Logger
+		SignalingService SignalingService
+
+		// Authenticator identifies participants, user id of signaling path
+		// must belong to authenticated participant. Ids are trusted if it is not set.
+		Authenticator auth.Authenticator
+
+		KeepaliveInterval time.Duration
+		ReconnectTimeout  time.Duration
+		PollTimeout       time.Duration
+		BufferSize        int
+		MaxMessageSize    int64
+
+		// RateLimiter applies flood protection, it can be shared with other transports.
+		// Sessions are not limited if it is not set.
+		RateLimiter *ratelimit.Limiter
+	}
+
+	// Handler implements signaling transport where client receives announcements
+	// with Server-Sent Events or long polling, and sends them with POST requests.
+	Handler struct {
+		logger zerolog.Logger
+		svc    SignalingService
+		auth   auth.Authenticator
+		rl     *ratelimit.Limiter
+
+		keepaliveInterval time.Duration
+		reconnectTimeout  time.Duration
+		pollTimeout       time.Duration
+		bufferSize        int
+		maxMessageSize    int64
+
+		mx       *sync.Mutex
+		sessions map[string]*session
+	}
+
+	pollResponse struct {
+		Events []event            `json:"events"`
+		Closed *model.CloseReason `json:"closed,omitempty"`
+		Ended  bool               `json:"ended,omitempty"`
+	}
+)
+
+func NewHandler(cfg Config) *Handler {
+	h := &Handler{
+		logger: cfg.Logger.With().Str("component", "sse").Logger(),
+		svc:    cfg.SignalingService,
+		auth:   cfg.Authenticator,
+		rl:     cfg.RateLimiter,
+
+		keepaliveInterval: cfg.KeepaliveInterval,
+		reconnectTimeout:  cfg.ReconnectTimeout,
+		pollTimeout:       cfg.PollTimeout,
+		bufferSize:        cfg.BufferSize,
+		maxMessageSize:    cfg.MaxMessageSize,
+
+		mx:       &sync.Mutex{},
+		sessions: make(map[string]*session),
+	}
+	if h.keepaliveInterval == 0 {
+		h.keepaliveInterval = defaultKeepaliveInterval
+	}
+	if h.reconnectTimeout == 0 {
+		h.reconnectTimeout = defaultReconnectTimeout
+	}
+	if h.pollTimeout == 0 {
+		h.pollTimeout = defaultPollTimeout
+	}
+	if h.bufferSize == 0 {
+		h.bufferSize = defaultBufferSize
+	}
+	if h.maxMessageSize == 0 {
+		h.maxMessageSize = defaultMaxMessageSize
+	}
+	if h.auth == nil {
+		h.auth = auth.Anonymous{}
+	}
+	if h.rl == nil {
+		h.rl = ratelimit.New(ratelimit.Config{})
+	}
+	return h
+}
+
+// Routes returns ServeMux patterns and handlers of SSE transport.
+func (h *Handler) Routes() map[string]http.Handler {
+	return map[string]http.Handler{
+		"GET /signal/room/{roomID}/user/{userID}/events":  h.authorized(h.events),
+		"POST /signal/room/{roomID}/user/{userID}/events": h.authorized(h.send),
+		"GET /signal/room/{roomID}/user/{userID}/poll":    h.authorized(h.poll),
+	}
+}
+
+// authorized rejects requests that are not authenticated as participant of signaling path.
+func (h *Handler) authorized(next func(http.ResponseWriter, *http.Request, *auth.Identity)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := h.auth.Authenticate(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err = auth.ResolveUserID(id, r.PathValue("userID")); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next(w, r, id)
+	})
+}
+
+// events streams announcements as Server-Sent Events.
+// Client reconnects with Last-Event-ID header to receive missed events.
+func (h *Handler) events(w http.ResponseWriter, r *http.Request, id *auth.Identity) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sess, lastID, ok := h.attach(w, r, id, r.Header.Get("Last-Event-ID"))
+	if !ok {
+		return
+	}
+	defer sess.detach()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", retryInterval.Milliseconds()); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(h.keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		events, changed, done, reason := sess.since(lastID)
+		for _, ev := range events {
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, ev.Data); err != nil {
+				return
+			}
+			lastID = ev.ID
+		}
+		if done {
+			if reason != nil {
+				b, _ := json.Marshal(reason)
+				_, _ = fmt.Fprintf(w, "event: close\ndata: %s\n\n", b)
+			}
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		case <-keepalive.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// poll is long polling fallback, it waits for announcements after last_event_id.
+func (h *Handler) poll(w http.ResponseWriter, r *http.Request, id *auth.Identity) {
+	sess, lastID, ok := h.attach(w, r, id, r.URL.Query().Get("last_event_id"))
+	if !ok {
+		return
+	}
+	defer sess.detach()
+
+	timer := time.NewTimer(h.pollTimeout)
+	defer timer.Stop()
+
+	var resp pollResponse
+PollLoop:
+	for {
+		events, changed, done, reason := sess.since(lastID)
+		if len(events) > 0 || done {
+			resp = pollResponse{Events: events, Closed: reason, Ended: done}
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timer.C:
+			break PollLoop
+		case <-changed:
+		}
+	}
+	if resp.Events == nil {
+		resp.Events = []event{}
+	}
+	writeJSON(w, http.StatusOK, &resp)
+}
+
+// send passes announcement from client to signaling session.
+func (h *Handler) send(w http.ResponseWriter, r *http.Request, _ *auth.Identity) {
+	sess := h.lookup(r.PathValue("roomID"), r.PathValue("userID"))
+	if sess == nil || sess.wait() != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var ann model.Announcement
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxMessageSize))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err = json.Unmarshal(body, &ann); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if scope, ok := sess.limits.Allow(len(body)); !ok {
+		action := sess.limits.Action()
+		h.logger.Warn().
+			Str("roomID", sess.roomID).
+			Str("userID", sess.userID).
+			Str("scope", scope).
+			Str("action", action).
+			Msg("rate limit exceeded")
+		switch action {
+		case ratelimit.ActionDisconnect:
+			sess.wire.Terminate(ratelimit.CloseRateLimited)
+		case ratelimit.ActionWarn:
+			warn := model.Announcement{
+				Type:    model.AnnouncementTypeRateLimited,
+				Payload: map[string]string{"scope": scope},
+			}
+			select {
+			case sess.wire.TX <- warn:
+			case <-sess.ctx.Done():
+			case <-r.Context().Done():
+			}
+		}
+		writeJSON(w, http.StatusTooManyRequests, &ratelimit.CloseRateLimited)
+		return
+	}
+	ann.SRC = sess.userID
+
+	timer := time.NewTimer(defaultSendTimeout)
+	defer timer.Stop()
+	select {
+	case sess.wire.RX <- ann:
+		w.WriteHeader(http.StatusAccepted)
+	case <-sess.ctx.Done():
+		w.WriteHeader(http.StatusGone)
+	case <-r.Context().Done():
+	case <-timer.C:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// attach finds existing session or creates new one, session expires with credential
+// of latest request. Error response is written if session cannot be created.
+func (h *Handler) attach(w http.ResponseWriter, r *http.Request, id *auth.Identity, lastEventID string) (*session, uint64, bool) {
+	roomID := r.PathValue("roomID")
+	userID := r.PathValue("userID")
+	if roomID == "" || userID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, 0, false
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return nil, 0, false
+		}
+		lastID = id
+	}
+
+	key := sessionKey(roomID, userID)
+	h.mx.Lock()
+	sess, ok := h.sessions[key]
+	if !ok {
+		// placeholder keeps concurrent requests from creating one more session,
+		// signaling service is called without handler lock
+		sess = newSession(roomID, userID, h.bufferSize)
+		h.sessions[key] = sess
+	}
+	h.mx.Unlock()
+
+	if ok {
+		if err := sess.wait(); err != nil {
+			reason := model.CloseReasonOf(err)
+			writeJSON(w, http.StatusForbidden, &reason)
+			return nil, 0, false
+		}
+		sess.attach(id.ExpiresAt)
+		return sess, lastID, true
+	}
+
+	// session counts against connection cap of client ip for its whole lifetime
+	sess.ip = h.rl.RemoteIP(r)
+	if !h.rl.AcquireIP(sess.ip) {
+		h.logger.Warn().Str("ip", sess.ip).Msg("too many concurrent connections")
+		err := model.NewCloseError(ratelimit.CloseRateLimited, errors.New("too many concurrent connections"))
+		h.discard(key, sess, err)
+		writeJSON(w, http.StatusTooManyRequests, &ratelimit.CloseRateLimited)
+		return nil, 0, false
+	}
+	sess.limits = h.rl.OpenSession(roomID)
+
+	if err := h.svc.CreateSignalingSession(sess.ctx, roomID, userID, sess.wire); err != nil {
+		h.discard(key, sess, err)
+		h.rl.CloseSession(sess.limits)
+		h.rl.ReleaseIP(sess.ip)
+
+		h.logger.Error().Err(err).Msg("failed to create signaling session")
+		reason := model.CloseReasonOf(err)
+		metrics.SessionsClosed.WithLabelValues(strconv.Itoa(reason.Code)).Inc()
+		writeJSON(w, http.StatusForbidden, &reason)
+		return nil, 0, false
+	}
+	h.logger.Debug().
+		Str("roomID", roomID).
+		Str("userID", userID).
+		Msg("signaling session created")
+
+	sess.attach(id.ExpiresAt)
+	sess.ready(nil)
+	go h.run(sess)
+	// new session does not have anything to replay
+	return sess, 0, true
+}
+
+// discard removes placeholder of session that could not be created.
+func (h *Handler) discard(key string, sess *session, err error) {
+	h.mx.Lock()
+	delete(h.sessions, key)
+	h.mx.Unlock()
+	sess.cancel()
+	sess.ready(err)
+}
+
+func (h *Handler) lookup(roomID, userID string) *session {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return h.sessions[sessionKey(roomID, userID)]
+}
+
+// run buffers outbound announcements until session is terminated by server
+// or abandoned by client.
+func (h *Handler) run(sess *session) {
+	logger := h.logger.With().
+		Str("roomID", sess.roomID).
+		Str("userID", sess.userID).
+		Logger()
+
+	ticker := time.NewTicker(reconnectCheckInterval)
+	defer ticker.Stop()
+
+	var reason *model.CloseReason
+RunLoop:
+	for {
+		select {
+		case ann := <-sess.wire.TX:
+			b, err := json.Marshal(&ann)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to marshall outgoing message")
+				continue
+			}
+			sess.push(b)
+		case r := <-sess.wire.Close:
+			reason = &r
+			logger.Info().
+				Int("code", r.Code).
+				Str("reason", r.Text).
+				Msg("session is terminated by server")
+			break RunLoop
+		case <-ticker.C:
+			if sess.expired() {
+				expired := model.CloseAuthExpired
+				reason = &expired
+				logger.Info().Msg("session credential expired")
+				break RunLoop
+			}
+			if sess.abandoned(h.reconnectTimeout) {
+				logger.Debug().Msg("client did not reconnect in time")
+				break RunLoop
+			}
+		}
+	}
+
+	h.mx.Lock()
+	delete(h.sessions, sessionKey(sess.roomID, sess.userID))
+	h.mx.Unlock()
+
+	sess.cancel()
+	sess.finish(reason)
+	h.rl.CloseSession(sess.limits)
+	h.rl.ReleaseIP(sess.ip)
+	code := 0
+	if reason != nil {
+		code = reason.Code
+	}
+	metrics.SessionsClosed.WithLabelValues(strconv.Itoa(code)).Inc()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), defaultSessionCloseTimeout)
+	defer cancel()
+	if err := h.svc.DeleteSignalingSession(ctx, sess.roomID, sess.userID); err != nil {
+		logger.Error().Err(err).Msg("failed to delete signaling session")
+		return
+	}
+	logger.Debug().Msg("signaling session ended")
+}
+
+func sessionKey(roomID, userID string) string {
+	return roomID + "/" + userID
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(code)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,306 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/server/ratelimit"
+	"github.com/rs/zerolog"
+)
+
+type testService struct {
+	wires chan model.Wire
+
+	mx      sync.Mutex
+	refused map[string]error
+	held    string
+	release chan struct{}
+}
+
+func (ts *testService) CreateSignalingSession(_ context.Context, _, userID string, wire model.Wire) error {
+	ts.mx.Lock()
+	err, held, release := ts.refused[userID], ts.held == userID, ts.release
+	ts.mx.Unlock()
+	if err != nil {
+		return err
+	}
+	ts.wires <- wire
+	if held {
+		<-release
+	}
+	return nil
+}
+
+// refuse makes service refuse sessions of user with err, nil err lets user in again.
+func (ts *testService) refuse(userID string, err error) {
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+	ts.refused[userID] = err
+}
+
+// hold makes session creation of user wait until returned channel is closed.
+func (ts *testService) hold(userID string) chan struct{} {
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+	ts.held, ts.release = userID, make(chan struct{})
+	return ts.release
+}
+
+func (ts *testService) DeleteSignalingSession(context.Context, string, string) error {
+	return nil
+}
+
+type testAuthenticator struct {
+	expiresAt time.Time
+}
+
+func (ta testAuthenticator) Authenticate(*http.Request) (*auth.Identity, error) {
+	return &auth.Identity{UserID: "alice", ExpiresAt: ta.expiresAt}, nil
+}
+
+// newTestServer serves handler with test signaling service, cfg sets optional parameters.
+func newTestServer(t *testing.T, cfg Config) (*httptest.Server, *testService) {
+	t.Helper()
+	logger := zerolog.Nop()
+	svc := &testService{wires: make(chan model.Wire, 10), refused: make(map[string]error)}
+	cfg.Logger = &logger
+	cfg.SignalingService = svc
+	cfg.PollTimeout = time.Second
+	h := NewHandler(cfg)
+	mux := http.NewServeMux()
+	for pattern, handler := range h.Routes() {
+		mux.Handle(pattern, handler)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, svc
+}
+
+func TestPollReplay(t *testing.T) {
+	srv, svc := newTestServer(t, Config{})
+	poll := func(lastEventID string) []string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/signal/room/room/user/alice/poll?last_event_id=" + lastEventID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var pr pollResponse
+		if err = json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+			t.Fatal(err)
+		}
+		types := make([]string, 0, len(pr.Events))
+		for _, ev := range pr.Events {
+			var ann model.Announcement
+			if err = json.Unmarshal(ev.Data, &ann); err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, ann.Type)
+		}
+		return types
+	}
+
+	// first poll creates session and returns as soon as something is sent
+	sent := make(chan struct{})
+	go func() {
+		wire := <-svc.wires
+		for _, typ := range []string{"offer", "answer", "candidate"} {
+			wire.TX <- model.Announcement{Type: typ}
+		}
+		close(sent)
+	}()
+	if types := poll(""); len(types) == 0 || types[0] != "offer" {
+		t.Fatalf("first poll = %v, want events from offer", types)
+	}
+	<-sent
+	time.Sleep(50 * time.Millisecond) // last announcement is being buffered
+
+	if types := poll("0"); len(types) != 3 {
+		t.Errorf("poll from start = %v, want all events", types)
+	}
+	if types := poll("1"); len(types) != 2 || types[0] != "answer" {
+		t.Errorf("poll after first event = %v, want answer and candidate", types)
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	srv, _ := newTestServer(t, Config{
+		Authenticator: testAuthenticator{expiresAt: time.Now().Add(500 * time.Millisecond)},
+	})
+
+	resp, err := http.Get(srv.URL + "/signal/room/room/user/alice/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	done := make(chan string)
+	go func() {
+		b := new(strings.Builder)
+		buf := make([]byte, 1024)
+		for {
+			n, errR := resp.Body.Read(buf)
+			b.Write(buf[:n])
+			if errR != nil {
+				done <- b.String()
+				return
+			}
+		}
+	}()
+	select {
+	case stream := <-done:
+		if !strings.Contains(stream, "event: close") || !strings.Contains(stream, `"code":4009`) {
+			t.Errorf("stream did not end with auth expired close event:\n%s", stream)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("session did not expire")
+	}
+}
+
+// pollStatus polls announcements of user and returns response status, zero if request failed.
+func pollStatus(srv *httptest.Server, userID string) (int, []byte) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(srv.URL + "/signal/room/room/user/" + userID + "/poll")
+	if err != nil {
+		return 0, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func TestAttachWhileCreating(t *testing.T) {
+	srv, svc := newTestServer(t, Config{})
+	release := svc.hold("alice")
+
+	codes := make(chan int, 2)
+	for range 2 {
+		go func() {
+			code, _ := pollStatus(srv, "alice")
+			codes <- code
+		}()
+	}
+	<-svc.wires // alice session is being created
+
+	if code, _ := pollStatus(srv, "bob"); code != http.StatusOK {
+		t.Errorf("poll of bob = %d while alice session is created, want %d", code, http.StatusOK)
+	}
+	close(release)
+	for range 2 {
+		if code := <-codes; code != http.StatusOK {
+			t.Errorf("poll of alice = %d, want %d", code, http.StatusOK)
+		}
+	}
+	// bob session is the only one created after alice's
+	if n := len(svc.wires); n != 1 {
+		t.Errorf("%d more sessions are created, want 1", n)
+	}
+}
+
+func TestAttachRefused(t *testing.T) {
+	srv, svc := newTestServer(t, Config{})
+	svc.refuse("alice", model.NewCloseError(model.CloseBanned, errors.New("banned")))
+
+	code, body := pollStatus(srv, "alice")
+	var reason model.CloseReason
+	_ = json.Unmarshal(body, &reason)
+	if code != http.StatusForbidden || reason != model.CloseBanned {
+		t.Errorf("poll = %d %s, want %d with banned reason", code, body, http.StatusForbidden)
+	}
+
+	// refused session is not kept
+	svc.refuse("alice", nil)
+	if code, body = pollStatus(srv, "alice"); code != http.StatusOK {
+		t.Errorf("poll after refusal = %d %s, want %d", code, body, http.StatusOK)
+	}
+}
+
+func TestConnsPerIP(t *testing.T) {
+	srv, svc := newTestServer(t, Config{
+		RateLimiter: ratelimit.New(ratelimit.Config{MaxConnsPerIP: 1}),
+	})
+	if code, _ := pollStatus(srv, "alice"); code != http.StatusOK {
+		t.Fatalf("poll of alice = %d, want %d", code, http.StatusOK)
+	}
+	<-svc.wires
+
+	// requests of existing session are not counted again
+	if code, _ := pollStatus(srv, "alice"); code != http.StatusOK {
+		t.Errorf("second poll of alice = %d, want %d", code, http.StatusOK)
+	}
+	code, body := pollStatus(srv, "bob")
+	var reason model.CloseReason
+	_ = json.Unmarshal(body, &reason)
+	if code != http.StatusTooManyRequests || reason != ratelimit.CloseRateLimited {
+		t.Errorf("poll of bob = %d %s, want %d with rate limited reason", code, body, http.StatusTooManyRequests)
+	}
+	if n := len(svc.wires); n != 0 {
+		t.Errorf("%d sessions are created over the cap, want 0", n)
+	}
+}
+
+func TestSendRateLimited(t *testing.T) {
+	srv, svc := newTestServer(t, Config{
+		RateLimiter: ratelimit.New(ratelimit.Config{
+			Action:             ratelimit.ActionDisconnect,
+			ConnMessagesPerSec: 0.001,
+			ConnMessagesBurst:  1,
+		}),
+	})
+	if code, _ := pollStatus(srv, "alice"); code != http.StatusOK {
+		t.Fatalf("poll of alice = %d, want %d", code, http.StatusOK)
+	}
+	wire := <-svc.wires
+	go func() {
+		for range wire.RX {
+		}
+	}()
+
+	send := func() int {
+		t.Helper()
+		resp, err := http.Post(srv.URL+"/signal/room/room/user/alice/events", "application/json",
+			strings.NewReader(`{"type":"offer"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	// client waits for close event while sending
+	polled := make(chan []byte)
+	go func() {
+		_, body := pollStatus(srv, "alice")
+		polled <- body
+	}()
+	time.Sleep(100 * time.Millisecond) // poll is attached
+	if code := send(); code != http.StatusAccepted {
+		t.Fatalf("first send = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := send(); code != http.StatusTooManyRequests {
+		t.Errorf("second send = %d, want %d", code, http.StatusTooManyRequests)
+	}
+
+	body := <-polled
+	var pr pollResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		t.Fatal(err)
+	}
+	if pr.Closed == nil || *pr.Closed != ratelimit.CloseRateLimited {
+		t.Errorf("poll after violation = %s, want session closed as rate limited", body)
+	}
+}
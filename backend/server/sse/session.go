@@ -0,0 +1,154 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/server/ratelimit"
+)
+
+// event is outbound announcement with sequence id.
+type event struct {
+	ID   uint64          `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+// session is signaling session that outlives single http request,
+// so client can reconnect and continue from last received event.
+type session struct {
+	roomID string
+	userID string
+	wire   model.Wire
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mx         *sync.Mutex
+	events     []event
+	maxEvents  int
+	nextID     uint64
+	changed    chan struct{}
+	attached   int
+	detachedAt time.Time
+	reason     *model.CloseReason
+	done       bool
+
+	// ip and limits are rate limiting state of session
+	ip     string
+	limits *ratelimit.Session
+
+	// expiresAt is expiration of client credential, zero if it does not expire
+	expiresAt time.Time
+
+	// created is closed when signaling service has accepted or refused session
+	created   chan struct{}
+	createErr error
+}
+
+func newSession(roomID, userID string, maxEvents int) *session {
+	ctx, cancel := context.WithCancel(context.TODO()) // long-living wire context
+	return &session{
+		roomID:     roomID,
+		userID:     userID,
+		wire:       model.NewWire(),
+		ctx:        ctx,
+		cancel:     cancel,
+		mx:         &sync.Mutex{},
+		maxEvents:  maxEvents,
+		nextID:     1,
+		changed:    make(chan struct{}),
+		detachedAt: time.Now(),
+		created:    make(chan struct{}),
+	}
+}
+
+// ready marks session creation as finished, err is set if session is refused.
+func (s *session) ready(err error) {
+	s.createErr = err
+	close(s.created)
+}
+
+// wait blocks until session creation is finished and returns its error.
+func (s *session) wait() error {
+	<-s.created
+	return s.createErr
+}
+
+func (s *session) push(data []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.events = append(s.events, event{ID: s.nextID, Data: data})
+	s.nextID++
+	if len(s.events) > s.maxEvents {
+		s.events = s.events[len(s.events)-s.maxEvents:]
+	}
+	s.notify()
+}
+
+// finish marks session as ended, reason is nil if session is ended not by server.
+func (s *session) finish(reason *model.CloseReason) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.done {
+		return
+	}
+	s.done = true
+	s.reason = reason
+	s.notify()
+}
+
+// notify wakes up all waiting streams, must be called with lock held.
+func (s *session) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns buffered events after provided id and channel
+// which is closed when something is changed.
+func (s *session) since(lastID uint64) ([]event, <-chan struct{}, bool, *model.CloseReason) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	var events []event
+	for i, ev := range s.events {
+		if ev.ID > lastID {
+			events = s.events[i:]
+			break
+		}
+	}
+	return events, s.changed, s.done, s.reason
+}
+
+// attach registers client request, its credential expiration replaces previous one,
+// so client can prolong session by reconnecting with renewed credential.
+func (s *session) attach(expiresAt time.Time) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.attached++
+	s.expiresAt = expiresAt
+}
+
+func (s *session) detach() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.attached--
+	s.detachedAt = time.Now()
+}
+
+// expired reports whether client credential has expired.
+func (s *session) expired() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return !s.expiresAt.IsZero() && time.Now().After(s.expiresAt)
+}
+
+// abandoned reports whether client has not been attached longer than timeout.
+func (s *session) abandoned(timeout time.Duration) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.attached == 0 && time.Since(s.detachedAt) > timeout
+}
@@ -0,0 +1,61 @@
+package sse
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+func TestSessionSince(t *testing.T) {
+	sess := newSession("room", "alice", 3)
+	for _, data := range []string{`1`, `2`, `3`, `4`, `5`} {
+		sess.push([]byte(data))
+	}
+
+	tests := []struct {
+		name    string
+		lastID  uint64
+		wantIDs []uint64
+	}{
+		{"new client", 0, []uint64{3, 4, 5}},
+		{"events were dropped", 1, []uint64{3, 4, 5}},
+		{"missed events", 3, []uint64{4, 5}},
+		{"up to date", 5, nil},
+		{"unknown id", 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, _, done, _ := sess.since(tt.lastID)
+			if done {
+				t.Error("session is done")
+			}
+			if len(events) != len(tt.wantIDs) {
+				t.Fatalf("since() = %d events, want %d", len(events), len(tt.wantIDs))
+			}
+			for i, ev := range events {
+				if ev.ID != tt.wantIDs[i] || string(ev.Data) != strconv.FormatUint(ev.ID, 10) {
+					t.Errorf("event %d = %d %s, want %d", i, ev.ID, ev.Data, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSessionFinish(t *testing.T) {
+	sess := newSession("room", "alice", 3)
+	_, changed, _, _ := sess.since(0)
+
+	kicked := model.CloseKicked
+	sess.finish(&kicked)
+	sess.finish(nil)
+	select {
+	case <-changed:
+	default:
+		t.Error("waiting streams are not notified")
+	}
+	_, _, done, reason := sess.since(0)
+	if !done || reason == nil || reason.Code != model.CloseCodeKicked {
+		t.Errorf("since() = done %v reason %v, want first close reason", done, reason)
+	}
+}
@@ -0,0 +1,55 @@
+package static
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const indexFile = "index.html"
+
+func init() {
+	// not every system mime table has it
+	_ = mime.AddExtensionType(".ico", "image/x-icon")
+}
+
+// NewHandler serves single page application assets under prefix.
+// Requests for missing files are answered with index.html.
+func NewHandler(assets fs.FS, prefix string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServerFS(assets))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(strings.TrimPrefix(r.URL.Path, prefix)), "/")
+		if name == "" || name == "." {
+			serveIndex(w, r, assets)
+			return
+		}
+		fi, err := fs.Stat(assets, name)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && fi.IsDir()) {
+			serveIndex(w, r, assets)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// FileHandler serves single file from assets.
+func FileHandler(assets fs.FS, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, assets, name)
+	})
+}
+
+func serveIndex(w http.ResponseWriter, r *http.Request, assets fs.FS) {
+	b, err := fs.ReadFile(assets, indexFile)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,54 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestHandler(t *testing.T) {
+	assets := fstest.MapFS{
+		"index.html":   {Data: []byte("index")},
+		"index.js":     {Data: []byte("script")},
+		"img/logo.ico": {Data: []byte("icon")},
+	}
+	h := NewHandler(assets, "/peerchat")
+
+	tests := []struct {
+		name     string
+		path     string
+		wantBody string
+		wantType string // not checked if empty, system mime tables differ
+	}{
+		{"root", "/peerchat/", "index", "text/html; charset=utf-8"},
+		{"asset", "/peerchat/index.js", "script", ""},
+		{"nested asset", "/peerchat/img/logo.ico", "icon", "image/x-icon"},
+		{"directory", "/peerchat/img/", "index", "text/html; charset=utf-8"},
+		{"missing file", "/peerchat/room/myroom", "index", "text/html; charset=utf-8"},
+		{"path traversal", "/peerchat/../../etc/passwd", "index", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if body := w.Body.String(); body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if ct := w.Header().Get("Content-Type"); tt.wantType != "" && ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestHandlerNoIndex(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(fstest.MapFS{}, "/peerchat").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/peerchat/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
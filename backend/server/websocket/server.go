@@ -2,15 +2,24 @@ package websocket
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/metrics"
 	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/server/cors"
+	"github.com/adwski/webrtc-playground/backend/server/ratelimit"
+	"github.com/adwski/webrtc-playground/backend/tracing"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -25,7 +34,6 @@ const (
 	defaultWebSocketCloseWriteDeadline = 2 * time.Second
 	defaultWebSocketWriteDeadline      = 5 * time.Second
 
-	// defaultPongWait - defaultPingInterval == is how long we give client to respond
 	defaultPingInterval = 5 * time.Second
 	defaultPongWait     = 7 * time.Second
 )
@@ -34,16 +42,44 @@ var (
 	ErrUnexpected = errors.New("unexpected server error")
 )
 
+var tracer = otel.Tracer("github.com/adwski/webrtc-playground/backend/server/websocket")
+
 type (
 	SignalingService interface {
 		CreateSignalingSession(context.Context, string, string, model.Wire) error
 		DeleteSignalingSession(context.Context, string, string) error
 	}
 
+	// Config is signaling server config, zero values are replaced with defaults.
 	Config struct {
 		Logger           *zerolog.Logger
 		SignalingService SignalingService
 		ListenAddr       string
+		CORS             cors.Config
+		TLS              *tls.Config
+
+		// RateLimiter applies flood protection, it can be shared with other transports.
+		// Sessions are not limited if it is not set.
+		RateLimiter *ratelimit.Limiter
+
+		// Authenticator identifies participants, user id of signaling path
+		// must belong to authenticated participant. Ids are trusted if it is not set.
+		Authenticator auth.Authenticator
+
+		// Routes are additional signaling handlers mounted on server mux,
+		// keys are ServeMux patterns. Origin policy is applied to them.
+		Routes map[string]http.Handler
+
+		ShutdownTimeout     time.Duration
+		SessionCloseTimeout time.Duration
+		ReadBufferSize      int
+		WriteBufferSize     int
+		MaxMessageSize      int64
+		HandshakeTimeout    time.Duration
+		WriteTimeout        time.Duration
+		CloseWriteTimeout   time.Duration
+		PingInterval        time.Duration
+		PongWait            time.Duration
 	}
 
 	Server struct {
@@ -51,32 +87,89 @@ type (
 		ws  *websocket.Upgrader
 		*http.Server
 
+		rl       *ratelimit.Limiter
+		cors     *cors.Policy
+		auth     auth.Authenticator
+		sessions *sync.WaitGroup
+		params   *connParams
+
+		shutdownTimeout     time.Duration
+		sessionCloseTimeout time.Duration
+
 		logger zerolog.Logger
 	}
+
+	// connParams are websocket connection settings.
+	connParams struct {
+		maxMessageSize    int64
+		writeTimeout      time.Duration
+		closeWriteTimeout time.Duration
+		// pongWait - pingInterval == is how long we give client to respond
+		pingInterval time.Duration
+		pongWait     time.Duration
+	}
 )
 
 func NewServer(cfg Config) *Server {
 	srv := &Server{
 		logger: cfg.Logger.With().Str("component", "websocket-server").Logger(),
 		svc:    cfg.SignalingService,
+		rl:     cfg.RateLimiter,
+		cors:   cors.NewPolicy(cfg.CORS, "signaling", cfg.Logger),
+		auth:   cfg.Authenticator,
+
+		sessions: &sync.WaitGroup{},
+		params: &connParams{
+			maxMessageSize:    orDefault(cfg.MaxMessageSize, defaultWebSocketMaxMessageSize),
+			writeTimeout:      orDefault(cfg.WriteTimeout, defaultWebSocketWriteDeadline),
+			closeWriteTimeout: orDefault(cfg.CloseWriteTimeout, defaultWebSocketCloseWriteDeadline),
+			pingInterval:      orDefault(cfg.PingInterval, defaultPingInterval),
+			pongWait:          orDefault(cfg.PongWait, defaultPongWait),
+		},
+		shutdownTimeout:     orDefault(cfg.ShutdownTimeout, defaultShutdownDeadline),
+		sessionCloseTimeout: orDefault(cfg.SessionCloseTimeout, defaultSignalingSessionCloseTimeout),
 		ws: &websocket.Upgrader{
-			HandshakeTimeout: defaultWebSocketHandshakeTimeout,
-			ReadBufferSize:   defaultWebsocketReadBufferSize,
-			WriteBufferSize:  defaultWebsocketWriteBufferSize,
-			CheckOrigin:      func(r *http.Request) bool { return true },
+			HandshakeTimeout: orDefault(cfg.HandshakeTimeout, defaultWebSocketHandshakeTimeout),
+			ReadBufferSize:   orDefault(cfg.ReadBufferSize, defaultWebsocketReadBufferSize),
+			WriteBufferSize:  orDefault(cfg.WriteBufferSize, defaultWebsocketWriteBufferSize),
 		},
 	}
 
+	if srv.auth == nil {
+		srv.auth = auth.Anonymous{}
+	}
+	if srv.rl == nil {
+		srv.rl = ratelimit.New(ratelimit.Config{})
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/signal/room/{roomID}/user/{userID}", srv.signal)
+	for pattern, h := range cfg.Routes {
+		mux.Handle(pattern, srv.cors.Handler(h))
+	}
 
 	srv.Server = &http.Server{
-		Addr:    cfg.ListenAddr,
-		Handler: mux,
+		Addr:      cfg.ListenAddr,
+		Handler:   mux,
+		TLSConfig: cfg.TLS,
 	}
+	srv.ws.CheckOrigin = srv.cors.CheckOrigin
 	return srv
 }
 
+// SetCORS updates origin policy.
+func (srv *Server) SetCORS(cfg cors.Config) {
+	srv.cors.Update(cfg)
+}
+
+func orDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
 func (srv *Server) Run(ctx context.Context, wg *sync.WaitGroup, errc chan<- error) {
 	defer func() {
 		srv.logger.Debug().Msg("server stopped")
@@ -85,10 +178,14 @@ func (srv *Server) Run(ctx context.Context, wg *sync.WaitGroup, errc chan<- erro
 
 	errSrv := make(chan error)
 	go func() {
-		errSrv <- srv.ListenAndServe()
+		if srv.TLSConfig != nil {
+			errSrv <- srv.ListenAndServeTLS("", "")
+		} else {
+			errSrv <- srv.ListenAndServe()
+		}
 	}()
 
-	srv.logger.Info().Str("addr", srv.Addr).Msg("server started")
+	srv.logger.Info().Str("addr", srv.Addr).Bool("tls", srv.TLSConfig != nil).Msg("server started")
 
 	select {
 	case err := <-errSrv:
@@ -96,11 +193,28 @@ func (srv *Server) Run(ctx context.Context, wg *sync.WaitGroup, errc chan<- erro
 			errc <- errors.Join(ErrUnexpected, err)
 		}
 	case <-ctx.Done():
-		shCtx, shCancel := context.WithTimeout(context.Background(), defaultShutdownDeadline)
+		shCtx, shCancel := context.WithTimeout(context.Background(), srv.shutdownTimeout)
 		defer shCancel()
 		if err := srv.Shutdown(shCtx); err != nil {
 			srv.logger.Error().Err(err).Msg("server shutdown failed")
 		}
+		srv.WaitSessions(shCtx)
+	}
+}
+
+// WaitSessions waits for hijacked websocket connections to finish.
+// Run calls it during shutdown, it should be called explicitly
+// if server handler is mounted elsewhere.
+func (srv *Server) WaitSessions(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		srv.sessions.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		srv.logger.Warn().Msg("signaling sessions did not finish in time")
 	}
 }
 
@@ -111,24 +225,55 @@ func (srv *Server) signal(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	id, err := srv.auth.Authenticate(r)
+	if err != nil {
+		srv.logger.Debug().Err(err).Str("roomID", roomID).Str("userID", userID).Msg("unauthenticated signaling request")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if _, err = auth.ResolveUserID(id, userID); err != nil {
+		srv.logger.Debug().Err(err).Str("roomID", roomID).Str("userID", userID).Msg("signaling request rejected")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	spanCtx, span := tracer.Start(tracing.Extract(r), "signal",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			tracing.AttrRoomID.String(roomID),
+			tracing.AttrUserID.String(userID),
+		))
+	defer span.End()
+
+	ip := srv.rl.RemoteIP(r)
+	if !srv.rl.AcquireIP(ip) {
+		srv.logger.Warn().Str("ip", ip).Msg("too many concurrent connections")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
 
 	conn, err := srv.ws.Upgrade(w, r, nil)
 	if err != nil {
 		srv.logger.Error().Err(err).Msg("websocket upgrade failed")
+		tracing.RecordError(span, err)
 		w.WriteHeader(http.StatusBadRequest)
+		srv.rl.ReleaseIP(ip)
 		return
 	}
 
 	wire := model.NewWire()
 
-	ctx, cancel := context.WithCancel(context.TODO()) // long-living wire context
+	// long-living wire context, it carries only span context of upgrade request,
+	// so session activity is traced as its children
+	ctx, cancel := context.WithCancel(trace.ContextWithSpanContext(context.TODO(), trace.SpanContextFromContext(spanCtx)))
 
 	err = srv.svc.CreateSignalingSession(ctx, roomID, userID, wire)
 	if err != nil {
 		srv.logger.Error().Err(err).Msg("failed to create signaling session")
-		w.WriteHeader(http.StatusInternalServerError)
+		tracing.RecordError(span, err)
 		cancel()
-		webSocketCloser(conn, &srv.logger)
+		webSocketCloser(conn, model.CloseReasonOf(err), srv.params, &srv.logger)
+		srv.rl.ReleaseIP(ip)
 		return
 	}
 	srv.logger.Debug().
@@ -136,11 +281,27 @@ func (srv *Server) signal(w http.ResponseWriter, r *http.Request) {
 		Str("userID", userID).
 		Msg("signaling session created")
 
-	go srv.handleWSConn(ctx, cancel, conn, roomID, userID, wire)
+	var expiry *time.Timer
+	if !id.ExpiresAt.IsZero() {
+		// session cannot outlive credential it is authenticated with
+		expiry = time.AfterFunc(time.Until(id.ExpiresAt), func() {
+			wire.Terminate(model.CloseAuthExpired)
+		})
+	}
+
+	srv.sessions.Add(1)
+	go func() {
+		srv.handleWSConn(ctx, cancel, conn, roomID, userID, wire)
+		if expiry != nil {
+			expiry.Stop()
+		}
+		srv.rl.ReleaseIP(ip)
+		srv.sessions.Done()
+	}()
 }
 
 func (srv *Server) destroySession(roomID, userID string, logger *zerolog.Logger) {
-	ctx, cancel := context.WithDeadline(context.TODO(), time.Now().Add(defaultSignalingSessionCloseTimeout))
+	ctx, cancel := context.WithDeadline(context.TODO(), time.Now().Add(srv.sessionCloseTimeout))
 	defer cancel()
 	err := srv.svc.DeleteSignalingSession(ctx, roomID, userID)
 	if err != nil {
@@ -161,7 +322,13 @@ func (srv *Server) handleWSConn(
 	userID string,
 	wire model.Wire,
 ) {
-	wg := &sync.WaitGroup{}
+	var (
+		wg       = &sync.WaitGroup{}
+		rxReason model.CloseReason
+		txReason model.CloseReason
+		limits   = srv.rl.OpenSession(roomID)
+	)
+	defer srv.rl.CloseSession(limits)
 
 	logger := srv.logger.With().
 		Str("roomID", roomID).
@@ -170,16 +337,22 @@ func (srv *Server) handleWSConn(
 
 	wg.Add(2)
 	go func() {
-		webSocketReceiver(ctx, wg, conn, userID, wire.RX, &logger)
+		rxReason = webSocketReceiver(ctx, wg, conn, userID, wire, limits, srv.params, &logger)
 		cancel()
 	}()
 	go func() {
-		webSocketSender(ctx, wg, conn, wire.TX, &logger)
+		txReason = webSocketSender(ctx, wg, conn, wire, srv.params, &logger)
 		cancel()
+		// unblock receiver since it cannot be interrupted by context
+		_ = conn.SetReadDeadline(time.Now())
 	}()
 
 	wg.Wait()
-	webSocketCloser(conn, &logger)
+	reason := txReason
+	if reason.Code == 0 {
+		reason = rxReason
+	}
+	webSocketCloser(conn, reason, srv.params, &logger)
 	srv.destroySession(roomID, userID, &logger)
 }
 
@@ -187,10 +360,11 @@ func webSocketSender(
 	ctx context.Context,
 	wg *sync.WaitGroup,
 	conn *websocket.Conn,
-	tx <-chan model.Announcement,
+	wire model.Wire,
+	params *connParams,
 	logger *zerolog.Logger,
-) {
-	pingTicker := time.NewTicker(defaultPingInterval)
+) (reason model.CloseReason) {
+	pingTicker := time.NewTicker(params.pingInterval)
 	defer func() {
 		pingTicker.Stop()
 		wg.Done()
@@ -200,8 +374,14 @@ SendLoop:
 		select {
 		case <-ctx.Done():
 			break SendLoop
+		case reason = <-wire.Close:
+			logger.Debug().
+				Int("code", reason.Code).
+				Str("reason", reason.Text).
+				Msg("session is terminated by server")
+			break SendLoop
 		case <-pingTicker.C:
-			wsErr := conn.SetWriteDeadline(time.Now().Add(defaultWebSocketWriteDeadline))
+			wsErr := conn.SetWriteDeadline(time.Now().Add(params.writeTimeout))
 			if wsErr != nil {
 				logger.Error().Err(wsErr).Msg("failed to set websocket write deadline")
 				break SendLoop
@@ -212,7 +392,7 @@ SendLoop:
 			}
 			logger.Trace().Msg("ping sent")
 
-		case msg, ok := <-tx:
+		case msg, ok := <-wire.TX:
 			if !ok {
 				break SendLoop
 			}
@@ -223,7 +403,7 @@ SendLoop:
 				break SendLoop
 			}
 
-			wsErr = conn.SetWriteDeadline(time.Now().Add(defaultWebSocketWriteDeadline))
+			wsErr = conn.SetWriteDeadline(time.Now().Add(params.writeTimeout))
 			if wsErr != nil {
 				logger.Error().Err(wsErr).Msg("failed to set websocket write deadline")
 				break SendLoop
@@ -245,6 +425,7 @@ SendLoop:
 			}
 		}
 	}
+	return reason
 }
 
 func webSocketReceiver(
@@ -252,23 +433,25 @@ func webSocketReceiver(
 	wg *sync.WaitGroup,
 	conn *websocket.Conn,
 	userID string,
-	rx chan<- model.Announcement,
+	wire model.Wire,
+	limits *ratelimit.Session,
+	params *connParams,
 	logger *zerolog.Logger,
-) {
+) (reason model.CloseReason) {
 	defer wg.Done()
 
-	conn.SetReadLimit(defaultWebSocketMaxMessageSize)
+	conn.SetReadLimit(params.maxMessageSize)
 	readDeadLineFunc := func(deadline time.Duration) error {
 		return conn.SetReadDeadline(time.Now().Add(deadline))
 	}
 	conn.SetPongHandler(func(string) error {
 		logger.Trace().Msg("got pong")
-		return readDeadLineFunc(defaultPongWait)
+		return readDeadLineFunc(params.pongWait)
 	})
-	err := readDeadLineFunc(defaultPongWait)
+	err := readDeadLineFunc(params.pongWait)
 	if err != nil {
 		logger.Error().Err(err).Msg("failed to set websocket read deadline")
-		return
+		return reason
 	}
 
 RecvLoop:
@@ -279,6 +462,9 @@ RecvLoop:
 		default:
 			_, msg, wsErr := conn.ReadMessage()
 			if wsErr != nil {
+				if ctx.Err() != nil {
+					break RecvLoop
+				}
 				if websocket.IsCloseError(wsErr,
 					websocket.CloseNormalClosure,
 					websocket.CloseGoingAway) {
@@ -289,27 +475,58 @@ RecvLoop:
 				break RecvLoop
 			}
 
+			if scope, ok := limits.Allow(len(msg)); !ok {
+				action := limits.Action()
+				logger.Warn().Str("scope", scope).Str("action", action).Msg("rate limit exceeded")
+				switch action {
+				case ratelimit.ActionDisconnect:
+					reason = ratelimit.CloseRateLimited
+					break RecvLoop
+				case ratelimit.ActionWarn:
+					warn := model.Announcement{
+						Type:    model.AnnouncementTypeRateLimited,
+						Payload: map[string]string{"scope": scope},
+					}
+					select {
+					case wire.TX <- warn:
+					case <-ctx.Done():
+						break RecvLoop
+					}
+				}
+				continue
+			}
+
 			var ann model.Announcement
 			if wsErr = json.Unmarshal(msg, &ann); wsErr != nil {
 				logger.Error().Err(wsErr).Msg("failed to unmarshall incoming message")
 			} else {
 				ann.SRC = userID
 				select {
-				case rx <- ann:
+				case wire.RX <- ann:
 				case <-ctx.Done():
 					break RecvLoop
 				}
 			}
 		}
 	}
+	return reason
 }
 
-func webSocketCloser(conn *websocket.Conn, logger *zerolog.Logger) {
-	wsErr := conn.SetWriteDeadline(time.Now().Add(defaultWebSocketCloseWriteDeadline))
+func webSocketCloser(conn *websocket.Conn, reason model.CloseReason, params *connParams, logger *zerolog.Logger) {
+	msg := []byte{}
+	if reason.Code != 0 {
+		msg = websocket.FormatCloseMessage(reason.Code, reason.Text)
+		logger.Info().
+			Int("code", reason.Code).
+			Str("reason", reason.Text).
+			Msg("closing websocket connection")
+	}
+	metrics.SessionsClosed.WithLabelValues(strconv.Itoa(reason.Code)).Inc()
+	wsErr := conn.SetWriteDeadline(time.Now().Add(params.closeWriteTimeout))
 	if wsErr != nil {
 		logger.Error().Err(wsErr).Msg("failed to set websocket write deadline during closing")
 	} else {
-		wsErr = conn.WriteMessage(websocket.CloseMessage, []byte{})
+		wsErr = conn.WriteMessage(websocket.CloseMessage, msg)
 		if wsErr != nil {
 			logger.Error().Err(wsErr).Msg("failed to close websocket connection")
 		}
@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/auth"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+)
+
+type testService struct{}
+
+func (testService) CreateSignalingSession(context.Context, string, string, model.Wire) error {
+	return nil
+}
+
+func (testService) DeleteSignalingSession(context.Context, string, string) error {
+	return nil
+}
+
+type testAuthenticator struct {
+	expiresAt time.Time
+}
+
+func (ta testAuthenticator) Authenticate(*http.Request) (*auth.Identity, error) {
+	return &auth.Identity{UserID: "alice", ExpiresAt: ta.expiresAt}, nil
+}
+
+func newTestServer(t *testing.T, cfg Config) string {
+	t.Helper()
+	logger := zerolog.Nop()
+	cfg.Logger = &logger
+	cfg.SignalingService = testService{}
+	srv := httptest.NewServer(NewServer(cfg).Handler)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// readClose reads connection until it is closed and returns close code.
+func readClose(t *testing.T, conn *websocket.Conn, timeout time.Duration) int {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				return ce.Code
+			}
+			t.Fatalf("connection is not closed properly: %v", err)
+		}
+	}
+}
+
+func TestSessionExpires(t *testing.T) {
+	url := newTestServer(t, Config{
+		Authenticator: testAuthenticator{expiresAt: time.Now().Add(300 * time.Millisecond)},
+	})
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/signal/room/room/user/alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if code := readClose(t, conn, 3*time.Second); code != model.CloseCodeAuthExpired {
+		t.Errorf("close code = %d, want %d", code, model.CloseCodeAuthExpired)
+	}
+}
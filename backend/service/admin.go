@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+var (
+	ErrKick      = errors.New("unable to kick participant")
+	ErrCloseRoom = errors.New("unable to close room")
+)
+
+type (
+	// RoomFilter selects rooms in ListRooms. Zero Limit means no limit.
+	RoomFilter struct {
+		Prefix          string
+		MinParticipants int
+		Offset          int
+		Limit           int
+	}
+
+	RoomSummary struct {
+		ID           string `json:"room_id"`
+		Participants int    `json:"participants"`
+		Connected    int    `json:"connected"`
+	}
+
+	RoomDetails struct {
+		ID           string              `json:"room_id"`
+		Host         string              `json:"host"`
+		Locked       bool                `json:"locked"`
+		Type         string              `json:"type"`
+		Participants []model.RosterEntry `json:"participants"`
+		Lobby        []string            `json:"lobby"`
+	}
+
+	// Notice is payload of server notice announcement.
+	Notice struct {
+		Text string `json:"text"`
+	}
+)
+
+// ListRooms returns page of rooms sorted by id and total number of matched rooms.
+func (svc *Service) ListRooms(filter RoomFilter) ([]RoomSummary, int) {
+	var matched []RoomSummary
+	for _, room := range svc.store.ListRooms() {
+		if !strings.HasPrefix(room.ID, filter.Prefix) || len(room.Participants) < filter.MinParticipants {
+			continue
+		}
+		matched = append(matched, RoomSummary{
+			ID:           room.ID,
+			Participants: len(room.Participants),
+			Connected:    len(svc.sw.Endpoints(room.ID)),
+		})
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := len(matched)
+	if filter.Offset >= total {
+		return []RoomSummary{}, total
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, total
+}
+
+// GetRoomDetails returns room participants and their signaling session status.
+func (svc *Service) GetRoomDetails(roomID string) (*RoomDetails, error) {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return nil, errors.Join(ErrGet, err)
+	}
+	connected := make(map[string]bool)
+	for _, userID := range svc.sw.Endpoints(roomID) {
+		connected[userID] = true
+	}
+
+	details := &RoomDetails{
+		ID:           room.ID,
+		Host:         room.Host,
+		Locked:       room.Locked,
+		Type:         room.Type,
+		Participants: make([]model.RosterEntry, 0, len(room.Participants)),
+		Lobby:        make([]string, 0, len(room.Lobby)),
+	}
+	for userID := range room.Lobby {
+		details.Lobby = append(details.Lobby, userID)
+	}
+	sort.Strings(details.Lobby)
+	for userID, p := range room.Participants {
+		details.Participants = append(details.Participants, model.RosterEntry{
+			Participant: p,
+			Connected:   connected[userID],
+		})
+	}
+	sort.Slice(details.Participants, func(i, j int) bool {
+		return details.Participants[i].ID < details.Participants[j].ID
+	})
+	return details, nil
+}
+
+// KickParticipant removes participant from room and ends its signaling session.
+func (svc *Service) KickParticipant(roomID, userID, reason string) error {
+	if err := svc.store.RemoveParticipant(roomID, userID); err != nil {
+		return errors.Join(ErrKick, err)
+	}
+	closeReason := model.CloseKicked
+	if reason != "" {
+		closeReason = closeReason.WithText(reason)
+	}
+	svc.updateBroadcaster(roomID)
+	// participant may not have signaling session at the moment
+	_ = svc.sw.Terminate(roomID, userID, closeReason)
+
+	svc.logger.Info().
+		Str("userID", userID).
+		Str("roomID", roomID).
+		Str("reason", reason).
+		Msg("participant kicked")
+	svc.events.Publish(model.NewEvent(model.EventTypeParticipantKicked, roomID, userID, nil))
+	// host might be changed
+	go svc.announceRoomState(context.Background(), roomID)
+	return nil
+}
+
+// CloseRoom deletes room and ends signaling sessions of its participants
+// and of users waiting in its lobby.
+func (svc *Service) CloseRoom(roomID, reason string) error {
+	if err := svc.store.DeleteRoom(roomID); err != nil {
+		return errors.Join(ErrCloseRoom, err)
+	}
+	closeReason := model.CloseRoomClosed
+	if reason != "" {
+		closeReason = closeReason.WithText(reason)
+	}
+	svc.sw.TerminateInstance(roomID, closeReason)
+	svc.terminateRoomLobby(roomID, closeReason)
+	svc.closeBreakouts(roomID, reason)
+
+	svc.logger.Info().
+		Str("roomID", roomID).
+		Str("reason", reason).
+		Msg("room closed")
+	svc.events.Publish(model.NewEvent(model.EventTypeRoomClosed, roomID, "", nil))
+	return nil
+}
+
+// SendNotice broadcasts server notice to every connected participant of room.
+func (svc *Service) SendNotice(ctx context.Context, roomID, text string) error {
+	if _, err := svc.store.GetRoom(roomID); err != nil {
+		return errors.Join(ErrGet, err)
+	}
+	ann := model.Announcement{
+		Type:    model.AnnouncementTypeNotice,
+		Payload: &Notice{Text: text},
+	}
+	return svc.sw.Broadcast(ctx, ann, roomID)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestListRooms(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 3})
+	for roomID, users := range map[string][]string{
+		"team-a": {"alice", "bob"},
+		"team-b": {"carol"},
+		"team-c": {"dave", "erin", "frank"},
+		"other":  {"grace"},
+	} {
+		for _, userID := range users {
+			if _, err := svc.JoinRoom(ctx, roomID, userID, JoinParams{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	connectTest(t, svc, "team-a", "alice")
+
+	tests := []struct {
+		name      string
+		filter    RoomFilter
+		wantRooms []string
+		wantTotal int
+	}{
+		{"all", RoomFilter{}, []string{"other", "team-a", "team-b", "team-c"}, 4},
+		{"prefix", RoomFilter{Prefix: "team-"}, []string{"team-a", "team-b", "team-c"}, 3},
+		{"min participants", RoomFilter{MinParticipants: 2}, []string{"team-a", "team-c"}, 2},
+		{"page", RoomFilter{Prefix: "team-", Offset: 1, Limit: 1}, []string{"team-b"}, 3},
+		{"offset past end", RoomFilter{Offset: 10}, []string{}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rooms, total := svc.ListRooms(tt.filter)
+			if total != tt.wantTotal {
+				t.Errorf("ListRooms() total = %d, want %d", total, tt.wantTotal)
+			}
+			if len(rooms) != len(tt.wantRooms) {
+				t.Fatalf("ListRooms() = %+v, want %v", rooms, tt.wantRooms)
+			}
+			for i, room := range rooms {
+				if room.ID != tt.wantRooms[i] {
+					t.Errorf("room %d = %s, want %s", i, room.ID, tt.wantRooms[i])
+				}
+				if wantConnected := map[string]int{"team-a": 1}[room.ID]; room.Connected != wantConnected {
+					t.Errorf("%s connected = %d, want %d", room.ID, room.Connected, wantConnected)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminEndsSessions(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		act      func(svc *Service) error
+		wantCode int
+		wantText string
+	}{
+		{
+			name:     "kick",
+			act:      func(svc *Service) error { return svc.KickParticipant("room", "alice", "") },
+			wantCode: model.CloseCodeKicked,
+			wantText: model.CloseKicked.Text,
+		},
+		{
+			name:     "kick with reason",
+			act:      func(svc *Service) error { return svc.KickParticipant("room", "alice", "bye") },
+			wantCode: model.CloseCodeKicked,
+			wantText: "bye",
+		},
+		{
+			name:     "close room",
+			act:      func(svc *Service) error { return svc.CloseRoom("room", "") },
+			wantCode: model.CloseCodeRoomClosed,
+			wantText: model.CloseRoomClosed.Text,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestService(t, memory.Config{})
+			for _, userID := range []string{"host", "alice"} {
+				if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			wire, _ := connectTest(t, svc, "room", "alice")
+			if err := tt.act(svc); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case reason := <-wire.Close:
+				if reason.Code != tt.wantCode || reason.Text != tt.wantText {
+					t.Errorf("close reason = %+v, want %d %s", reason, tt.wantCode, tt.wantText)
+				}
+			case <-time.After(time.Second):
+				t.Error("session is not ended")
+			}
+		})
+	}
+}
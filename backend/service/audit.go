@@ -0,0 +1,22 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+type noopAuditor struct{}
+
+func (noopAuditor) Audit(model.AuditEvent) {}
+
+// audit writes event with outcome of err, error replaces event reason.
+func (svc *Service) audit(ev model.AuditEvent, err error) {
+	ev.Outcome = model.AuditSuccess
+	if err != nil {
+		ev.Outcome = model.AuditFailure
+		// joined errors are multiline
+		ev.Reason = strings.ReplaceAll(err.Error(), "\n", ": ")
+	}
+	svc.auditor.Audit(ev)
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+// breakout rooms are closed with delay after participants are moved back,
+// so move announcement is delivered before signaling sessions are ended
+const breakoutCloseDelay = 5 * time.Second
+
+// breakoutIDSize is size of random part of breakout room id
+const breakoutIDSize = 4
+
+var (
+	ErrBreakouts        = errors.New("unable to start breakouts")
+	ErrBreakoutsActive  = errors.New("room already has breakouts")
+	ErrNoBreakouts      = errors.New("room has no breakouts")
+	ErrInvalidBreakouts = errors.New("invalid breakout assignment")
+	ErrBreakoutExists   = errors.New("breakout room id is taken")
+	ErrBreakoutRoom     = errors.New("breakout room can be joined only by assignment")
+)
+
+type (
+	// Breakouts are breakout rooms of parent room, EndsAt is set if they are ended by timer.
+	Breakouts struct {
+		Rooms  []BreakoutRoom `json:"rooms"`
+		EndsAt *time.Time     `json:"ends_at,omitempty"`
+	}
+
+	BreakoutRoom struct {
+		ID           string   `json:"room_id"`
+		Participants []string `json:"participants"`
+	}
+
+	activeBreakouts struct {
+		Breakouts
+		timer *time.Timer
+	}
+)
+
+// StartBreakouts splits room into breakout rooms on behalf of room host. Every assignment
+// is list of participants of one breakout room, breakout room ids are <room>-<random hex>,
+// so they cannot be taken in advance.
+// Assigned participants stay members of parent room and receive move announcement.
+// Everyone is moved back after duration unless it is zero.
+func (svc *Service) StartBreakouts(ctx context.Context, roomID, hostID string, assignments [][]string, duration time.Duration) (_ *Breakouts, err error) {
+	defer func() {
+		svc.audit(model.AuditEvent{
+			Action: model.AuditActionModerate,
+			Actor:  hostID,
+			RoomID: roomID,
+			Detail: "breakouts_start",
+		}, err)
+	}()
+
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return nil, errors.Join(ErrBreakouts, ErrGet, err)
+	}
+	if room.Host != hostID {
+		return nil, ErrNotHost
+	}
+	if err = validateAssignments(room, assignments, duration); err != nil {
+		return nil, err
+	}
+
+	svc.breakoutMx.Lock()
+	if _, ok := svc.breakouts[roomID]; ok {
+		svc.breakoutMx.Unlock()
+		return nil, ErrBreakoutsActive
+	}
+	active := &activeBreakouts{}
+	for _, users := range assignments {
+		var childID string
+		if childID, err = newBreakoutID(roomID); err == nil {
+			err = svc.createBreakout(room, childID, users)
+		}
+		if err != nil {
+			for _, br := range active.Rooms {
+				_ = svc.store.DeleteRoom(br.ID)
+			}
+			svc.breakoutMx.Unlock()
+			return nil, errors.Join(ErrBreakouts, err)
+		}
+		active.Rooms = append(active.Rooms, BreakoutRoom{ID: childID, Participants: users})
+	}
+	if duration > 0 {
+		endsAt := time.Now().Add(duration)
+		active.EndsAt = &endsAt
+		active.timer = time.AfterFunc(duration, func() {
+			_ = svc.endBreakouts(context.Background(), roomID, active)
+		})
+	}
+	svc.breakouts[roomID] = active
+	svc.breakoutMx.Unlock()
+
+	svc.logger.Debug().
+		Str("roomID", roomID).
+		Int("rooms", len(active.Rooms)).
+		Dur("duration", duration).
+		Msg("breakouts started")
+	svc.events.Publish(model.NewEvent(model.EventTypeBreakoutsStarted, roomID, "", &active.Breakouts))
+	for _, br := range active.Rooms {
+		for _, userID := range br.Participants {
+			svc.move(ctx, roomID, userID, br.ID)
+		}
+	}
+	return &active.Breakouts, nil
+}
+
+// EndBreakouts moves participants of breakout rooms back to parent room
+// on behalf of its host and closes breakout rooms.
+func (svc *Service) EndBreakouts(ctx context.Context, roomID, hostID string) (err error) {
+	defer func() {
+		svc.audit(model.AuditEvent{
+			Action: model.AuditActionModerate,
+			Actor:  hostID,
+			RoomID: roomID,
+			Detail: "breakouts_end",
+		}, err)
+	}()
+
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return errors.Join(ErrGet, err)
+	}
+	if room.Host != hostID {
+		return ErrNotHost
+	}
+	return svc.endBreakouts(ctx, roomID, nil)
+}
+
+// endBreakouts ends active breakouts of room, if only is set they are ended only if they
+// are still active, so timer of ended breakouts does not end ones started after them.
+func (svc *Service) endBreakouts(ctx context.Context, roomID string, only *activeBreakouts) error {
+	active := svc.takeBreakouts(roomID, only)
+	if active == nil {
+		return ErrNoBreakouts
+	}
+	for _, br := range active.Rooms {
+		room, err := svc.store.GetRoom(br.ID)
+		if err != nil {
+			continue
+		}
+		for userID := range room.Participants {
+			svc.move(ctx, br.ID, userID, roomID)
+		}
+		childID := br.ID
+		time.AfterFunc(breakoutCloseDelay, func() {
+			_ = svc.CloseRoom(childID, "breakout ended")
+		})
+	}
+	svc.logger.Debug().
+		Str("roomID", roomID).
+		Msg("breakouts ended")
+	svc.events.Publish(model.NewEvent(model.EventTypeBreakoutsEnded, roomID, "", nil))
+	return nil
+}
+
+// closeBreakouts closes breakout rooms of closed parent room.
+func (svc *Service) closeBreakouts(roomID, reason string) {
+	active := svc.takeBreakouts(roomID, nil)
+	if active == nil {
+		return
+	}
+	for _, br := range active.Rooms {
+		_ = svc.CloseRoom(br.ID, reason)
+	}
+}
+
+// takeBreakouts removes active breakouts of room and stops their timer.
+// If only is set, breakouts are removed only if they are the same.
+func (svc *Service) takeBreakouts(roomID string, only *activeBreakouts) *activeBreakouts {
+	svc.breakoutMx.Lock()
+	defer svc.breakoutMx.Unlock()
+
+	active, ok := svc.breakouts[roomID]
+	if !ok || (only != nil && active != only) {
+		return nil
+	}
+	delete(svc.breakouts, roomID)
+	if active.timer != nil {
+		active.timer.Stop()
+	}
+	return active
+}
+
+// breakoutOf returns breakout room that participant of parent room is assigned to.
+func (svc *Service) breakoutOf(roomID, userID string) string {
+	svc.breakoutMx.Lock()
+	defer svc.breakoutMx.Unlock()
+
+	if active, ok := svc.breakouts[roomID]; ok {
+		for _, br := range active.Rooms {
+			for _, id := range br.Participants {
+				if id == userID {
+					return br.ID
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// createBreakout creates breakout room with assigned participants at once, so room
+// is not limited by capacity of its own. Participants keep profile and viewer role
+// of parent room.
+func (svc *Service) createBreakout(parent *model.Room, roomID string, users []string) error {
+	if _, err := svc.store.GetRoom(roomID); err == nil {
+		return ErrBreakoutExists
+	}
+	room := &model.Room{
+		ID:           roomID,
+		Participants: make(map[string]model.Participant, len(users)),
+		Host:         breakoutHost(parent, users),
+		DefaultRole:  model.RolePublisher,
+		Type:         model.RoomTypeConference,
+		Parent:       parent.ID,
+	}
+	for _, userID := range users {
+		p := parent.Participants[userID]
+		role := model.RolePublisher
+		switch {
+		case userID == room.Host:
+			role = model.RoleHost
+		case p.Role == model.RoleViewer:
+			role = model.RoleViewer
+		}
+		room.Participants[userID] = model.Participant{ID: userID, Role: role, Profile: p.Profile}
+	}
+	return svc.store.CreateRoom(room)
+}
+
+func newBreakoutID(parentID string) (string, error) {
+	b := make([]byte, breakoutIDSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return parentID + "-" + hex.EncodeToString(b), nil
+}
+
+// breakoutHost returns host of breakout room: parent room host if it is assigned,
+// otherwise first assigned participant that is not a viewer.
+func breakoutHost(parent *model.Room, users []string) string {
+	var host string
+	for _, userID := range users {
+		if userID == parent.Host {
+			return userID
+		}
+		if host == "" && parent.Participants[userID].Role != model.RoleViewer {
+			host = userID
+		}
+	}
+	return host
+}
+
+// move instructs participant to reconnect its signaling session to another room.
+func (svc *Service) move(ctx context.Context, fromRoomID, userID, toRoomID string) {
+	// participant may not have signaling session at the moment
+	_ = svc.sw.Send(ctx, model.Announcement{
+		DST:     userID,
+		Type:    model.AnnouncementTypeMove,
+		Payload: &model.MovePayload{RoomID: toRoomID},
+	}, fromRoomID)
+}
+
+func validateAssignments(room *model.Room, assignments [][]string, duration time.Duration) error {
+	if room.Parent != "" || len(assignments) == 0 || duration < 0 {
+		return ErrInvalidBreakouts
+	}
+	assigned := make(map[string]struct{})
+	for _, users := range assignments {
+		if len(users) == 0 {
+			return ErrInvalidBreakouts
+		}
+		for _, userID := range users {
+			if _, ok := room.Participants[userID]; !ok {
+				return fmt.Errorf("%w: %s is not a participant", ErrInvalidBreakouts, userID)
+			}
+			if _, ok := assigned[userID]; ok {
+				return fmt.Errorf("%w: %s is assigned twice", ErrInvalidBreakouts, userID)
+			}
+			assigned[userID] = struct{}{}
+		}
+		// viewer cannot host breakout room
+		if breakoutHost(room, users) == "" {
+			return fmt.Errorf("%w: breakout room has only viewers", ErrInvalidBreakouts)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+// newBreakoutTestService returns service with room joined by host, publishers p1, p2, p3
+// and viewers v1, v2. Capacity is then lowered below number of publishers.
+func newBreakoutTestService(t *testing.T) *Service {
+	t.Helper()
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 4})
+	for _, userID := range []string{"host", "p1", "p2", "p3"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, userID := range []string{"v1", "v2"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{Viewer: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc.store.(*memory.MemStore).SetMaxParticipants(2)
+	return svc
+}
+
+func TestStartBreakouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		hostID      string
+		assignments [][]string
+		duration    time.Duration
+		takenRoom   string
+		wantErr     error
+		wantHosts   []string
+	}{
+		{
+			name:        "not host",
+			hostID:      "p1",
+			assignments: [][]string{{"p1", "p2"}},
+			wantErr:     ErrNotHost,
+		},
+		{
+			name:        "no assignments",
+			hostID:      "host",
+			assignments: [][]string{},
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "empty breakout room",
+			hostID:      "host",
+			assignments: [][]string{{"p1"}, {}},
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "not a participant",
+			hostID:      "host",
+			assignments: [][]string{{"p1", "alice"}},
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "assigned twice",
+			hostID:      "host",
+			assignments: [][]string{{"p1", "p2"}, {"p3", "p1"}},
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "only viewers",
+			hostID:      "host",
+			assignments: [][]string{{"p1"}, {"v1", "v2"}},
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "negative duration",
+			hostID:      "host",
+			assignments: [][]string{{"p1"}},
+			duration:    -time.Second,
+			wantErr:     ErrInvalidBreakouts,
+		},
+		{
+			name:        "predictable room id taken",
+			hostID:      "host",
+			assignments: [][]string{{"p1", "p2"}, {"p3"}},
+			takenRoom:   "room-1",
+			wantHosts:   []string{"p1", "p3"},
+		},
+		{
+			name:        "parent host is preferred",
+			hostID:      "host",
+			assignments: [][]string{{"v1", "p1", "p2", "host"}, {"v2", "p3"}},
+			wantHosts:   []string{"host", "p3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newBreakoutTestService(t)
+			wantRooms := 1
+			if tt.takenRoom != "" {
+				if _, err := svc.JoinRoom(ctx, tt.takenRoom, "alice", JoinParams{}); err != nil {
+					t.Fatal(err)
+				}
+				wantRooms++
+			}
+			breakouts, err := svc.StartBreakouts(ctx, "room", tt.hostID, tt.assignments, tt.duration)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StartBreakouts() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if rooms := svc.store.ListRooms(); len(rooms) != wantRooms {
+					t.Errorf("rooms after failed start = %d, want %d", len(rooms), wantRooms)
+				}
+				return
+			}
+			for i, br := range breakouts.Rooms {
+				room, errGet := svc.store.GetRoom(br.ID)
+				if errGet != nil {
+					t.Fatal(errGet)
+				}
+				if room.Host != tt.wantHosts[i] {
+					t.Errorf("%s host = %s, want %s", br.ID, room.Host, tt.wantHosts[i])
+				}
+				if len(room.Participants) != len(tt.assignments[i]) {
+					t.Errorf("%s participants = %d, want %d", br.ID, len(room.Participants), len(tt.assignments[i]))
+				}
+				for _, userID := range []string{"v1", "v2"} {
+					if p, ok := room.Participants[userID]; ok && p.Role != model.RoleViewer {
+						t.Errorf("%s role of %s = %s, want %s", br.ID, userID, p.Role, model.RoleViewer)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBreakoutsTimer(t *testing.T) {
+	ctx := context.Background()
+	svc := newBreakoutTestService(t)
+	breakouts, err := svc.StartBreakouts(ctx, "room", "host", [][]string{{"p1", "p2"}}, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breakouts.EndsAt == nil {
+		t.Error("EndsAt is not set")
+	}
+	if _, err = svc.StartBreakouts(ctx, "room", "host", [][]string{{"p3"}}, 0); !errors.Is(err, ErrBreakoutsActive) {
+		t.Errorf("StartBreakouts() error = %v, want %v", err, ErrBreakoutsActive)
+	}
+	_, received := connectTest(t, svc, breakouts.Rooms[0].ID, "p1")
+
+	ann := waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeMove))
+	if ann == nil {
+		t.Fatal("participant is not moved back")
+	}
+	if roomID := ann.Payload.(*model.MovePayload).RoomID; roomID != "room" {
+		t.Errorf("moved to %s, want room", roomID)
+	}
+	if err = svc.EndBreakouts(ctx, "room", "host"); !errors.Is(err, ErrNoBreakouts) {
+		t.Errorf("EndBreakouts() error = %v, want %v", err, ErrNoBreakouts)
+	}
+}
+
+func TestEndBreakouts(t *testing.T) {
+	ctx := context.Background()
+	svc := newBreakoutTestService(t)
+	breakouts, err := svc.StartBreakouts(ctx, "room", "host", [][]string{{"p1", "p2"}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := svc.breakoutOf("room", "p2"), breakouts.Rooms[0].ID; got != want {
+		t.Errorf("breakoutOf() = %s, want %s", got, want)
+	}
+	ended := svc.breakouts["room"]
+	if err = svc.EndBreakouts(ctx, "room", "p1"); !errors.Is(err, ErrNotHost) {
+		t.Errorf("EndBreakouts() error = %v, want %v", err, ErrNotHost)
+	}
+	if err = svc.EndBreakouts(ctx, "room", "host"); err != nil {
+		t.Fatal(err)
+	}
+	if got := svc.breakoutOf("room", "p2"); got != "" {
+		t.Errorf("breakoutOf() after end = %s, want none", got)
+	}
+
+	// ended breakout rooms are not closed yet, new ones do not clash with them
+	if _, err = svc.StartBreakouts(ctx, "room", "host", [][]string{{"p1", "p2"}}, 0); err != nil {
+		t.Errorf("StartBreakouts() right after end error = %v", err)
+	}
+	// timer of ended breakouts could fire before it is stopped
+	if err = svc.endBreakouts(ctx, "room", ended); !errors.Is(err, ErrNoBreakouts) {
+		t.Errorf("endBreakouts() of ended breakouts error = %v, want %v", err, ErrNoBreakouts)
+	}
+	if svc.breakoutOf("room", "p2") == "" {
+		t.Error("new breakouts are ended by timer of ended ones")
+	}
+}
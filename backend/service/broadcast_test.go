@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestBroadcastRoom(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{Type: model.RoomTypeBroadcast}); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []string{"v1", "v2"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, host := connectTest(t, svc, "room", "host")
+	_, v1 := connectTest(t, svc, "room", "v1")
+	if waitAnnouncement(host, time.Second, joinedBy("v1")) == nil {
+		t.Fatal("broadcaster is not told about viewer join")
+	}
+	v2Wire, v2 := connectTest(t, svc, "room", "v2")
+
+	ann := waitAnnouncement(v2, time.Second, ofType(model.AnnouncementTypeRoster))
+	if ann == nil {
+		t.Fatal("viewer did not receive roster")
+	}
+	roster := ann.Payload.(*model.Roster)
+	if len(roster.Participants) != 1 || roster.Participants[0].ID != "host" {
+		t.Errorf("viewer roster = %+v, want only broadcaster", roster.Participants)
+	}
+	if waitAnnouncement(host, time.Second, joinedBy("v2")) == nil {
+		t.Error("broadcaster is not told about viewer join")
+	}
+	if waitAnnouncement(v1, 200*time.Millisecond, joinedBy("v2")) != nil {
+		t.Error("viewer is told about another viewer join")
+	}
+
+	if err := svc.Moderate(ctx, "room", "host", model.AnnouncementTypeTransferHost, model.ModerationPayload{UserID: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	v2Wire.RX <- model.Announcement{SRC: "v2", Type: "candidate"}
+	ann = waitAnnouncement(v1, time.Second, ofType("candidate"))
+	if ann == nil {
+		t.Fatal("viewer announcement did not reach new broadcaster")
+	}
+	if ann.SRC != "v2" || ann.Session == "" {
+		t.Errorf("announcement = %+v, want one from v2 with session", ann)
+	}
+	if waitAnnouncement(host, 200*time.Millisecond, ofType("candidate")) != nil {
+		t.Error("viewer announcement reached former broadcaster")
+	}
+}
+
+func joinedBy(userID string) func(model.Announcement) bool {
+	return func(ann model.Announcement) bool {
+		return ann.Type == model.AnnouncementTypeJoined && ann.SRC == userID
+	}
+}
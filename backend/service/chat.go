@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+const (
+	maxChatMessageLength = 2000
+	maxChatPageLimit     = 100
+)
+
+var (
+	ErrInvalidChat = errors.New("invalid chat message")
+	ErrChat        = errors.New("unable to send chat message")
+	ErrChatHistory = errors.New("unable to get chat history")
+)
+
+// SendChatMessage stores message in room chat history and broadcasts it to room participants
+// including sender.
+func (svc *Service) SendChatMessage(ctx context.Context, roomID, userID, text string) error {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return fmt.Errorf("%w: text must not be empty", ErrInvalidChat)
+	case utf8.RuneCountInString(text) > maxChatMessageLength:
+		return fmt.Errorf("%w: text is longer than %d characters", ErrInvalidChat, maxChatMessageLength)
+	}
+	msg, err := svc.store.AppendChatMessage(roomID, model.ChatMessage{
+		UserID: userID,
+		Text:   text,
+		SentAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return errors.Join(ErrChat, err)
+	}
+	ann := model.Announcement{
+		SRC:     userID,
+		Type:    model.AnnouncementTypeChat,
+		Payload: msg,
+	}
+	if err = svc.sw.Broadcast(ctx, ann, roomID); err != nil {
+		return err
+	}
+	// broadcast skips sender, it gets its message back with assigned id
+	ann.DST = userID
+	_ = svc.sw.Send(ctx, ann, roomID)
+	return nil
+}
+
+// ChatHistory returns page of room messages older than message with before id
+// to room participant, zero before returns latest messages.
+func (svc *Service) ChatHistory(roomID, userID string, before int64, limit int) (*model.ChatHistory, error) {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return nil, errors.Join(ErrChatHistory, ErrGet, err)
+	}
+	if _, ok := room.Participants[userID]; !ok {
+		return nil, ErrNotAMember
+	}
+	if limit <= 0 || limit > maxChatPageLimit {
+		limit = maxChatPageLimit
+	}
+	history, err := svc.store.ChatHistory(roomID, before, limit)
+	if err != nil {
+		return nil, errors.Join(ErrChatHistory, err)
+	}
+	return history, nil
+}
+
+// sendChatHistory replays latest room messages to newly connected participant.
+func (svc *Service) sendChatHistory(ctx context.Context, roomID, userID string) {
+	history, err := svc.store.ChatHistory(roomID, 0, maxChatPageLimit)
+	if err != nil || len(history.Messages) == 0 {
+		return
+	}
+	_ = svc.sw.Send(ctx, model.Announcement{
+		DST:     userID,
+		Type:    model.AnnouncementTypeChatHistory,
+		Payload: history,
+	}, roomID)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestSendChatMessage(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, host := connectTest(t, svc, "room", "host")
+	_, alice := connectTest(t, svc, "room", "alice")
+
+	if err := svc.SendChatMessage(ctx, "room", "alice", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	// sender receives its message too, so it learns message id
+	for name, received := range map[string]<-chan model.Announcement{"host": host, "sender": alice} {
+		ann := waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeChat))
+		if ann == nil {
+			t.Fatalf("%s did not receive message", name)
+		}
+		if msg := ann.Payload.(*model.ChatMessage); msg.ID != 1 || msg.Text != "hello" || msg.UserID != "alice" {
+			t.Errorf("%s received %+v", name, msg)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		roomID  string
+		text    string
+		wantErr error
+	}{
+		{"empty", "room", " \n", ErrInvalidChat},
+		{"too long", "room", strings.Repeat("я", maxChatMessageLength+1), ErrInvalidChat},
+		{"room not found", "other", "hello", ErrChat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.SendChatMessage(ctx, tt.roomID, "alice", tt.text); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SendChatMessage() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChatHistory(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SendChatMessage(ctx, "room", "host", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if history, err := svc.ChatHistory("room", "host", 0, 0); err != nil || len(history.Messages) != 1 {
+		t.Errorf("ChatHistory() = %+v, %v, want single message", history, err)
+	}
+	if _, err := svc.ChatHistory("room", "alice", 0, 0); !errors.Is(err, ErrNotAMember) {
+		t.Errorf("ChatHistory() of non-member error = %v, want %v", err, ErrNotAMember)
+	}
+	if _, err := svc.ChatHistory("other", "host", 0, 0); !errors.Is(err, ErrChatHistory) {
+		t.Errorf("ChatHistory() of missing room error = %v, want %v", err, ErrChatHistory)
+	}
+
+	// history is replayed to connected participant
+	_, received := connectTest(t, svc, "room", "host")
+	if waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeChatHistory)) == nil {
+		t.Error("chat history is not replayed")
+	}
+}
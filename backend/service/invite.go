@@ -0,0 +1,171 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultInviteTTL    = time.Hour
+	defaultInviteMaxTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrPasscode      = errors.New("invalid room passcode")
+	ErrInvalidInvite = errors.New("invalid invite")
+	ErrInvite        = errors.New("unable to create invite")
+	ErrInviteTTL     = errors.New("invite ttl exceeds limit")
+
+	ErrInvalidRoomType = errors.New("invalid room type")
+)
+
+type (
+	InviteIssuer interface {
+		Issue(roomID, roomNonce string, ttl time.Duration, singleUse bool) (string, time.Time, error)
+		Redeem(token, roomID, roomNonce string) error
+	}
+
+	// JoinParams are join request parameters. RequireAdmission, Passcode, DefaultRole and Type
+	// are applied if room is created, otherwise Passcode is checked unless
+	// user has valid invite token. Viewer joins new participant as viewer.
+	JoinParams struct {
+		RequireAdmission bool
+		Passcode         string
+		InviteToken      string
+		Profile          model.Profile
+		DefaultRole      string
+		Type             string
+		Viewer           bool
+	}
+
+	Invite struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+		SingleUse bool      `json:"single_use"`
+	}
+)
+
+// CreateInvite issues invite token for room on behalf of room host.
+// Zero ttl means default ttl.
+func (svc *Service) CreateInvite(roomID, hostID string, ttl time.Duration, singleUse bool) (_ *Invite, err error) {
+	defer func() {
+		svc.audit(model.AuditEvent{Action: model.AuditActionCreateInvite, Actor: hostID, RoomID: roomID}, err)
+	}()
+
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return nil, errors.Join(ErrInvite, ErrGet, err)
+	}
+	if room.Host != hostID {
+		return nil, ErrNotHost
+	}
+	svc.mx.RLock()
+	defaultTTL, maxTTL := svc.inviteTTL, svc.inviteMaxTTL
+	svc.mx.RUnlock()
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	if ttl < 0 || ttl > maxTTL {
+		return nil, ErrInviteTTL
+	}
+	token, expiresAt, err := svc.invites.Issue(roomID, room.Nonce, ttl, singleUse)
+	if err != nil {
+		return nil, errors.Join(ErrInvite, err)
+	}
+	svc.logger.Debug().
+		Str("roomID", roomID).
+		Time("expiresAt", expiresAt).
+		Bool("singleUse", singleUse).
+		Msg("invite created")
+	return &Invite{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		SingleUse: singleUse,
+	}, nil
+}
+
+// SetInviteTTL updates default and max invite ttl.
+func (svc *Service) SetInviteTTL(defaultTTL, maxTTL time.Duration) {
+	svc.mx.Lock()
+	defer svc.mx.Unlock()
+	svc.inviteTTL, svc.inviteMaxTTL = defaultTTL, maxTTL
+}
+
+// joinOptions checks join rights of user, room is nil if it does not exist yet.
+// Passcode is checked against room snapshot, invite token is redeemed by store, see checkAccess.
+func (svc *Service) joinOptions(room *model.Room, userID string, params JoinParams) (model.JoinOptions, error) {
+	// verified is nonce of room which passcode is checked
+	var verified string
+	opts := model.JoinOptions{
+		Profile: params.Profile,
+		Viewer:  params.Viewer,
+		Check: func(room *model.Room) error {
+			return svc.checkAccess(room, params, verified)
+		},
+	}
+	if err := validateProfile(&opts.Profile); err != nil {
+		return opts, err
+	}
+	if room == nil {
+		switch params.DefaultRole {
+		case "", model.RolePublisher, model.RoleViewer:
+		default:
+			return opts, ErrInvalidRole
+		}
+		switch params.Type {
+		case "", model.RoomTypeConference, model.RoomTypeBroadcast:
+		default:
+			return opts, ErrInvalidRoomType
+		}
+		opts.Create.RequireAdmission = params.RequireAdmission
+		opts.Create.DefaultRole = params.DefaultRole
+		opts.Create.Type = params.Type
+		if params.Passcode != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(params.Passcode), bcrypt.DefaultCost)
+			if err != nil {
+				return opts, err
+			}
+			opts.Create.PasscodeHash = hash
+		}
+		return opts, nil
+	}
+	if _, ok := room.Participants[userID]; ok {
+		return opts, nil
+	}
+	if room.Parent != "" {
+		// participants of breakout room are assigned, parent host may visit it
+		if parent, err := svc.store.GetRoom(room.Parent); err != nil || parent.Host != userID {
+			return opts, ErrBreakoutRoom
+		}
+		opts.Admitted = true
+		return opts, nil
+	}
+	if params.InviteToken == "" && len(room.PasscodeHash) > 0 {
+		// bcrypt is slow, so it is not done under store lock
+		if bcrypt.CompareHashAndPassword(room.PasscodeHash, []byte(params.Passcode)) != nil {
+			return opts, ErrPasscode
+		}
+		verified = room.Nonce
+	}
+	opts.Admitted = params.InviteToken != ""
+	return opts, nil
+}
+
+// checkAccess redeems invite token of user joining existing room or makes sure that passcode
+// is verified for this room, not for the one it could be recreated from. It is called
+// by store right before user is added, so single-use invite is not spent if join fails.
+func (svc *Service) checkAccess(room *model.Room, params JoinParams, verified string) error {
+	if params.InviteToken != "" {
+		if err := svc.invites.Redeem(params.InviteToken, room.ID, room.Nonce); err != nil {
+			return errors.Join(ErrInvalidInvite, err)
+		}
+		return nil
+	}
+	if len(room.PasscodeHash) > 0 && room.Nonce != verified {
+		return ErrPasscode
+	}
+	return nil
+}
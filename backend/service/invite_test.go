@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestJoinRoomAccess(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 3})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{Passcode: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.JoinRoom(ctx, "other", "host", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := svc.CreateInvite("room", "host", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.CreateInvite("other", "host", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		params  JoinParams
+		wantErr error
+	}{
+		{"no passcode", "alice", JoinParams{}, ErrPasscode},
+		{"wrong passcode", "alice", JoinParams{Passcode: "wrong"}, ErrPasscode},
+		{"invite of other room", "alice", JoinParams{InviteToken: other.Token}, ErrInvalidInvite},
+		{"passcode", "alice", JoinParams{Passcode: "secret"}, nil},
+		{"participant rejoins", "alice", JoinParams{}, nil},
+		{"invite", "bob", JoinParams{InviteToken: inv.Token}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.JoinRoom(ctx, "room", tt.userID, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("JoinRoom() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJoinRoomSingleUseInvite(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 2})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := svc.CreateInvite("room", "host", 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.JoinRoom(ctx, "room", "alice", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// room is full, so invite must not be spent
+	if _, err = svc.JoinRoom(ctx, "room", "bob", JoinParams{InviteToken: inv.Token}); !errors.Is(err, memory.ErrRoomIsFull) {
+		t.Fatalf("JoinRoom() of full room error = %v, want %v", err, memory.ErrRoomIsFull)
+	}
+	if err = svc.KickParticipant("room", "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.JoinRoom(ctx, "room", "bob", JoinParams{InviteToken: inv.Token}); err != nil {
+		t.Fatalf("JoinRoom() with invite error = %v", err)
+	}
+	if err = svc.KickParticipant("room", "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.JoinRoom(ctx, "room", "carol", JoinParams{InviteToken: inv.Token}); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("JoinRoom() with used invite error = %v, want %v", err, ErrInvalidInvite)
+	}
+}
+
+func TestJoinRecreatedRoom(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 3})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{Passcode: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := svc.CreateInvite("room", "host", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// passcode is verified against snapshot of room that is closed before join
+	snapshot, err := svc.store.GetRoom("room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := svc.joinOptions(snapshot, "alice", JoinParams{Passcode: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = svc.CloseRoom("room", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.JoinRoom(ctx, "room", "mallory", JoinParams{Passcode: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = svc.store.CreateOrJoinRoom("room", "alice", opts); !errors.Is(err, ErrPasscode) {
+		t.Errorf("join with passcode of closed room error = %v, want %v", err, ErrPasscode)
+	}
+	if _, err = svc.JoinRoom(ctx, "room", "bob", JoinParams{InviteToken: inv.Token}); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("JoinRoom() with invite of closed room error = %v, want %v", err, ErrInvalidInvite)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+var (
+	ErrDecide = errors.New("unable to process admission")
+)
+
+// lobbySession is signaling session of user that waits for host admission.
+// It is not connected to switch, so user cannot reach room participants.
+type lobbySession struct {
+	wire     model.Wire
+	decision chan bool
+}
+
+// waitInLobby keeps lobby session until host decides on admission. Admitted user
+// continues with the same session as room participant, denied user is disconnected.
+func (svc *Service) waitInLobby(ctx context.Context, roomID, userID string, wire model.Wire) {
+	ls := &lobbySession{
+		wire:     wire,
+		decision: make(chan bool, 1),
+	}
+	svc.lobbyMx.Lock()
+	if _, ok := svc.lobby[roomID]; !ok {
+		svc.lobby[roomID] = make(map[string]*lobbySession)
+	}
+	svc.lobby[roomID][userID] = ls
+	svc.lobbyMx.Unlock()
+
+	svc.logger.Debug().
+		Str("userID", userID).
+		Str("roomID", roomID).
+		Msg("lobby session connected")
+
+	// host could decide before session is registered
+	if room, err := svc.store.GetRoom(roomID); err != nil {
+		svc.notifyLobby(roomID, userID, false)
+	} else if _, ok := room.Lobby[userID]; !ok {
+		_, admitted := room.Participants[userID]
+		svc.notifyLobby(roomID, userID, admitted)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wire.RX:
+				// lobby user cannot send anything
+			case admitted := <-ls.decision:
+				if !admitted {
+					wire.Terminate(model.CloseAdmissionDenied)
+					continue
+				}
+				select {
+				case wire.TX <- model.Announcement{DST: userID, Type: model.AnnouncementTypeAdmitted}:
+				case <-ctx.Done():
+					return
+				}
+				// admitted session is not a lobby session anymore, it is removed before connect,
+				// so session deletion either finds it in lobby or connected to switch
+				if !svc.leaveLobby(roomID, userID) || ctx.Err() != nil {
+					return
+				}
+				if err := svc.connect(ctx, roomID, userID, wire); err != nil {
+					svc.logger.Error().Err(err).
+						Str("userID", userID).
+						Str("roomID", roomID).
+						Msg("failed to connect admitted user")
+					wire.Terminate(model.CloseReasonOf(err))
+					continue
+				}
+				return
+			}
+		}
+	}()
+}
+
+// decide admits user from lobby or denies admission.
+func (svc *Service) decide(roomID, userID string, admit bool) error {
+	var err error
+	if admit {
+		err = svc.store.Admit(roomID, userID)
+	} else {
+		err = svc.store.Deny(roomID, userID)
+	}
+	if err != nil {
+		return errors.Join(ErrDecide, err)
+	}
+	svc.notifyLobby(roomID, userID, admit)
+
+	typ := model.EventTypeParticipantDenied
+	if admit {
+		typ = model.EventTypeParticipantAdmitted
+	}
+	svc.events.Publish(model.NewEvent(typ, roomID, userID, nil))
+	return nil
+}
+
+// notifyLobby passes host decision to lobby session if user is connected.
+func (svc *Service) notifyLobby(roomID, userID string, admitted bool) {
+	svc.lobbyMx.Lock()
+	ls, ok := svc.lobby[roomID][userID]
+	svc.lobbyMx.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ls.decision <- admitted:
+	default:
+	}
+}
+
+// leaveLobby removes lobby session and reports whether it existed.
+func (svc *Service) leaveLobby(roomID, userID string) bool {
+	svc.lobbyMx.Lock()
+	defer svc.lobbyMx.Unlock()
+
+	if _, ok := svc.lobby[roomID][userID]; !ok {
+		return false
+	}
+	delete(svc.lobby[roomID], userID)
+	if len(svc.lobby[roomID]) == 0 {
+		delete(svc.lobby, roomID)
+	}
+	svc.logger.Debug().
+		Str("userID", userID).
+		Str("roomID", roomID).
+		Msg("lobby session deleted")
+	return true
+}
+
+func (svc *Service) terminateLobbySession(roomID, userID string, reason model.CloseReason) {
+	svc.lobbyMx.Lock()
+	defer svc.lobbyMx.Unlock()
+
+	if ls, ok := svc.lobby[roomID][userID]; ok {
+		ls.wire.Terminate(reason)
+	}
+}
+
+func (svc *Service) terminateRoomLobby(roomID string, reason model.CloseReason) {
+	svc.lobbyMx.Lock()
+	defer svc.lobbyMx.Unlock()
+
+	for _, ls := range svc.lobby[roomID] {
+		ls.wire.Terminate(reason)
+	}
+}
+
+func (svc *Service) terminateLobby(reason model.CloseReason) {
+	svc.lobbyMx.Lock()
+	defer svc.lobbyMx.Unlock()
+
+	for _, room := range svc.lobby {
+		for _, ls := range room {
+			ls.wire.Terminate(reason)
+		}
+	}
+}
+
+// knock notifies room host about users waiting in lobby,
+// if no users are provided, host is notified about every waiting user.
+func (svc *Service) knock(ctx context.Context, roomID string, userIDs ...string) {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil || room.Host == "" {
+		return
+	}
+	if len(userIDs) == 0 {
+		for userID := range room.Lobby {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	for _, userID := range userIDs {
+		_ = svc.sw.Send(ctx, model.Announcement{
+			DST:     room.Host,
+			Type:    model.AnnouncementTypeKnock,
+			Payload: &model.KnockPayload{UserID: userID},
+		}, roomID)
+	}
+}
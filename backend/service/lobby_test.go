@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+// newLobbyTestService returns service with room that requires admission and guest waiting in its lobby.
+func newLobbyTestService(t *testing.T) *Service {
+	t.Helper()
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{RequireAdmission: true}); err != nil {
+		t.Fatal(err)
+	}
+	room, err := svc.JoinRoom(ctx, "room", "guest", JoinParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := room.Lobby["guest"]; !ok {
+		t.Fatal("guest is not put to lobby")
+	}
+	return svc
+}
+
+func TestLobbyAdmit(t *testing.T) {
+	ctx := context.Background()
+	svc := newLobbyTestService(t)
+	_, host := connectTest(t, svc, "room", "host")
+	// knock is sent when host connects
+	if waitAnnouncement(host, time.Second, ofType(model.AnnouncementTypeKnock)) == nil {
+		t.Error("host is not told about guest")
+	}
+	_, guest := connectTest(t, svc, "room", "guest")
+
+	err := svc.Moderate(ctx, "room", "host", model.AnnouncementTypeAdmit, model.ModerationPayload{UserID: "guest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waitAnnouncement(guest, time.Second, ofType(model.AnnouncementTypeAdmitted)) == nil {
+		t.Fatal("guest is not admitted")
+	}
+	joined := func(ann model.Announcement) bool {
+		return ann.Type == model.AnnouncementTypeJoined && ann.SRC == "guest"
+	}
+	if waitAnnouncement(host, time.Second, joined) == nil {
+		t.Error("admitted guest did not join signaling")
+	}
+}
+
+func TestLobbySessionDeletedOnAdmit(t *testing.T) {
+	ctx := context.Background()
+	svc := newLobbyTestService(t)
+	wire := model.NewWire()
+	if err := svc.CreateSignalingSession(ctx, "room", "guest", wire); err != nil {
+		t.Fatal(err)
+	}
+
+	err := svc.Moderate(ctx, "room", "host", model.AnnouncementTypeAdmit, model.ModerationPayload{UserID: "guest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// session is deleted by transport before admitted announcement is delivered
+	if err = svc.DeleteSignalingSession(ctx, "room", "guest"); err != nil {
+		t.Errorf("DeleteSignalingSession() error = %v", err)
+	}
+	<-wire.TX
+	time.Sleep(100 * time.Millisecond)
+	for _, userID := range svc.sw.Endpoints("room") {
+		if userID == "guest" {
+			t.Error("deleted session is connected")
+		}
+	}
+}
+
+func TestLobbyDeny(t *testing.T) {
+	ctx := context.Background()
+	svc := newLobbyTestService(t)
+	wire, _ := connectTest(t, svc, "room", "guest")
+
+	err := svc.Moderate(ctx, "room", "host", model.AnnouncementTypeDeny, model.ModerationPayload{UserID: "guest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-wire.Close:
+		if reason.Code != model.CloseCodeAdmissionDenied {
+			t.Errorf("close code = %d, want %d", reason.Code, model.CloseCodeAdmissionDenied)
+		}
+	case <-time.After(time.Second):
+		t.Error("denied lobby session is not ended")
+	}
+	if room, _ := svc.store.GetRoom("room"); room.Lobby["guest"].ID != "" || room.Participants["guest"].ID != "" {
+		t.Error("denied guest is kept in room")
+	}
+}
+
+func TestLobbySessionEnds(t *testing.T) {
+	tests := []struct {
+		name     string
+		end      func(svc *Service) error
+		wantCode int
+	}{
+		{
+			name:     "room closed",
+			end:      func(svc *Service) error { return svc.CloseRoom("room", "") },
+			wantCode: model.CloseCodeRoomClosed,
+		},
+		{
+			name:     "guest banned",
+			end:      func(svc *Service) error { return svc.BanParticipant("room", "guest", "") },
+			wantCode: model.CloseCodeBanned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newLobbyTestService(t)
+			wire, _ := connectTest(t, svc, "room", "guest")
+			if err := tt.end(svc); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case reason := <-wire.Close:
+				if reason.Code != tt.wantCode {
+					t.Errorf("close code = %d, want %d", reason.Code, tt.wantCode)
+				}
+			case <-time.After(time.Second):
+				t.Error("lobby session is not ended")
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+var (
+	ErrModerate      = errors.New("unable to moderate room")
+	ErrNotHost       = errors.New("user is not room host")
+	ErrUnknownAction = errors.New("unknown moderation action")
+	ErrInvalidTarget = errors.New("invalid moderation target")
+	ErrBan           = errors.New("unable to ban participant")
+)
+
+// Moderate performs moderation action on behalf of room host.
+// Action is one of moderation announcement types.
+func (svc *Service) Moderate(ctx context.Context, roomID, hostID, action string, payload model.ModerationPayload) (err error) {
+	defer func() {
+		svc.audit(model.AuditEvent{
+			Action: model.AuditActionModerate,
+			Actor:  hostID,
+			RoomID: roomID,
+			UserID: payload.UserID,
+			Detail: action,
+			Reason: payload.Reason,
+		}, err)
+	}()
+
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return errors.Join(ErrModerate, ErrGet, err)
+	}
+	if room.Host != hostID {
+		return ErrNotHost
+	}
+
+	switch action {
+	case model.AnnouncementTypeKick, model.AnnouncementTypeBan, model.AnnouncementTypeTransferHost,
+		model.AnnouncementTypeAdmit, model.AnnouncementTypeDeny, model.AnnouncementTypeSetRole:
+		if payload.UserID == "" || payload.UserID == hostID {
+			return ErrInvalidTarget
+		}
+	}
+	switch action {
+	case model.AnnouncementTypeKick:
+		err = svc.KickParticipant(roomID, payload.UserID, payload.Reason)
+	case model.AnnouncementTypeBan:
+		err = svc.BanParticipant(roomID, payload.UserID, payload.Reason)
+	case model.AnnouncementTypeLock, model.AnnouncementTypeUnlock:
+		err = svc.store.SetLocked(roomID, action == model.AnnouncementTypeLock)
+	case model.AnnouncementTypeTransferHost:
+		if err = svc.store.SetHost(roomID, payload.UserID); err == nil {
+			svc.updateBroadcaster(roomID)
+		}
+	case model.AnnouncementTypeAdmit, model.AnnouncementTypeDeny:
+		err = svc.decide(roomID, payload.UserID, action == model.AnnouncementTypeAdmit)
+	case model.AnnouncementTypeSetRole:
+		err = svc.SetRole(ctx, roomID, payload.UserID, payload.Role)
+	default:
+		return ErrUnknownAction
+	}
+	if err != nil {
+		return errors.Join(ErrModerate, err)
+	}
+
+	svc.logger.Debug().
+		Str("roomID", roomID).
+		Str("host", hostID).
+		Str("action", action).
+		Str("target", payload.UserID).
+		Msg("room moderated")
+	switch action {
+	case model.AnnouncementTypeLock, model.AnnouncementTypeUnlock:
+		svc.announceRoomState(ctx, roomID)
+	case model.AnnouncementTypeTransferHost:
+		svc.announceRoomState(ctx, roomID)
+		svc.knock(ctx, roomID)
+	}
+	return nil
+}
+
+// BanParticipant removes participant from room, ends its signaling session
+// and refuses further joins with the same id.
+func (svc *Service) BanParticipant(roomID, userID, reason string) error {
+	if err := svc.store.BanParticipant(roomID, userID); err != nil {
+		return errors.Join(ErrBan, err)
+	}
+	closeReason := model.CloseBanned
+	if reason != "" {
+		closeReason = closeReason.WithText(reason)
+	}
+	svc.updateBroadcaster(roomID)
+	_ = svc.sw.Terminate(roomID, userID, closeReason)
+	svc.terminateLobbySession(roomID, userID, closeReason)
+
+	svc.logger.Info().
+		Str("userID", userID).
+		Str("roomID", roomID).
+		Str("reason", reason).
+		Msg("participant banned")
+	svc.events.Publish(model.NewEvent(model.EventTypeParticipantBanned, roomID, userID, nil))
+	go svc.announceRoomState(context.Background(), roomID)
+	return nil
+}
+
+// announceRoomState broadcasts current host and lock state to connected participants.
+func (svc *Service) announceRoomState(ctx context.Context, roomID string) {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return
+	}
+	svc.setBroadcaster(room)
+	state := roomState(room)
+	svc.events.Publish(model.NewEvent(model.EventTypeRoomStateChanged, roomID, "", state))
+	_ = svc.sw.Broadcast(ctx, model.Announcement{
+		Type:    model.AnnouncementTypeRoomState,
+		Payload: state,
+	}, roomID)
+}
+
+// updateBroadcaster makes current host of broadcast room its broadcaster,
+// it is called right after host could be changed.
+func (svc *Service) updateBroadcaster(roomID string) {
+	if room, err := svc.store.GetRoom(roomID); err == nil {
+		svc.setBroadcaster(room)
+	}
+}
+
+// setBroadcaster makes host of broadcast room the only participant that viewers can reach.
+func (svc *Service) setBroadcaster(room *model.Room) {
+	if room.Type == model.RoomTypeBroadcast {
+		svc.sw.SetBroadcaster(room.ID, room.Host)
+	}
+}
+
+func roomState(room *model.Room) *model.RoomState {
+	return &model.RoomState{
+		Host:             room.Host,
+		Locked:           room.Locked,
+		RequireAdmission: room.RequireAdmission,
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestModerateRejects(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		hostID  string
+		action  string
+		target  string
+		wantErr error
+	}{
+		{"not host", "alice", model.AnnouncementTypeKick, "host", ErrNotHost},
+		{"unknown action", "host", "mute", "alice", ErrUnknownAction},
+		{"no target", "host", model.AnnouncementTypeBan, "", ErrInvalidTarget},
+		{"host targets itself", "host", model.AnnouncementTypeKick, "host", ErrInvalidTarget},
+		{"target is not a member", "host", model.AnnouncementTypeTransferHost, "bob", ErrModerate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.Moderate(ctx, "room", tt.hostID, tt.action, model.ModerationPayload{UserID: tt.target})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Moderate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModerate(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 3})
+	for _, userID := range []string{"host", "alice", "bob"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, received := connectTest(t, svc, "room", "alice")
+	bobWire, _ := connectTest(t, svc, "room", "bob")
+	moderate := func(action, target string) *model.Room {
+		t.Helper()
+		if err := svc.Moderate(ctx, "room", "host", action, model.ModerationPayload{UserID: target}); err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+		room, err := svc.store.GetRoom("room")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return room
+	}
+
+	if room := moderate(model.AnnouncementTypeBan, "bob"); room.Participants["bob"].ID != "" {
+		t.Error("banned participant is still in room")
+	}
+	select {
+	case reason := <-bobWire.Close:
+		if reason.Code != model.CloseCodeBanned {
+			t.Errorf("close code of banned = %d, want %d", reason.Code, model.CloseCodeBanned)
+		}
+	case <-time.After(time.Second):
+		t.Error("session of banned participant is not ended")
+	}
+
+	if room := moderate(model.AnnouncementTypeLock, ""); !room.Locked {
+		t.Error("room is not locked")
+	}
+	if waitAnnouncement(received, time.Second, roomStateMatching(func(state *model.RoomState) bool { return state.Locked })) == nil {
+		t.Error("locked room state is not announced")
+	}
+
+	if room := moderate(model.AnnouncementTypeTransferHost, "alice"); room.Host != "alice" {
+		t.Errorf("host = %s, want alice", room.Host)
+	}
+	if waitAnnouncement(received, time.Second, roomStateMatching(func(state *model.RoomState) bool { return state.Host == "alice" })) == nil {
+		t.Error("new host is not announced")
+	}
+}
+
+func roomStateMatching(match func(*model.RoomState) bool) func(model.Announcement) bool {
+	return func(ann model.Announcement) bool {
+		return ann.Type == model.AnnouncementTypeRoomState && match(ann.Payload.(*model.RoomState))
+	}
+}
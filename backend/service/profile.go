@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxAvatarURLLength   = 2048
+	maxClientLength      = 256
+)
+
+var (
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrProfile        = errors.New("unable to update profile")
+)
+
+// UpdateProfile replaces participant's profile and propagates it to other participants.
+func (svc *Service) UpdateProfile(ctx context.Context, roomID, userID string, profile model.Profile) error {
+	if err := validateProfile(&profile); err != nil {
+		return err
+	}
+	p, err := svc.store.UpdateParticipant(roomID, userID, func(p *model.Participant) {
+		p.Profile = profile
+	})
+	if err != nil {
+		return errors.Join(ErrProfile, err)
+	}
+	svc.logger.Debug().
+		Str("userID", userID).
+		Str("roomID", roomID).
+		Msg("profile updated")
+	return svc.sw.Broadcast(ctx, model.Announcement{
+		SRC:     userID,
+		Type:    model.AnnouncementTypeProfile,
+		Payload: p,
+	}, roomID)
+}
+
+// sendRoster sends room participants with their profiles to participant.
+// Viewer of broadcast room gets only broadcaster.
+func (svc *Service) sendRoster(ctx context.Context, roomID, userID string) {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return
+	}
+	connected := make(map[string]bool)
+	for _, endpoint := range svc.sw.Endpoints(roomID) {
+		connected[endpoint] = true
+	}
+	roster := &model.Roster{
+		Participants: make([]model.RosterEntry, 0, len(room.Participants)),
+	}
+	viewer := room.Type == model.RoomTypeBroadcast && userID != room.Host
+	for id, p := range room.Participants {
+		if viewer && id != room.Host {
+			continue
+		}
+		roster.Participants = append(roster.Participants, model.RosterEntry{
+			Participant: p,
+			Connected:   connected[id],
+		})
+	}
+	sort.Slice(roster.Participants, func(i, j int) bool {
+		return roster.Participants[i].ID < roster.Participants[j].ID
+	})
+	_ = svc.sw.Send(ctx, model.Announcement{
+		DST:     userID,
+		Type:    model.AnnouncementTypeRoster,
+		Payload: roster,
+	}, roomID)
+}
+
+func validateProfile(profile *model.Profile) error {
+	switch {
+	case utf8.RuneCountInString(profile.DisplayName) > maxDisplayNameLength:
+		return fmt.Errorf("%w: display name is longer than %d characters", ErrInvalidProfile, maxDisplayNameLength)
+	case len(profile.Client) > maxClientLength:
+		return fmt.Errorf("%w: client info is longer than %d bytes", ErrInvalidProfile, maxClientLength)
+	case len(profile.AvatarURL) > maxAvatarURLLength:
+		return fmt.Errorf("%w: avatar url is longer than %d bytes", ErrInvalidProfile, maxAvatarURLLength)
+	}
+	if profile.AvatarURL != "" {
+		u, err := url.Parse(profile.AvatarURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: avatar url must be absolute http(s) url", ErrInvalidProfile)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestValidateProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile model.Profile
+		wantErr error
+	}{
+		{"empty", model.Profile{}, nil},
+		{"full", model.Profile{
+			DisplayName: "Алиса",
+			AvatarURL:   "https://example.com/alice.png",
+			Client:      "peerchat/1.0",
+		}, nil},
+		{"display name of max length", model.Profile{DisplayName: strings.Repeat("я", maxDisplayNameLength)}, nil},
+		{"long display name", model.Profile{DisplayName: strings.Repeat("a", maxDisplayNameLength+1)}, ErrInvalidProfile},
+		{"long client", model.Profile{Client: strings.Repeat("a", maxClientLength+1)}, ErrInvalidProfile},
+		{"long avatar url", model.Profile{AvatarURL: "https://example.com/" + strings.Repeat("a", maxAvatarURLLength)}, ErrInvalidProfile},
+		{"relative avatar url", model.Profile{AvatarURL: "/alice.png"}, ErrInvalidProfile},
+		{"avatar url scheme", model.Profile{AvatarURL: "javascript:alert(1)"}, ErrInvalidProfile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateProfile(&tt.profile); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateProfile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, host := connectTest(t, svc, "room", "host")
+	_, alice := connectTest(t, svc, "room", "alice")
+
+	ann := waitAnnouncement(alice, time.Second, ofType(model.AnnouncementTypeRoster))
+	if ann == nil {
+		t.Fatal("roster is not sent")
+	}
+	if n := len(ann.Payload.(*model.Roster).Participants); n != 2 {
+		t.Errorf("roster has %d participants, want 2", n)
+	}
+
+	if err := svc.UpdateProfile(ctx, "room", "alice", model.Profile{DisplayName: "Alice"}); err != nil {
+		t.Fatal(err)
+	}
+	ann = waitAnnouncement(host, time.Second, ofType(model.AnnouncementTypeProfile))
+	if ann == nil {
+		t.Fatal("profile update is not propagated")
+	}
+	if p := ann.Payload.(*model.Participant); ann.SRC != "alice" || p.DisplayName != "Alice" {
+		t.Errorf("profile announcement = %s %+v", ann.SRC, p)
+	}
+	if err := svc.UpdateProfile(ctx, "room", "bob", model.Profile{}); !errors.Is(err, ErrProfile) {
+		t.Errorf("UpdateProfile() of non-member error = %v, want %v", err, ErrProfile)
+	}
+}
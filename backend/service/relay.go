@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+// relay passes announcements from transport to switch. Announcements
+// that are handled by service itself are not forwarded to other participants.
+func (svc *Service) relay(ctx context.Context, roomID, userID string, wire model.Wire) model.Wire {
+	swWire := model.Wire{
+		RX:    make(chan model.Announcement),
+		TX:    wire.TX,
+		Close: wire.Close,
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ann := <-wire.RX:
+				switch {
+				case model.IsModeration(ann.Type):
+					dispatch(ctx, svc, roomID, userID, ann, nil, func(payload *model.ModerationPayload) error {
+						return svc.Moderate(ctx, roomID, userID, ann.Type, *payload)
+					})
+					continue
+				case ann.Type == model.AnnouncementTypeProfile:
+					dispatch(ctx, svc, roomID, userID, ann, ErrInvalidProfile, func(profile *model.Profile) error {
+						return svc.UpdateProfile(ctx, roomID, userID, *profile)
+					})
+					continue
+				case ann.Type == model.AnnouncementTypeState:
+					dispatch(ctx, svc, roomID, userID, ann, ErrInvalidState, func(state *model.MediaState) error {
+						return svc.UpdateState(ctx, roomID, userID, *state)
+					})
+					continue
+				case ann.Type == model.AnnouncementTypeChat:
+					dispatch(ctx, svc, roomID, userID, ann, ErrInvalidChat, func(msg *model.ChatMessage) error {
+						return svc.SendChatMessage(ctx, roomID, userID, msg.Text)
+					})
+					continue
+				case ann.Type == model.AnnouncementTypeStats:
+					dispatch(ctx, svc, roomID, userID, ann, ErrInvalidStats, func(report *model.StatsReport) error {
+						return svc.SubmitStats(roomID, userID, *report)
+					})
+					continue
+				case ann.Type == model.AnnouncementTypeOffer, ann.Type == model.AnnouncementTypeAnswer:
+					if !svc.handleSessionDescription(ctx, roomID, userID, ann) {
+						continue
+					}
+				}
+				select {
+				case swWire.RX <- ann:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return swWire
+}
+
+// dispatch decodes payload of participant request and passes it to act. Decoding error
+// is joined with errInvalid. Rejected request is answered with error announcement.
+func dispatch[T any](ctx context.Context, svc *Service, roomID, userID string, ann model.Announcement,
+	errInvalid error, act func(*T) error) {
+	var v T
+	err := decodePayload(ann.Payload, &v)
+	if err != nil {
+		err = errors.Join(errInvalid, err)
+	} else {
+		err = act(&v)
+	}
+	if err != nil {
+		svc.logger.Debug().Err(err).
+			Str("roomID", roomID).
+			Str("userID", userID).
+			Str("type", ann.Type).
+			Msg("request rejected")
+		svc.replyError(ctx, roomID, userID, err)
+	}
+}
+
+// replyError sends error announcement to participant whose request was rejected.
+func (svc *Service) replyError(ctx context.Context, roomID, userID string, err error) {
+	_ = svc.sw.Send(ctx, model.Announcement{
+		DST:     userID,
+		Type:    model.AnnouncementTypeError,
+		Payload: &model.ErrorPayload{Error: err.Error()},
+	}, roomID)
+}
+
+// decodePayload converts generic announcement payload to particular type.
+func decodePayload(payload any, v any) error {
+	if payload == nil {
+		return nil
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
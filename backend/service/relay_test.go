@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestRelayRejectsRequests(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wire, received := connectTest(t, svc, "room", "alice")
+
+	tests := []struct {
+		name      string
+		ann       model.Announcement
+		wantError error
+	}{
+		{
+			name:      "invalid chat payload",
+			ann:       model.Announcement{Type: model.AnnouncementTypeChat, Payload: "text"},
+			wantError: ErrInvalidChat,
+		},
+		{
+			name:      "invalid state payload",
+			ann:       model.Announcement{Type: model.AnnouncementTypeState, Payload: 1},
+			wantError: ErrInvalidState,
+		},
+		{
+			name:      "moderation by participant",
+			ann:       model.Announcement{Type: model.AnnouncementTypeKick, Payload: map[string]any{"user_id": "host"}},
+			wantError: ErrNotHost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.ann.SRC = "alice"
+			wire.RX <- tt.ann
+			ann := waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeError))
+			if ann == nil {
+				t.Fatal("request is not rejected")
+			}
+			if msg := ann.Payload.(*model.ErrorPayload).Error; !strings.Contains(msg, tt.wantError.Error()) {
+				t.Errorf("error = %q, want %q", msg, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+var (
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrViewerSending = errors.New("viewer cannot send media")
+	ErrDescription   = errors.New("invalid session description")
+)
+
+// SetRole changes role of participant and notifies room about it.
+// Only publisher and viewer roles can be set, host role is transferred.
+func (svc *Service) SetRole(ctx context.Context, roomID, userID, role string) error {
+	if role != model.RolePublisher && role != model.RoleViewer {
+		return ErrInvalidRole
+	}
+	if _, err := svc.store.SetRole(roomID, userID, role); err != nil {
+		return err
+	}
+	svc.logger.Debug().
+		Str("roomID", roomID).
+		Str("userID", userID).
+		Str("role", role).
+		Msg("participant role changed")
+	payload := &model.RolePayload{UserID: userID, Role: role}
+	svc.events.Publish(model.NewEvent(model.EventTypeRoleChanged, roomID, userID, payload))
+	_ = svc.sw.Broadcast(ctx, model.Announcement{
+		Type:    model.AnnouncementTypeRole,
+		Payload: payload,
+	}, roomID)
+	return nil
+}
+
+// checkSessionDescription rejects offers and answers of viewers that would send media.
+func (svc *Service) checkSessionDescription(roomID, userID string, ann model.Announcement) error {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return errors.Join(ErrGet, err)
+	}
+	if model.IsPublishing(room.Participants[userID].Role) {
+		return nil
+	}
+	var desc model.SessionDescription
+	if err = decodePayload(ann.Payload, &desc); err != nil {
+		return errors.Join(ErrDescription, err)
+	}
+	if sendsMedia(desc.SDP) {
+		return ErrViewerSending
+	}
+	return nil
+}
+
+// sendsMedia reports whether session description has active audio or video
+// section with send direction. Direction is sendrecv unless it is set
+// at media or session level.
+func sendsMedia(sdp string) bool {
+	var (
+		sessionDir = "sendrecv"
+		inMedia    bool
+		active     bool
+		dir        string
+	)
+	sends := func() bool {
+		return active && (dir == "sendrecv" || dir == "sendonly")
+	}
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if m, ok := strings.CutPrefix(line, "m="); ok {
+			if sends() {
+				return true
+			}
+			fields := strings.Fields(m)
+			inMedia = true
+			active = len(fields) > 1 && (fields[0] == "audio" || fields[0] == "video") && fields[1] != "0"
+			dir = sessionDir
+			continue
+		}
+		switch a := strings.TrimPrefix(line, "a="); a {
+		case "sendrecv", "sendonly", "recvonly", "inactive":
+			if inMedia {
+				dir = a
+			} else {
+				sessionDir = a
+			}
+		}
+	}
+	return sends()
+}
+
+func (svc *Service) handleSessionDescription(ctx context.Context, roomID, userID string, ann model.Announcement) bool {
+	if err := svc.checkSessionDescription(roomID, userID, ann); err != nil {
+		svc.logger.Debug().Err(err).
+			Str("roomID", roomID).
+			Str("userID", userID).
+			Str("type", ann.Type).
+			Msg("session description rejected")
+		svc.replyError(ctx, roomID, userID, err)
+		return false
+	}
+	return true
+}
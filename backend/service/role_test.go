@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func sdp(lines ...string) string {
+	return strings.Join(append([]string{"v=0", "o=- 1 2 IN IP4 127.0.0.1", "s=-", "t=0 0"}, lines...), "\r\n") + "\r\n"
+}
+
+func TestSendsMedia(t *testing.T) {
+	tests := []struct {
+		name string
+		sdp  string
+		want bool
+	}{
+		{"empty", "", false},
+		{"no media", sdp(), false},
+		{"default direction", sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111"), true},
+		{"sendrecv", sdp("m=video 9 UDP/TLS/RTP/SAVPF 96", "a=sendrecv"), true},
+		{"sendonly", sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111", "a=sendonly"), true},
+		{"recvonly", sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111", "a=recvonly", "m=video 9 UDP/TLS/RTP/SAVPF 96", "a=recvonly"), false},
+		{"inactive", sdp("m=video 9 UDP/TLS/RTP/SAVPF 96", "a=inactive"), false},
+		{"rejected section", sdp("m=video 0 UDP/TLS/RTP/SAVPF 96", "a=sendrecv"), false},
+		{"second section sends", sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111", "a=recvonly", "m=video 9 UDP/TLS/RTP/SAVPF 96", "a=sendonly"), true},
+		{"session level recvonly", sdp("a=recvonly", "m=audio 9 UDP/TLS/RTP/SAVPF 111"), false},
+		{"media level overrides session level", sdp("a=recvonly", "m=audio 9 UDP/TLS/RTP/SAVPF 111", "a=sendrecv"), true},
+		{"application section", sdp("m=application 9 UDP/DTLS/SCTP webrtc-datachannel", "a=sendrecv"), false},
+		{"lf line endings", "v=0\nm=audio 9 UDP/TLS/RTP/SAVPF 111\na=sendonly\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sendsMedia(tt.sdp); got != tt.want {
+				t.Errorf("sendsMedia() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{MaxParticipants: 3})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, received := connectTest(t, svc, "room", "host")
+
+	// alice is demoted to viewer and promoted back
+	for _, role := range []string{model.RoleViewer, model.RolePublisher} {
+		if err := svc.SetRole(ctx, "room", "alice", role); err != nil {
+			t.Fatalf("SetRole(%s) error = %v", role, err)
+		}
+		ann := waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeRole))
+		if ann == nil {
+			t.Fatalf("role %s is not announced", role)
+		}
+		if p := ann.Payload.(*model.RolePayload); p.UserID != "alice" || p.Role != role {
+			t.Errorf("announced role = %+v, want %s of alice", p, role)
+		}
+	}
+
+	for _, tt := range []struct {
+		userID  string
+		role    string
+		wantErr error
+	}{
+		{"alice", model.RoleHost, ErrInvalidRole},
+		{"alice", "admin", ErrInvalidRole},
+		{"host", model.RoleViewer, memory.ErrHostRole},
+	} {
+		if err := svc.SetRole(ctx, "room", tt.userID, tt.role); !errors.Is(err, tt.wantErr) {
+			t.Errorf("SetRole(%s, %s) error = %v, want %v", tt.userID, tt.role, err, tt.wantErr)
+		}
+	}
+}
+
+func TestViewerSessionDescription(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.JoinRoom(ctx, "room", "alice", JoinParams{Viewer: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, hostReceived := connectTest(t, svc, "room", "host")
+	wire, received := connectTest(t, svc, "room", "alice")
+
+	tests := []struct {
+		name      string
+		typ       string
+		sdp       string
+		wantError error
+	}{
+		{"receiving offer", model.AnnouncementTypeOffer, sdp("m=video 9 UDP/TLS/RTP/SAVPF 96", "a=recvonly"), nil},
+		{"receiving answer", model.AnnouncementTypeAnswer, sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111", "a=inactive"), nil},
+		{"sending offer", model.AnnouncementTypeOffer, sdp("m=video 9 UDP/TLS/RTP/SAVPF 96", "a=sendrecv"), ErrViewerSending},
+		{"sending answer", model.AnnouncementTypeAnswer, sdp("m=audio 9 UDP/TLS/RTP/SAVPF 111"), ErrViewerSending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wire.RX <- model.Announcement{
+				SRC:     "alice",
+				DST:     "host",
+				Type:    tt.typ,
+				Payload: &model.SessionDescription{Type: tt.typ, SDP: tt.sdp},
+			}
+			if tt.wantError == nil {
+				if waitAnnouncement(hostReceived, time.Second, ofType(tt.typ)) == nil {
+					t.Errorf("%s is not relayed", tt.typ)
+				}
+				return
+			}
+			ann := waitAnnouncement(received, time.Second, ofType(model.AnnouncementTypeError))
+			if ann == nil {
+				t.Fatalf("%s is not rejected", tt.typ)
+			}
+			if msg := ann.Payload.(*model.ErrorPayload).Error; !strings.Contains(msg, tt.wantError.Error()) {
+				t.Errorf("error = %q, want %q", msg, tt.wantError)
+			}
+			if waitAnnouncement(hostReceived, 100*time.Millisecond, ofType(tt.typ)) != nil {
+				t.Errorf("rejected %s is relayed", tt.typ)
+			}
+		})
+	}
+}
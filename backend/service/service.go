@@ -3,9 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/tracing"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -16,67 +21,242 @@ var (
 	ErrDisconnect = errors.New("unable to disconnect")
 )
 
+var tracer = otel.Tracer("github.com/adwski/webrtc-playground/backend/service")
+
 type (
 	RoomStore interface {
-		CreateOrJoinRoom(roomID string, userID string) (*model.Room, error)
+		CreateOrJoinRoom(roomID string, userID string, opts model.JoinOptions) (*model.Room, bool, error)
+		CreateRoom(room *model.Room) error
 		GetRoom(roomID string) (*model.Room, error)
+		ListRooms() []model.Room
+		RemoveParticipant(roomID string, userID string) error
+		BanParticipant(roomID string, userID string) error
+		SetLocked(roomID string, locked bool) error
+		SetHost(roomID string, userID string) error
+		SetRole(roomID string, userID string, role string) (*model.Participant, error)
+		UpdateParticipant(roomID string, userID string, update func(*model.Participant)) (*model.Participant, error)
+		Admit(roomID string, userID string) error
+		Deny(roomID string, userID string) error
+		DeleteRoom(roomID string) error
+		AppendChatMessage(roomID string, msg model.ChatMessage) (*model.ChatMessage, error)
+		ChatHistory(roomID string, before int64, limit int) (*model.ChatHistory, error)
+	}
+
+	// Auditor receives security-relevant events.
+	Auditor interface {
+		Audit(model.AuditEvent)
+	}
+
+	StatsStore interface {
+		Add(report *model.StatsReport)
+		Reports(roomID, userID string, since time.Time, limit int) []model.StatsReport
+	}
+
+	EventPublisher interface {
+		Publish(model.Event)
 	}
 
 	Switch interface {
 		Connect(ctx context.Context, roomID string, userID string, wire model.Wire) error
 		Disconnect(roomID string, userID string) error
 		Broadcast(ctx context.Context, ann model.Announcement, roomID string) error
+		Send(ctx context.Context, ann model.Announcement, roomID string) error
+		Terminate(roomID string, userID string, reason model.CloseReason) error
+		TerminateInstance(roomID string, reason model.CloseReason)
+		TerminateAll(reason model.CloseReason)
+		Endpoints(roomID string) []string
+		SetBroadcaster(roomID string, userID string)
 	}
 
 	Service struct {
 		store  RoomStore
 		sw     Switch
+		events EventPublisher
 		logger zerolog.Logger
+
+		mx         *sync.RWMutex
+		iceServers []model.ICEServer
+
+		lobbyMx *sync.Mutex
+		lobby   map[string]map[string]*lobbySession
+
+		invites      InviteIssuer
+		inviteTTL    time.Duration
+		inviteMaxTTL time.Duration
+
+		stats   StatsStore
+		auditor Auditor
+
+		breakoutMx *sync.Mutex
+		breakouts  map[string]*activeBreakouts
 	}
 
 	Config struct {
-		RoomStore RoomStore
-		Switch    Switch
-		Logger    *zerolog.Logger
+		RoomStore  RoomStore
+		Switch     Switch
+		Logger     *zerolog.Logger
+		ICEServers []model.ICEServer
+
+		// Events receives room lifecycle events, optional.
+		Events EventPublisher
+
+		Invites InviteIssuer
+		// InviteTTL is used if invite ttl is not requested, InviteMaxTTL limits requested ttl.
+		InviteTTL    time.Duration
+		InviteMaxTTL time.Duration
+
+		// Stats keeps call quality reports.
+		Stats StatsStore
+
+		// Audit receives security-relevant events, optional.
+		Audit Auditor
 	}
+
+	noopPublisher struct{}
 )
 
 func NewService(cfg Config) *Service {
-	return &Service{
+	svc := &Service{
 		store:  cfg.RoomStore,
 		sw:     cfg.Switch,
+		events: cfg.Events,
 		logger: cfg.Logger.With().Str("component", "api").Logger(),
+
+		mx:         &sync.RWMutex{},
+		iceServers: cfg.ICEServers,
+
+		lobbyMx: &sync.Mutex{},
+		lobby:   make(map[string]map[string]*lobbySession),
+
+		invites:      cfg.Invites,
+		inviteTTL:    cfg.InviteTTL,
+		inviteMaxTTL: cfg.InviteMaxTTL,
+
+		stats:   cfg.Stats,
+		auditor: cfg.Audit,
+
+		breakoutMx: &sync.Mutex{},
+		breakouts:  make(map[string]*activeBreakouts),
 	}
+	if svc.inviteTTL == 0 {
+		svc.inviteTTL = defaultInviteTTL
+	}
+	if svc.inviteMaxTTL == 0 {
+		svc.inviteMaxTTL = defaultInviteMaxTTL
+	}
+	if svc.events == nil {
+		svc.events = noopPublisher{}
+	}
+	if svc.auditor == nil {
+		svc.auditor = noopAuditor{}
+	}
+	return svc
+}
+
+func (noopPublisher) Publish(model.Event) {}
+
+// ICEServers returns STUN/TURN servers that clients should use.
+func (svc *Service) ICEServers() []model.ICEServer {
+	svc.mx.RLock()
+	defer svc.mx.RUnlock()
+	return svc.iceServers
 }
 
-func (svc *Service) CreateSignalingSession(ctx context.Context, roomID, userID string, wire model.Wire) error {
+// SetICEServers updates advertised STUN/TURN servers.
+func (svc *Service) SetICEServers(servers []model.ICEServer) {
+	svc.mx.Lock()
+	defer svc.mx.Unlock()
+	svc.iceServers = servers
+}
+
+func (svc *Service) CreateSignalingSession(ctx context.Context, roomID, userID string, wire model.Wire) (err error) {
+	// span is not put to ctx, ctx lives as long as session
+	_, span := tracer.Start(ctx, "Service.CreateSignalingSession", trace.WithAttributes(
+		tracing.AttrRoomID.String(roomID),
+		tracing.AttrUserID.String(userID),
+	))
+	defer func() {
+		if err != nil {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+		svc.audit(model.AuditEvent{Action: model.AuditActionConnect, Actor: userID, RoomID: roomID}, err)
+	}()
+
 	room, err := svc.store.GetRoom(roomID)
 	if err != nil {
-		return errors.Join(ErrGet, err)
+		return model.NewCloseError(model.CloseRoomNotFound, errors.Join(ErrGet, err))
+	}
+	if _, ok := room.Lobby[userID]; ok {
+		span.AddEvent("waiting in lobby")
+		svc.waitInLobby(ctx, roomID, userID, wire)
+		return nil
 	}
 	if _, ok := room.Participants[userID]; !ok {
-		return ErrNotAMember
+		return model.NewCloseError(model.CloseNotAMember, ErrNotAMember)
+	}
+	return svc.connect(ctx, roomID, userID, wire)
+}
+
+// connect attaches signaling session of room participant to switch.
+func (svc *Service) connect(ctx context.Context, roomID, userID string, wire model.Wire) error {
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return model.NewCloseError(model.CloseRoomNotFound, errors.Join(ErrGet, err))
 	}
-	err = svc.sw.Connect(ctx, roomID, userID, wire)
+	broadcast := room.Type == model.RoomTypeBroadcast
+	svc.setBroadcaster(room)
+	err = svc.sw.Connect(ctx, roomID, userID, svc.relay(ctx, roomID, userID, wire))
 	if err != nil {
-		return errors.Join(ErrConnect, err)
+		return model.NewCloseError(model.CloseInternalError, errors.Join(ErrConnect, err))
 	}
 	svc.logger.Debug().
 		Str("userID", userID).
 		Str("roomID", roomID).
 		Msg("signaling session connected")
+	svc.events.Publish(model.NewEvent(model.EventTypeSignalingConnected, roomID, userID, nil))
 
 	go func() {
 		ann := model.Announcement{
 			Type: model.AnnouncementTypeJoined,
 			SRC:  userID,
 		}
-		_ = svc.sw.Broadcast(ctx, ann, roomID)
+		if p, ok := room.Participants[userID]; ok {
+			ann.Payload = &p
+		}
+		svc.sendRoster(ctx, roomID, userID)
+		svc.sendChatHistory(ctx, roomID, userID)
+		if broadcast && room.Host != userID {
+			// viewer joins are announced only to broadcaster
+			ann.DST = room.Host
+			_ = svc.sw.Send(ctx, ann, roomID)
+		} else {
+			_ = svc.sw.Broadcast(ctx, ann, roomID)
+		}
+		if broadcast {
+			// viewers are not told about each other, so room state is sent to joiner only
+			_ = svc.sw.Send(ctx, model.Announcement{
+				DST:     userID,
+				Type:    model.AnnouncementTypeRoomState,
+				Payload: roomState(room),
+			}, roomID)
+		} else {
+			svc.announceRoomState(ctx, roomID)
+		}
+		if room.Host == userID {
+			svc.knock(ctx, roomID)
+		}
+		if breakoutID := svc.breakoutOf(roomID, userID); breakoutID != "" {
+			svc.move(ctx, roomID, userID, breakoutID)
+		}
 	}()
 	return nil
 }
 
 func (svc *Service) DeleteSignalingSession(ctx context.Context, roomID, userID string) error {
+	if svc.leaveLobby(roomID, userID) {
+		return nil
+	}
 	err := svc.sw.Disconnect(roomID, userID)
 	if err != nil {
 		return errors.Join(ErrDisconnect, err)
@@ -85,6 +265,15 @@ func (svc *Service) DeleteSignalingSession(ctx context.Context, roomID, userID s
 		Str("userID", userID).
 		Str("roomID", roomID).
 		Msg("signaling session deleted")
+	svc.events.Publish(model.NewEvent(model.EventTypeSignalingDisconnected, roomID, userID, nil))
+	connected := len(svc.sw.Endpoints(roomID))
+	svc.events.Publish(model.NewEvent(model.EventTypeParticipantLeft, roomID, userID, map[string]int{
+		"connected": connected,
+	}))
+	if connected == 0 {
+		svc.events.Publish(model.NewEvent(model.EventTypeRoomEmpty, roomID, "", nil))
+	}
+	svc.resetState(roomID, userID)
 
 	ann := model.Announcement{
 		SRC:  userID,
@@ -94,14 +283,57 @@ func (svc *Service) DeleteSignalingSession(ctx context.Context, roomID, userID s
 	return nil
 }
 
-func (svc *Service) JoinRoom(roomID, userID string) (*model.Room, error) {
-	room, err := svc.store.CreateOrJoinRoom(roomID, userID)
+// Drain ends all signaling sessions, it is used during graceful shutdown.
+func (svc *Service) Drain() {
+	svc.sw.TerminateAll(model.CloseServerDraining)
+	svc.terminateLobby(model.CloseServerDraining)
+	svc.logger.Info().Msg("signaling sessions are drained")
+}
+
+// JoinRoom creates room or adds user to existing one. If room requires admission,
+// user is put to lobby and host is notified, returned room has user in Lobby then.
+// Joining room with passcode requires passcode or invite token.
+func (svc *Service) JoinRoom(ctx context.Context, roomID, userID string, params JoinParams) (_ *model.Room, err error) {
+	_, span := tracer.Start(ctx, "Service.JoinRoom", trace.WithAttributes(
+		tracing.AttrRoomID.String(roomID),
+		tracing.AttrUserID.String(userID),
+	))
+	defer func() {
+		if err != nil {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+		svc.audit(model.AuditEvent{Action: model.AuditActionJoin, Actor: userID, RoomID: roomID}, err)
+	}()
+
+	existing, _ := svc.store.GetRoom(roomID)
+	opts, err := svc.joinOptions(existing, userID, params)
 	if err != nil {
 		return nil, errors.Join(ErrJoin, err)
 	}
+	room, created, err := svc.store.CreateOrJoinRoom(roomID, userID, opts)
+	if err != nil {
+		return nil, errors.Join(ErrJoin, err)
+	}
+	if _, ok := room.Lobby[userID]; ok {
+		span.AddEvent("waiting in lobby")
+		svc.logger.Debug().
+			Str("userID", userID).
+			Str("roomID", roomID).
+			Msg("user is waiting in lobby")
+		svc.events.Publish(model.NewEvent(model.EventTypeParticipantKnocked, roomID, userID, nil))
+		go svc.knock(context.Background(), roomID, userID)
+		return room, nil
+	}
 	svc.logger.Debug().
 		Str("userID", userID).
 		Str("roomID", roomID).
 		Msg("user joined room")
+	if created {
+		svc.events.Publish(model.NewEvent(model.EventTypeRoomCreated, roomID, userID, nil))
+	}
+	svc.events.Publish(model.NewEvent(model.EventTypeParticipantJoined, roomID, userID, map[string]int{
+		"participants": len(room.Participants),
+	}))
 	return room, nil
 }
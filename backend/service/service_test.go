@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/invite"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+	sw "github.com/adwski/webrtc-playground/backend/switch"
+	"github.com/rs/zerolog"
+)
+
+func newTestService(t *testing.T, storeCfg memory.Config) *Service {
+	t.Helper()
+	logger := zerolog.Nop()
+	invites, err := invite.NewIssuer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(Config{
+		RoomStore: memory.NewMemStore(storeCfg),
+		Switch:    sw.NewSwitch(sw.Config{Logger: &logger}),
+		Logger:    &logger,
+		Invites:   invites,
+	})
+}
+
+// connectTest connects signaling session of participant, announcements
+// received by participant are returned.
+func connectTest(t *testing.T, svc *Service, roomID, userID string) (model.Wire, <-chan model.Announcement) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	wire := model.NewWire()
+	if err := svc.CreateSignalingSession(ctx, roomID, userID, wire); err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan model.Announcement, 100)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ann := <-wire.TX:
+				received <- ann
+			}
+		}
+	}()
+	return wire, received
+}
+
+// waitAnnouncement returns first received announcement that matches, or nil after timeout.
+func waitAnnouncement(received <-chan model.Announcement, timeout time.Duration, match func(model.Announcement) bool) *model.Announcement {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ann := <-received:
+			if match(ann) {
+				return &ann
+			}
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+func ofType(typ string) func(model.Announcement) bool {
+	return func(ann model.Announcement) bool {
+		return ann.Type == typ
+	}
+}
+
+// eventRecorder keeps published events.
+type eventRecorder struct {
+	mx     sync.Mutex
+	events []model.Event
+}
+
+func (er *eventRecorder) Publish(ev model.Event) {
+	er.mx.Lock()
+	defer er.mx.Unlock()
+	er.events = append(er.events, ev)
+}
+
+func (er *eventRecorder) count(typ string) int {
+	er.mx.Lock()
+	defer er.mx.Unlock()
+	n := 0
+	for _, ev := range er.events {
+		if ev.Type == typ {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRoomCreatedOnce(t *testing.T) {
+	svc := newTestService(t, memory.Config{MaxParticipants: 10})
+	recorder := &eventRecorder{}
+	svc.events = recorder
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.JoinRoom(context.Background(), "room", fmt.Sprintf("user%d", i), JoinParams{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := recorder.count(model.EventTypeRoomCreated); n != 1 {
+		t.Errorf("%d room_created events are published, want 1", n)
+	}
+}
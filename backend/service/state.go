@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+var (
+	ErrInvalidState = errors.New("invalid state")
+	ErrState        = errors.New("unable to update state")
+)
+
+// UpdateState records participant's media state and forwards it to other participants.
+func (svc *Service) UpdateState(ctx context.Context, roomID, userID string, state model.MediaState) error {
+	if _, err := svc.store.UpdateParticipant(roomID, userID, func(p *model.Participant) {
+		p.State = state
+	}); err != nil {
+		return errors.Join(ErrState, err)
+	}
+	return svc.sw.Broadcast(ctx, model.Announcement{
+		SRC:     userID,
+		Type:    model.AnnouncementTypeState,
+		Payload: &state,
+	}, roomID)
+}
+
+// resetState clears state of participant whose signaling session has ended,
+// so it is not shown as speaking or sharing screen to late joiners.
+func (svc *Service) resetState(roomID, userID string) {
+	_, _ = svc.store.UpdateParticipant(roomID, userID, func(p *model.Participant) {
+		p.State = model.MediaState{}
+	})
+}
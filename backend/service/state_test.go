@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestUpdateState(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	for _, userID := range []string{"host", "alice"} {
+		if _, err := svc.JoinRoom(ctx, "room", userID, JoinParams{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, host := connectTest(t, svc, "room", "host")
+	connectTest(t, svc, "room", "alice")
+
+	// every update replaces whole state
+	for _, state := range []model.MediaState{
+		{Muted: true},
+		{Muted: true, HandRaised: true},
+		{CameraOff: true},
+	} {
+		if err := svc.UpdateState(ctx, "room", "alice", state); err != nil {
+			t.Fatal(err)
+		}
+		ann := waitAnnouncement(host, time.Second, ofType(model.AnnouncementTypeState))
+		if ann == nil {
+			t.Fatalf("state %+v is not forwarded", state)
+		}
+		if got := *ann.Payload.(*model.MediaState); ann.SRC != "alice" || got != state {
+			t.Errorf("forwarded state = %s %+v, want %+v", ann.SRC, got, state)
+		}
+		if room, _ := svc.store.GetRoom("room"); room.Participants["alice"].State != state {
+			t.Errorf("recorded state = %+v, want %+v", room.Participants["alice"].State, state)
+		}
+	}
+
+	if err := svc.UpdateState(ctx, "room", "bob", model.MediaState{Muted: true}); !errors.Is(err, ErrState) {
+		t.Errorf("UpdateState() of non-member error = %v, want %v", err, ErrState)
+	}
+}
+
+func TestStateResetOnDisconnect(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "alice", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+	connectTest(t, svc, "room", "alice")
+	if err := svc.UpdateState(ctx, "room", "alice", model.MediaState{ScreenSharing: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteSignalingSession(ctx, "room", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	room, _ := svc.store.GetRoom("room")
+	if state := room.Participants["alice"].State; state != (model.MediaState{}) {
+		t.Errorf("state after disconnect = %+v, want reset", state)
+	}
+}
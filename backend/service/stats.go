@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+const (
+	maxStatsPageLimit = 1000
+	maxPeerIDLength   = 256
+)
+
+var (
+	ErrInvalidStats = errors.New("invalid stats report")
+	ErrStats        = errors.New("unable to submit stats report")
+)
+
+// SubmitStats stores call quality report of room participant.
+func (svc *Service) SubmitStats(roomID, userID string, report model.StatsReport) error {
+	if err := validateStats(&report); err != nil {
+		return err
+	}
+	room, err := svc.store.GetRoom(roomID)
+	if err != nil {
+		return errors.Join(ErrStats, ErrGet, err)
+	}
+	if _, ok := room.Participants[userID]; !ok {
+		return ErrNotAMember
+	}
+	report.RoomID = roomID
+	report.UserID = userID
+	report.ReceivedAt = time.Now().UTC()
+	svc.stats.Add(&report)
+	return nil
+}
+
+// RoomStats returns up to limit latest room call quality reports received after since,
+// optionally only reports of single participant.
+func (svc *Service) RoomStats(roomID, userID string, since time.Time, limit int) []model.StatsReport {
+	if limit <= 0 || limit > maxStatsPageLimit {
+		limit = maxStatsPageLimit
+	}
+	return svc.stats.Reports(roomID, userID, since, limit)
+}
+
+func validateStats(report *model.StatsReport) error {
+	for _, v := range []struct {
+		name string
+		val  float64
+	}{
+		{"rtt_ms", report.RTTMs},
+		{"jitter_ms", report.JitterMs},
+		{"inbound_bitrate", report.InboundBitrate},
+		{"outbound_bitrate", report.OutboundBitrate},
+	} {
+		if v.val < 0 || math.IsNaN(v.val) || math.IsInf(v.val, 0) {
+			return fmt.Errorf("%w: %s must be non-negative number", ErrInvalidStats, v.name)
+		}
+	}
+	if !(report.PacketLoss >= 0 && report.PacketLoss <= 1) {
+		return fmt.Errorf("%w: packet_loss must be between 0 and 1", ErrInvalidStats)
+	}
+	if len(report.PeerID) > maxPeerIDLength {
+		return fmt.Errorf("%w: peer_id is longer than %d bytes", ErrInvalidStats, maxPeerIDLength)
+	}
+	for _, typ := range []string{report.LocalCandidateType, report.RemoteCandidateType} {
+		switch typ {
+		case "", model.CandidateTypeHost, model.CandidateTypeSrflx, model.CandidateTypePrflx, model.CandidateTypeRelay:
+		default:
+			return fmt.Errorf("%w: unknown candidate type %q", ErrInvalidStats, typ)
+		}
+	}
+	return nil
+}
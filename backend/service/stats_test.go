@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/stats"
+	"github.com/adwski/webrtc-playground/backend/storage/memory"
+)
+
+func TestValidateStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  model.StatsReport
+		wantErr error
+	}{
+		{"valid", model.StatsReport{
+			RTTMs:               40,
+			PacketLoss:          0.01,
+			LocalCandidateType:  model.CandidateTypeRelay,
+			RemoteCandidateType: model.CandidateTypeSrflx,
+		}, nil},
+		{"negative rtt", model.StatsReport{RTTMs: -1}, ErrInvalidStats},
+		{"nan jitter", model.StatsReport{JitterMs: math.NaN()}, ErrInvalidStats},
+		{"infinite bitrate", model.StatsReport{InboundBitrate: math.Inf(1)}, ErrInvalidStats},
+		{"packet loss above one", model.StatsReport{PacketLoss: 1.5}, ErrInvalidStats},
+		{"nan packet loss", model.StatsReport{PacketLoss: math.NaN()}, ErrInvalidStats},
+		{"long peer id", model.StatsReport{PeerID: strings.Repeat("a", maxPeerIDLength+1)}, ErrInvalidStats},
+		{"unknown candidate type", model.StatsReport{LocalCandidateType: "turn"}, ErrInvalidStats},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateStats(&tt.report); !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateStats() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubmitStats(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t, memory.Config{})
+	svc.stats = stats.NewStore(stats.Config{})
+	if _, err := svc.JoinRoom(ctx, "room", "host", JoinParams{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  string
+		wantErr error
+	}{
+		{"participant", "room", "host", nil},
+		{"not a member", "room", "alice", ErrNotAMember},
+		{"room not found", "other", "host", ErrStats},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// report ids are overwritten by server
+			err := svc.SubmitStats(tt.roomID, tt.userID, model.StatsReport{RoomID: "x", UserID: "y", RTTMs: 10})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SubmitStats() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	reports := svc.RoomStats("room", "", time.Time{}, 0)
+	if len(reports) != 1 || reports[0].UserID != "host" || reports[0].ReceivedAt.IsZero() {
+		t.Errorf("RoomStats() = %+v, want single report of host", reports)
+	}
+}
@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/metrics"
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+const (
+	defaultRetention  = time.Hour
+	defaultMaxReports = 1000
+
+	pruneInterval = time.Minute
+)
+
+// Store keeps call quality reports per room for retention period
+// and exports them as metrics. Reports outlive rooms, so ended calls can be inspected.
+type Store struct {
+	mx         *sync.Mutex
+	rooms      map[string][]model.StatsReport
+	retention  time.Duration
+	maxReports int
+}
+
+type Config struct {
+	// Retention is how long reports are kept.
+	Retention time.Duration
+
+	// MaxReports is max number of kept reports per room, oldest are discarded.
+	MaxReports int
+}
+
+func NewStore(cfg Config) *Store {
+	st := &Store{
+		mx:         &sync.Mutex{},
+		rooms:      make(map[string][]model.StatsReport),
+		retention:  cfg.Retention,
+		maxReports: cfg.MaxReports,
+	}
+	if st.retention == 0 {
+		st.retention = defaultRetention
+	}
+	if st.maxReports == 0 {
+		st.maxReports = defaultMaxReports
+	}
+	return st
+}
+
+// Run removes expired reports until context is done.
+func (st *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			st.prune(now)
+		}
+	}
+}
+
+// Add stores report and observes its values in metrics.
+func (st *Store) Add(report *model.StatsReport) {
+	observe(report)
+
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	reports := append(st.rooms[report.RoomID], *report)
+	if extra := len(reports) - st.maxReports; extra > 0 {
+		reports = append(reports[:0:0], reports[extra:]...)
+	}
+	st.rooms[report.RoomID] = reports
+}
+
+// Reports returns up to limit latest room reports received after since ordered by time.
+// Empty userID selects reports of every participant, zero limit selects all reports.
+func (st *Store) Reports(roomID, userID string, since time.Time, limit int) []model.StatsReport {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	if cutoff := time.Now().Add(-st.retention); since.Before(cutoff) {
+		since = cutoff
+	}
+	result := make([]model.StatsReport, 0)
+	reports := st.rooms[roomID]
+	for i := len(reports) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		r := reports[i]
+		if r.ReceivedAt.Before(since) {
+			break
+		}
+		if userID == "" || r.UserID == userID {
+			result = append(result, r)
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func (st *Store) prune(now time.Time) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	cutoff := now.Add(-st.retention)
+	for roomID, reports := range st.rooms {
+		i := 0
+		for i < len(reports) && reports[i].ReceivedAt.Before(cutoff) {
+			i++
+		}
+		switch {
+		case i == len(reports):
+			delete(st.rooms, roomID)
+		case i > 0:
+			st.rooms[roomID] = append(reports[:0:0], reports[i:]...)
+		}
+	}
+}
+
+func observe(report *model.StatsReport) {
+	metrics.CallRTT.Observe(report.RTTMs / 1000)
+	metrics.CallJitter.Observe(report.JitterMs / 1000)
+	metrics.CallPacketLoss.Observe(report.PacketLoss)
+	metrics.CallBitrate.WithLabelValues("inbound").Observe(report.InboundBitrate)
+	metrics.CallBitrate.WithLabelValues("outbound").Observe(report.OutboundBitrate)
+	if report.LocalCandidateType != "" && report.RemoteCandidateType != "" {
+		metrics.CallCandidatePairs.WithLabelValues(report.LocalCandidateType, report.RemoteCandidateType).Inc()
+	}
+}
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+func TestReports(t *testing.T) {
+	st := NewStore(Config{MaxReports: 4})
+	now := time.Now()
+	for i, userID := range []string{"alice", "bob", "alice", "bob", "alice"} {
+		st.Add(&model.StatsReport{
+			RoomID:     "room",
+			UserID:     userID,
+			ReceivedAt: now.Add(time.Duration(i-5) * time.Minute),
+			RTTMs:      float64(i),
+		})
+	}
+
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  string
+		since   time.Time
+		limit   int
+		wantRTT []float64
+	}{
+		{"all kept", "room", "", time.Time{}, 0, []float64{1, 2, 3, 4}},
+		{"latest", "room", "", time.Time{}, 2, []float64{3, 4}},
+		{"participant", "room", "alice", time.Time{}, 0, []float64{2, 4}},
+		{"since", "room", "", now.Add(-150 * time.Second), 0, []float64{3, 4}},
+		{"other room", "other", "", time.Time{}, 0, []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports := st.Reports(tt.roomID, tt.userID, tt.since, tt.limit)
+			if len(reports) != len(tt.wantRTT) {
+				t.Fatalf("Reports() = %d reports, want %d", len(reports), len(tt.wantRTT))
+			}
+			for i, r := range reports {
+				if r.RTTMs != tt.wantRTT[i] {
+					t.Errorf("report %d rtt = %v, want %v", i, r.RTTMs, tt.wantRTT[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	st := NewStore(Config{Retention: time.Minute})
+	now := time.Now()
+	st.Add(&model.StatsReport{RoomID: "old", ReceivedAt: now.Add(-2 * time.Minute)})
+	st.Add(&model.StatsReport{RoomID: "room", ReceivedAt: now.Add(-2 * time.Minute)})
+	st.Add(&model.StatsReport{RoomID: "room", ReceivedAt: now})
+
+	st.prune(now)
+
+	if _, ok := st.rooms["old"]; ok {
+		t.Error("room without reports is kept")
+	}
+	if n := len(st.rooms["room"]); n != 1 {
+		t.Errorf("kept %d reports, want 1", n)
+	}
+}
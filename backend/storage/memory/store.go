@@ -1,7 +1,10 @@
 package memory
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"sort"
 	"sync"
 
 	"github.com/adwski/webrtc-playground/backend/model"
@@ -9,51 +12,228 @@ import (
 
 const (
 	defaultMaxParticipants = 2
+	defaultMaxViewers      = 100
+	defaultMaxBroadcast    = 1000
+	defaultMaxChatHistory  = 100
+
+	nonceSize = 8
 )
 
 var (
 	ErrRoomIsFull   = errors.New("room is full")
 	ErrRoomNotFound = errors.New("room is not found")
+	ErrRoomExists   = errors.New("room already exists")
+	ErrNotAMember   = errors.New("user is not a member of this room")
+	ErrBanned       = errors.New("user is banned in this room")
+	ErrRoomLocked   = errors.New("room is locked")
+	ErrNotInLobby   = errors.New("user is not waiting in lobby")
+	ErrHostRole     = errors.New("host role can only be transferred")
 )
 
 type MemStore struct {
-	mx *sync.Mutex
-	db map[string]*model.Room
+	mx              *sync.Mutex
+	db              map[string]*model.Room
+	chats           map[string]*chatLog
+	maxParticipants int
+	maxViewers      int
+	maxBroadcast    int
+	maxChatHistory  int
+}
+
+// chatLog is bounded room chat history, seq is id of last appended message.
+type chatLog struct {
+	seq      int64
+	messages []model.ChatMessage
+}
+
+type Config struct {
+	// MaxParticipants is max number of host and publishers in room.
+	MaxParticipants int
+
+	// MaxViewers is max number of viewers in room, they are counted separately.
+	MaxViewers int
+
+	// MaxBroadcastViewers is max number of viewers in broadcast room.
+	MaxBroadcastViewers int
+
+	// MaxChatHistory is number of messages kept per room, older messages are discarded.
+	MaxChatHistory int
 }
 
-func NewMemStore() *MemStore {
-	return &MemStore{
-		mx: &sync.Mutex{},
-		db: make(map[string]*model.Room),
+func NewMemStore(cfg Config) *MemStore {
+	ms := &MemStore{
+		mx:              &sync.Mutex{},
+		db:              make(map[string]*model.Room),
+		chats:           make(map[string]*chatLog),
+		maxParticipants: cfg.MaxParticipants,
+		maxViewers:      cfg.MaxViewers,
+		maxBroadcast:    cfg.MaxBroadcastViewers,
+		maxChatHistory:  cfg.MaxChatHistory,
+	}
+	if ms.maxParticipants == 0 {
+		ms.maxParticipants = defaultMaxParticipants
+	}
+	if ms.maxViewers == 0 {
+		ms.maxViewers = defaultMaxViewers
+	}
+	if ms.maxBroadcast == 0 {
+		ms.maxBroadcast = defaultMaxBroadcast
+	}
+	if ms.maxChatHistory == 0 {
+		ms.maxChatHistory = defaultMaxChatHistory
 	}
+	return ms
 }
 
-func (ms *MemStore) CreateOrJoinRoom(roomID string, userID string) (*model.Room, error) {
+// SetMaxParticipants changes room capacity, existing participants are kept.
+func (ms *MemStore) SetMaxParticipants(n int) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.maxParticipants = n
+}
+
+// SetMaxViewers changes room viewers capacity, existing viewers are kept.
+func (ms *MemStore) SetMaxViewers(n int) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.maxViewers = n
+}
+
+// SetMaxBroadcastViewers changes broadcast room viewers capacity, existing viewers are kept.
+func (ms *MemStore) SetMaxBroadcastViewers(n int) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.maxBroadcast = n
+}
+
+// SetMaxChatHistory changes chat history size, histories are trimmed on next message.
+func (ms *MemStore) SetMaxChatHistory(n int) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+	ms.maxChatHistory = n
+}
+
+// CreateOrJoinRoom adds user to room, created is true if room did not exist. If room requires
+// admission, user is put to room lobby instead, unless it is already a participant.
+func (ms *MemStore) CreateOrJoinRoom(roomID string, userID string, opts model.JoinOptions) (_ *model.Room, created bool, err error) {
 	ms.mx.Lock()
 	defer ms.mx.Unlock()
 
 	room, ok := ms.db[roomID]
 	if !ok {
+		nonce, err := newNonce()
+		if err != nil {
+			return nil, false, err
+		}
 		room = &model.Room{
-			ID: roomID,
+			ID:    roomID,
+			Nonce: nonce,
 			Participants: map[string]model.Participant{
-				userID: {ID: userID},
+				userID: {ID: userID, Role: model.RoleHost, Profile: opts.Profile},
 			},
+			Host:             userID,
+			Banned:           make(map[string]struct{}),
+			RequireAdmission: opts.Create.RequireAdmission,
+			Lobby:            make(map[string]model.Participant),
+			PasscodeHash:     opts.Create.PasscodeHash,
+			DefaultRole:      opts.Create.DefaultRole,
+			Type:             opts.Create.Type,
+			Parent:           opts.Create.Parent,
+		}
+		if room.DefaultRole == "" {
+			room.DefaultRole = model.RolePublisher
+		}
+		if room.Type == "" {
+			room.Type = model.RoomTypeConference
+		}
+		if room.Type == model.RoomTypeBroadcast {
+			// host is the only publisher of broadcast room
+			room.DefaultRole = model.RoleViewer
 		}
 		ms.db[roomID] = room
-		return room, nil
+		return room.Clone(), true, nil
 	}
 
-	if len(room.Participants) == defaultMaxParticipants {
-		if _, ok := room.Participants[userID]; !ok {
-			return nil, ErrRoomIsFull
+	if _, ok = room.Banned[userID]; ok {
+		return nil, false, ErrBanned
+	}
+	// existing participant keeps its role
+	p, ok := room.Participants[userID]
+	if !ok {
+		if room.Locked {
+			return nil, false, ErrRoomLocked
+		}
+		p.Role = room.DefaultRole
+		if opts.Viewer {
+			p.Role = model.RoleViewer
+		}
+		if err := ms.checkCapacity(room, p.Role); err != nil {
+			return nil, false, err
+		}
+		if opts.Check != nil {
+			if err := opts.Check(room); err != nil {
+				return nil, false, err
+			}
+		}
+		if room.RequireAdmission && !opts.Admitted && room.Host != "" {
+			room.Lobby[userID] = model.Participant{ID: userID, Role: p.Role, Profile: opts.Profile}
+			return room.Clone(), false, nil
 		}
 	}
 
+	if room.Host == "" {
+		// room was left by everyone
+		room.Host = userID
+	}
+	if room.Host == userID {
+		p.Role = model.RoleHost
+	}
 	room.Participants[userID] = model.Participant{
-		ID: userID,
+		ID:      userID,
+		Role:    p.Role,
+		Profile: opts.Profile,
+	}
+	return room.Clone(), false, nil
+}
+
+// CreateRoom adds room with its participants if room id is not taken. Capacity is not checked,
+// since room is formed from participants of another room.
+func (ms *MemStore) CreateRoom(room *model.Room) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	if _, ok := ms.db[room.ID]; ok {
+		return ErrRoomExists
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	ms.db[room.ID] = room.Clone()
+	ms.db[room.ID].Nonce = nonce
+	return nil
+}
+
+// checkCapacity returns error if there is no place for one more participant with role.
+// Error carries room full close reason, so transports can pass it to client.
+func (ms *MemStore) checkCapacity(room *model.Room, role string) error {
+	maxPublishers, maxViewers := ms.maxParticipants, ms.maxViewers
+	if room.Type == model.RoomTypeBroadcast {
+		maxPublishers, maxViewers = 1, ms.maxBroadcast
+	}
+	var publishers, viewers int
+	for _, p := range room.Participants {
+		if model.IsPublishing(p.Role) {
+			publishers++
+		} else {
+			viewers++
+		}
+	}
+	if model.IsPublishing(role) && publishers >= maxPublishers ||
+		!model.IsPublishing(role) && viewers >= maxViewers {
+		return model.NewCloseError(model.CloseRoomFull, ErrRoomIsFull)
 	}
-	return room, nil
+	return nil
 }
 
 func (ms *MemStore) GetRoom(roomID string) (*model.Room, error) {
@@ -64,5 +244,269 @@ func (ms *MemStore) GetRoom(roomID string) (*model.Room, error) {
 	if !ok {
 		return nil, ErrRoomNotFound
 	}
-	return room, nil
+	return room.Clone(), nil
+}
+
+// ListRooms returns copies of all rooms.
+func (ms *MemStore) ListRooms() []model.Room {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	rooms := make([]model.Room, 0, len(ms.db))
+	for _, room := range ms.db {
+		rooms = append(rooms, *room.Clone())
+	}
+	return rooms
+}
+
+func (ms *MemStore) RemoveParticipant(roomID string, userID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if _, ok = room.Participants[userID]; !ok {
+		return ErrNotAMember
+	}
+	removeParticipant(room, userID)
+	return nil
+}
+
+// UpdateParticipant changes participant with update func and returns updated participant.
+func (ms *MemStore) UpdateParticipant(roomID string, userID string, update func(*model.Participant)) (*model.Participant, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	p, ok := room.Participants[userID]
+	if !ok {
+		return nil, ErrNotAMember
+	}
+	update(&p)
+	room.Participants[userID] = p
+	return &p, nil
+}
+
+// BanParticipant removes participant from room and refuses its further joins.
+// User does not have to be a member to be banned.
+func (ms *MemStore) BanParticipant(roomID string, userID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	removeParticipant(room, userID)
+	room.Banned[userID] = struct{}{}
+	return nil
+}
+
+// SetLocked locks or unlocks room, locked room can be joined only by its members.
+func (ms *MemStore) SetLocked(roomID string, locked bool) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	room.Locked = locked
+	return nil
+}
+
+// SetHost transfers host role to room member.
+func (ms *MemStore) SetHost(roomID string, userID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	p, ok := room.Participants[userID]
+	if !ok {
+		return ErrNotAMember
+	}
+	// broadcast room has single publisher, so host and viewer swap roles
+	broadcast := room.Type == model.RoomTypeBroadcast
+	if !model.IsPublishing(p.Role) && !broadcast {
+		if err := ms.checkCapacity(room, model.RoleHost); err != nil {
+			return err
+		}
+	}
+	if prev, ok := room.Participants[room.Host]; ok && prev.ID != userID {
+		prev.Role = model.RolePublisher
+		if broadcast {
+			prev.Role = model.RoleViewer
+		}
+		room.Participants[prev.ID] = prev
+	}
+	p.Role = model.RoleHost
+	room.Participants[userID] = p
+	room.Host = userID
+	return nil
+}
+
+// SetRole changes role of participant, host role cannot be set or removed this way.
+func (ms *MemStore) SetRole(roomID string, userID string, role string) (*model.Participant, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	p, ok := room.Participants[userID]
+	if !ok {
+		return nil, ErrNotAMember
+	}
+	if p.Role == model.RoleHost || role == model.RoleHost {
+		return nil, ErrHostRole
+	}
+	if model.IsPublishing(role) && !model.IsPublishing(p.Role) {
+		if err := ms.checkCapacity(room, role); err != nil {
+			return nil, err
+		}
+	}
+	p.Role = role
+	room.Participants[userID] = p
+	return &p, nil
+}
+
+// Admit moves user from lobby to room participants.
+func (ms *MemStore) Admit(roomID string, userID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	p, ok := room.Lobby[userID]
+	if !ok {
+		return ErrNotInLobby
+	}
+	if err := ms.checkCapacity(room, p.Role); err != nil {
+		return err
+	}
+	delete(room.Lobby, userID)
+	room.Participants[userID] = p
+	return nil
+}
+
+// Deny removes user from lobby.
+func (ms *MemStore) Deny(roomID string, userID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	room, ok := ms.db[roomID]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if _, ok = room.Lobby[userID]; !ok {
+		return ErrNotInLobby
+	}
+	delete(room.Lobby, userID)
+	return nil
+}
+
+// removeParticipant deletes participant, if it was host, role is passed
+// to participant with the smallest id. Empty room has no host until someone joins.
+func removeParticipant(room *model.Room, userID string) {
+	delete(room.Lobby, userID)
+	delete(room.Participants, userID)
+	if room.Host != userID {
+		return
+	}
+	// publishers are preferred, viewer becomes host only if there are no publishers
+	room.Host = ""
+	for id, p := range room.Participants {
+		if room.Host == "" {
+			room.Host = id
+			continue
+		}
+		hostPublishing, publishing := model.IsPublishing(room.Participants[room.Host].Role), model.IsPublishing(p.Role)
+		if publishing && !hostPublishing || publishing == hostPublishing && id < room.Host {
+			room.Host = id
+		}
+	}
+	if p, ok := room.Participants[room.Host]; ok {
+		p.Role = model.RoleHost
+		room.Participants[room.Host] = p
+	}
+}
+
+func (ms *MemStore) DeleteRoom(roomID string) error {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	if _, ok := ms.db[roomID]; !ok {
+		return ErrRoomNotFound
+	}
+	delete(ms.db, roomID)
+	delete(ms.chats, roomID)
+	return nil
+}
+
+// AppendChatMessage assigns id to message and adds it to room chat history.
+func (ms *MemStore) AppendChatMessage(roomID string, msg model.ChatMessage) (*model.ChatMessage, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	if _, ok := ms.db[roomID]; !ok {
+		return nil, ErrRoomNotFound
+	}
+	chat, ok := ms.chats[roomID]
+	if !ok {
+		chat = &chatLog{}
+		ms.chats[roomID] = chat
+	}
+	chat.seq++
+	msg.ID = chat.seq
+	chat.messages = append(chat.messages, msg)
+	if extra := len(chat.messages) - ms.maxChatHistory; extra > 0 {
+		chat.messages = append(chat.messages[:0:0], chat.messages[extra:]...)
+	}
+	return &msg, nil
+}
+
+// ChatHistory returns up to limit latest room messages with ids less than before,
+// zero before means no upper bound. Messages are ordered by id.
+func (ms *MemStore) ChatHistory(roomID string, before int64, limit int) (*model.ChatHistory, error) {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	if _, ok := ms.db[roomID]; !ok {
+		return nil, ErrRoomNotFound
+	}
+	history := &model.ChatHistory{Messages: []model.ChatMessage{}}
+	chat, ok := ms.chats[roomID]
+	if !ok {
+		return history, nil
+	}
+	end := len(chat.messages)
+	if before > 0 {
+		end = sort.Search(len(chat.messages), func(i int) bool {
+			return chat.messages[i].ID >= before
+		})
+	}
+	start := max(end-limit, 0)
+	history.Messages = append(history.Messages, chat.messages[start:end]...)
+	history.HasMore = start > 0
+	return history, nil
+}
+
+// newNonce returns random room nonce.
+func newNonce() (string, error) {
+	b := make([]byte, nonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+)
+
+// newTestRoom returns store with room created by host and joined by users.
+func newTestRoom(t *testing.T, cfg Config, users ...string) *MemStore {
+	t.Helper()
+	ms := NewMemStore(cfg)
+	for _, userID := range append([]string{"host"}, users...) {
+		if _, _, err := ms.CreateOrJoinRoom("room", userID, model.JoinOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ms
+}
+
+func TestCreateOrJoinRoom(t *testing.T) {
+	ms := newTestRoom(t, Config{}, "bob")
+	if _, created, _ := ms.CreateOrJoinRoom("other", "bob", model.JoinOptions{}); !created {
+		t.Error("join of missing room did not create it")
+	}
+	if _, created, _ := ms.CreateOrJoinRoom("room", "bob", model.JoinOptions{}); created {
+		t.Error("join of existing room created it")
+	}
+
+	join := func(userID string, wantErr error) {
+		t.Helper()
+		if _, _, err := ms.CreateOrJoinRoom("room", userID, model.JoinOptions{}); !errors.Is(err, wantErr) {
+			t.Errorf("join of %s error = %v, want %v", userID, err, wantErr)
+		}
+	}
+	join("carol", ErrRoomIsFull)
+	join("bob", nil)
+	if _, _, err := ms.CreateOrJoinRoom("room", "carol", model.JoinOptions{}); model.CloseReasonOf(err) != model.CloseRoomFull {
+		t.Errorf("close reason = %+v, want %+v", model.CloseReasonOf(err), model.CloseRoomFull)
+	}
+
+	if err := ms.BanParticipant("room", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	join("bob", ErrBanned)
+
+	if err := ms.SetLocked("room", true); err != nil {
+		t.Fatal(err)
+	}
+	join("carol", ErrRoomLocked)
+	// members can rejoin locked room
+	join("host", nil)
+}
+
+func TestSetHost(t *testing.T) {
+	ms := newTestRoom(t, Config{}, "bob")
+	if err := ms.SetHost("room", "dave"); !errors.Is(err, ErrNotAMember) {
+		t.Errorf("SetHost() of not a member error = %v, want %v", err, ErrNotAMember)
+	}
+	if err := ms.SetHost("room", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if room, _ := ms.GetRoom("room"); room.Host != "bob" {
+		t.Errorf("host = %s, want bob", room.Host)
+	}
+}
+
+func TestRemoveHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		users    []string
+		wantHost string
+	}{
+		{"smallest id", []string{"dave", "bob"}, "bob"},
+		{"nobody left", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newTestRoom(t, Config{MaxParticipants: 3}, tt.users...)
+			if err := ms.RemoveParticipant("room", "host"); err != nil {
+				t.Fatal(err)
+			}
+			if room, _ := ms.GetRoom("room"); room.Host != tt.wantHost {
+				t.Errorf("host = %s, want %s", room.Host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestBanParticipant(t *testing.T) {
+	ms := newTestRoom(t, Config{}, "bob")
+	if err := ms.BanParticipant("other", "bob"); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("BanParticipant() in missing room error = %v, want %v", err, ErrRoomNotFound)
+	}
+	// users can be banned before they join
+	for _, userID := range []string{"bob", "carol"} {
+		if err := ms.BanParticipant("room", userID); err != nil {
+			t.Fatal(err)
+		}
+		if room, _ := ms.GetRoom("room"); room.Participants[userID].ID != "" {
+			t.Errorf("banned %s is still a participant", userID)
+		}
+		if _, _, err := ms.CreateOrJoinRoom("room", userID, model.JoinOptions{}); !errors.Is(err, ErrBanned) {
+			t.Errorf("join of banned %s error = %v, want %v", userID, err, ErrBanned)
+		}
+	}
+}
+func TestChatHistory(t *testing.T) {
+	ms := newTestRoom(t, Config{MaxChatHistory: 5})
+	for i := 0; i < 8; i++ {
+		if _, err := ms.AppendChatMessage("room", model.ChatMessage{UserID: "host", Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		before      int64
+		limit       int
+		wantIDs     []int64
+		wantHasMore bool
+	}{
+		{"latest", 0, 2, []int64{7, 8}, true},
+		{"all kept", 0, 10, []int64{4, 5, 6, 7, 8}, false},
+		{"before", 7, 2, []int64{5, 6}, true},
+		{"oldest page", 6, 10, []int64{4, 5}, false},
+		{"before discarded", 3, 10, []int64{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history, err := ms.ChatHistory("room", tt.before, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]int64, 0, len(history.Messages))
+			for _, msg := range history.Messages {
+				ids = append(ids, msg.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("ChatHistory() ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if history.HasMore != tt.wantHasMore {
+				t.Errorf("HasMore = %v, want %v", history.HasMore, tt.wantHasMore)
+			}
+		})
+	}
+	if _, err := ms.ChatHistory("other", 0, 10); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("ChatHistory() of missing room error = %v, want %v", err, ErrRoomNotFound)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	ms := newTestRoom(t, Config{}, "bob")
+	if _, _, err := ms.CreateOrJoinRoom("room", "carol", model.JoinOptions{Viewer: true}); err != nil {
+		t.Fatal(err)
+	}
+	role := func(userID string) string {
+		room, _ := ms.GetRoom("room")
+		return room.Participants[userID].Role
+	}
+
+	if _, err := ms.SetRole("room", "carol", model.RolePublisher); !errors.Is(err, ErrRoomIsFull) {
+		t.Errorf("SetRole() of viewer in full room error = %v, want %v", err, ErrRoomIsFull)
+	}
+	if _, err := ms.SetRole("room", "bob", model.RoleViewer); err != nil || role("bob") != model.RoleViewer {
+		t.Errorf("SetRole() to viewer error = %v, role = %s", err, role("bob"))
+	}
+	// bob released publisher slot
+	if _, err := ms.SetRole("room", "carol", model.RolePublisher); err != nil || role("carol") != model.RolePublisher {
+		t.Errorf("SetRole() to publisher error = %v, role = %s", err, role("carol"))
+	}
+
+	for _, tt := range []struct {
+		userID  string
+		role    string
+		wantErr error
+	}{
+		{"host", model.RoleViewer, ErrHostRole},
+		{"bob", model.RoleHost, ErrHostRole},
+		{"dave", model.RoleViewer, ErrNotAMember},
+	} {
+		if _, err := ms.SetRole("room", tt.userID, tt.role); !errors.Is(err, tt.wantErr) {
+			t.Errorf("SetRole(%s, %s) error = %v, want %v", tt.userID, tt.role, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateRoom(t *testing.T) {
+	ms := newTestRoom(t, Config{MaxParticipants: 1})
+	if err := ms.CreateRoom(&model.Room{ID: "room"}); !errors.Is(err, ErrRoomExists) {
+		t.Errorf("CreateRoom() of existing room error = %v, want %v", err, ErrRoomExists)
+	}
+
+	// moved participants are placed regardless of capacity
+	breakout := &model.Room{
+		ID:   "breakout",
+		Host: "alice",
+		Participants: map[string]model.Participant{
+			"alice": {ID: "alice", Role: model.RoleHost},
+			"bob":   {ID: "bob", Role: model.RolePublisher},
+		},
+	}
+	if err := ms.CreateRoom(breakout); err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if room, err := ms.GetRoom("breakout"); err != nil || len(room.Participants) != 2 {
+		t.Errorf("GetRoom() = %+v, %v, want room with both participants", room, err)
+	}
+}
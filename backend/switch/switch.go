@@ -2,46 +2,123 @@ package _switch
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/adwski/webrtc-playground/backend/tracing"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	defaultFwdTimout = time.Second
+
+	// announcement counts are published not more often than this
+	countsPublishInterval = 5 * time.Second
+)
+
+var (
+	ErrEndpointNotFound = errors.New("endpoint not found")
 )
 
+var tracer = otel.Tracer("github.com/adwski/webrtc-playground/backend/switch")
+
 type Switch struct {
-	logger zerolog.Logger
-	mx     *sync.RWMutex
-	fwd    map[string]map[string]model.Wire
+	logger     zerolog.Logger
+	mx         *sync.RWMutex
+	fwd        map[string]map[string]*conn
+	fwdTimeout time.Duration
+
+	// broadcasters of broadcast instances
+	broadcasters map[string]string
+
+	events   EventPublisher
+	countsMx *sync.Mutex
+	counts   map[string]*announceCounts
+
+	recorder Recorder
+}
+
+type EventPublisher interface {
+	Publish(model.Event)
+}
+
+// Recorder receives endpoint connections and every forwarded announcement.
+// It is called under switch lock to keep records ordered, so it must not block.
+type Recorder interface {
+	Connected(instance, endpoint string)
+	Disconnected(instance, endpoint string, last bool)
+	Announcement(instance string, ann model.Announcement)
+}
+
+type Config struct {
+	Logger         *zerolog.Logger
+	ForwardTimeout time.Duration
+
+	// Events receives forwarded announcement counts, optional.
+	Events EventPublisher
+
+	// Recorder records switch activity, optional.
+	Recorder Recorder
+}
+
+// conn is signaling session of connected endpoint.
+type conn struct {
+	wire    model.Wire
+	session string
 }
 
-func NewSwitch(logger *zerolog.Logger) *Switch {
-	return &Switch{
-		logger: logger.With().Str("component", "switch").Logger(),
-		mx:     &sync.RWMutex{},
-		fwd:    make(map[string]map[string]model.Wire),
+// announceCounts are numbers of forwarded announcements by type.
+type announceCounts struct {
+	byType    map[string]int
+	published time.Time
+}
+
+func NewSwitch(cfg Config) *Switch {
+	sw := &Switch{
+		logger:     cfg.Logger.With().Str("component", "switch").Logger(),
+		mx:         &sync.RWMutex{},
+		fwd:        make(map[string]map[string]*conn),
+		fwdTimeout: cfg.ForwardTimeout,
+		events:     cfg.Events,
+		countsMx:   &sync.Mutex{},
+		counts:     make(map[string]*announceCounts),
+		recorder:   cfg.Recorder,
+
+		broadcasters: make(map[string]string),
 	}
+	if sw.fwdTimeout == 0 {
+		sw.fwdTimeout = defaultFwdTimout
+	}
+	return sw
 }
 
 func (sw *Switch) Disconnect(instance, endpoint string) error {
 	sw.mx.Lock()
-	defer func() {
-		sw.mx.Unlock()
-		sw.logger.Debug().
-			Str("instance", instance).
-			Str("endpoint", endpoint).
-			Msg("endpoint disconnected")
-	}()
-
 	inst, ok := sw.fwd[instance]
 	if ok {
 		delete(inst, endpoint)
 		sw.fwd[instance] = inst
 	}
+	last := len(inst) == 0
+	if last {
+		delete(sw.broadcasters, instance)
+	}
+	if sw.recorder != nil {
+		sw.recorder.Disconnected(instance, endpoint, last)
+	}
+	sw.mx.Unlock()
+
+	sw.publishCounts(instance, true, last)
+	sw.logger.Debug().
+		Str("instance", instance).
+		Str("endpoint", endpoint).
+		Msg("endpoint disconnected")
 	return nil
 }
 
@@ -58,13 +135,87 @@ func (sw *Switch) Connect(ctx context.Context, instance string, endpoint string,
 
 	inst, ok := sw.fwd[instance]
 	if !ok {
-		inst = make(map[string]model.Wire)
+		inst = make(map[string]*conn)
 	}
-	inst[endpoint] = wire
+	inst[endpoint] = &conn{wire: wire, session: newSessionID()}
 	sw.fwd[instance] = inst
+	if sw.recorder != nil {
+		sw.recorder.Connected(instance, endpoint)
+	}
+	return nil
+}
+
+// SetBroadcaster makes instance broadcast one: endpoints other than broadcaster
+// can send announcements only to broadcaster, and their announcements are tagged
+// with signaling session id, so broadcaster can tell viewer sessions apart.
+func (sw *Switch) SetBroadcaster(instance, endpoint string) {
+	sw.mx.Lock()
+	defer sw.mx.Unlock()
+	sw.broadcasters[instance] = endpoint
+}
+
+// Terminate ends signaling session of connected endpoint with provided reason.
+func (sw *Switch) Terminate(instance, endpoint string, reason model.CloseReason) error {
+	sw.mx.RLock()
+	ep, ok := sw.fwd[instance][endpoint]
+	sw.mx.RUnlock()
+
+	if !ok {
+		return ErrEndpointNotFound
+	}
+	ep.wire.Terminate(reason)
+	sw.logger.Debug().
+		Str("instance", instance).
+		Str("endpoint", endpoint).
+		Int("code", reason.Code).
+		Str("reason", reason.Text).
+		Msg("endpoint terminated")
 	return nil
 }
 
+// TerminateInstance ends signaling sessions of every endpoint connected to instance.
+func (sw *Switch) TerminateInstance(instance string, reason model.CloseReason) {
+	sw.mx.RLock()
+	defer sw.mx.RUnlock()
+
+	for _, ep := range sw.fwd[instance] {
+		ep.wire.Terminate(reason)
+	}
+	sw.logger.Debug().
+		Str("instance", instance).
+		Int("code", reason.Code).
+		Str("reason", reason.Text).
+		Msg("instance endpoints terminated")
+}
+
+// Endpoints returns endpoints that are currently connected to instance.
+func (sw *Switch) Endpoints(instance string) []string {
+	sw.mx.RLock()
+	defer sw.mx.RUnlock()
+
+	endpoints := make([]string, 0, len(sw.fwd[instance]))
+	for endpoint := range sw.fwd[instance] {
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+// TerminateAll ends signaling sessions of every connected endpoint.
+func (sw *Switch) TerminateAll(reason model.CloseReason) {
+	sw.mx.RLock()
+	defer sw.mx.RUnlock()
+
+	for _, inst := range sw.fwd {
+		for _, ep := range inst {
+			ep.wire.Terminate(reason)
+		}
+	}
+	sw.logger.Debug().
+		Int("code", reason.Code).
+		Str("reason", reason.Text).
+		Msg("all endpoints terminated")
+}
+
 func (sw *Switch) forwardAnnouncements(ctx context.Context, instance string, rx <-chan model.Announcement) {
 fwdLoop:
 	for {
@@ -88,6 +239,14 @@ fwdLoop:
 	}
 }
 
+// Send forwards announcement to its destination endpoint.
+func (sw *Switch) Send(ctx context.Context, ann model.Announcement, instance string) error {
+	if !sw.forward(ctx, ann, instance) {
+		return ErrEndpointNotFound
+	}
+	return nil
+}
+
 func (sw *Switch) Broadcast(ctx context.Context, ann model.Announcement, instance string) error {
 	ann.DST = "" // clear dst just in case
 	if !sw.forward(ctx, ann, instance) {
@@ -100,9 +259,19 @@ func (sw *Switch) Broadcast(ctx context.Context, ann model.Announcement, instanc
 	return nil
 }
 
-func (sw *Switch) forward(ctx context.Context, ann model.Announcement, instance string) bool {
+func (sw *Switch) forward(ctx context.Context, ann model.Announcement, instance string) (sent bool) {
+	ctx, span := tracer.Start(ctx, "Switch.forward", trace.WithAttributes(
+		tracing.AttrRoomID.String(instance),
+		tracing.AttrAnnouncementType.String(ann.Type),
+		tracing.AttrAnnouncementSRC.String(ann.SRC),
+		tracing.AttrAnnouncementDST.String(ann.DST),
+	))
+	defer func() {
+		span.SetAttributes(tracing.AttrDelivered.Bool(sent))
+		span.End()
+	}()
+
 	var (
-		sent   bool
 		logger = sw.logger.With().
 			Str("instance", instance).
 			Str("type", ann.Type).
@@ -111,39 +280,144 @@ func (sw *Switch) forward(ctx context.Context, ann model.Announcement, instance
 
 	sw.mx.RLock()
 	inst := sw.fwd[instance]
+	broadcaster, isBroadcast := sw.broadcasters[instance]
+	if isBroadcast {
+		var allowed bool
+		if ann, allowed = routeBroadcast(ann, inst, broadcaster); !allowed {
+			sw.mx.RUnlock()
+			logger.Debug().Str("dst", ann.DST).Msg("announcement is not allowed in broadcast instance")
+			return false
+		}
+	}
+	if sw.recorder != nil {
+		// recorded under lock, so it is ordered with endpoint connections
+		sw.recorder.Announcement(instance, ann)
+	}
+	// destinations are collected under lock, since endpoints
+	// can connect while announcement is being sent
+	var (
+		targets []*conn
+		ep, ok  = inst[ann.DST]
+	)
+	if ann.DST == "" {
+		targets = make([]*conn, 0, len(inst))
+		for dst, c := range inst {
+			if dst != ann.SRC {
+				targets = append(targets, c)
+			}
+		}
+	}
 	sw.mx.RUnlock()
 
+	sw.count(instance, ann.Type)
+
 	if ann.DST == "" {
 		// broadcast announce
 
-		for dst, wire := range inst {
-			if dst != ann.SRC {
-				annSent, canceled := send(ctx, ann, wire.TX, &sw.logger)
-				if canceled {
-					break
-				}
-				if annSent {
-					sent = true
-				}
+		for _, c := range targets {
+			annSent, canceled := send(ctx, ann, c.wire.TX, sw.fwdTimeout, &sw.logger)
+			if canceled {
+				break
+			}
+			if annSent {
+				sent = true
 			}
 		}
 
 	} else {
 		// send to a particular endpoint
 
-		wire, ok := inst[ann.DST]
 		if !ok {
 			logger.Debug().Str("dst", ann.DST).Msg("cannot forward, dst not found")
 		} else {
-			sent, _ = send(ctx, ann, wire.TX, &logger)
+			sent, _ = send(ctx, ann, ep.wire.TX, sw.fwdTimeout, &logger)
 		}
 	}
 	return sent
 }
 
-func send(ctx context.Context, ann model.Announcement, tx chan<- model.Announcement, logger *zerolog.Logger) (bool, bool) {
+// routeBroadcast restricts announcement of broadcast instance. Viewer announcements
+// go only to broadcaster and carry viewer session id. Announcements of broadcaster
+// and server to particular session of viewer are dropped if viewer has reconnected since.
+func routeBroadcast(ann model.Announcement, inst map[string]*conn, broadcaster string) (model.Announcement, bool) {
+	if ann.SRC == "" || ann.SRC == broadcaster {
+		if ep, ok := inst[ann.DST]; ok && ann.Session != "" && ann.Session != ep.session {
+			return ann, false
+		}
+		return ann, true
+	}
+	if broadcaster == "" || ann.DST != "" && ann.DST != broadcaster {
+		return ann, false
+	}
+	ann.DST = broadcaster
+	ann.Session = ""
+	if ep, ok := inst[ann.SRC]; ok {
+		ann.Session = ep.session
+	}
+	return ann, true
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (sw *Switch) count(instance, typ string) {
+	if sw.events == nil {
+		return
+	}
+	sw.countsMx.Lock()
+	c, ok := sw.counts[instance]
+	if !ok {
+		c = &announceCounts{
+			byType:    make(map[string]int),
+			published: time.Now(),
+		}
+		sw.counts[instance] = c
+	}
+	c.byType[typ]++
+	sw.countsMx.Unlock()
+
+	sw.publishCounts(instance, false, false)
+}
+
+// publishCounts sends cumulative announcement counts of instance,
+// unless they were published recently.
+func (sw *Switch) publishCounts(instance string, force, reset bool) {
+	if sw.events == nil {
+		return
+	}
+	sw.countsMx.Lock()
+	c, ok := sw.counts[instance]
+	if !ok || (!force && time.Since(c.published) < countsPublishInterval) {
+		sw.countsMx.Unlock()
+		return
+	}
+	byType := make(map[string]int, len(c.byType))
+	for typ, n := range c.byType {
+		byType[typ] = n
+	}
+	c.published = time.Now()
+	if reset {
+		delete(sw.counts, instance)
+	}
+	sw.countsMx.Unlock()
+
+	sw.events.Publish(model.NewEvent(model.EventTypeAnnouncementsForwarded, instance, "", map[string]any{
+		"counts": byType,
+	}))
+}
+
+func send(
+	ctx context.Context,
+	ann model.Announcement,
+	tx chan<- model.Announcement,
+	timeout time.Duration,
+	logger *zerolog.Logger,
+) (bool, bool) {
 	var sent, canceled bool
-	tCh := time.NewTimer(defaultFwdTimout)
+	tCh := time.NewTimer(timeout)
 	select {
 	case <-ctx.Done():
 		canceled = true
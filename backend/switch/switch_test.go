@@ -0,0 +1,128 @@
+package _switch
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+func TestRouteBroadcast(t *testing.T) {
+	inst := map[string]*conn{
+		"host": {session: "s0"},
+		"v1":   {session: "s1"},
+		"v2":   {session: "s2"},
+	}
+	tests := []struct {
+		name        string
+		broadcaster string
+		ann         model.Announcement
+		want        model.Announcement
+		allowed     bool
+	}{
+		{
+			name:        "broadcaster to all",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "host", Type: "joined"},
+			want:        model.Announcement{SRC: "host", Type: "joined"},
+			allowed:     true,
+		},
+		{
+			name:        "broadcaster to viewer session",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "host", DST: "v1", Session: "s1", Type: "answer"},
+			want:        model.Announcement{SRC: "host", DST: "v1", Session: "s1", Type: "answer"},
+			allowed:     true,
+		},
+		{
+			name:        "broadcaster to stale viewer session",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "host", DST: "v1", Session: "old", Type: "answer"},
+			allowed:     false,
+		},
+		{
+			name:        "server to viewer",
+			broadcaster: "host",
+			ann:         model.Announcement{DST: "v2", Type: "roster"},
+			want:        model.Announcement{DST: "v2", Type: "roster"},
+			allowed:     true,
+		},
+		{
+			name:        "viewer broadcast goes to broadcaster",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "v1", Type: "left"},
+			want:        model.Announcement{SRC: "v1", DST: "host", Session: "s1", Type: "left"},
+			allowed:     true,
+		},
+		{
+			name:        "viewer session cannot be forged",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "v1", DST: "host", Session: "s2", Type: "offer"},
+			want:        model.Announcement{SRC: "v1", DST: "host", Session: "s1", Type: "offer"},
+			allowed:     true,
+		},
+		{
+			name:        "viewer to viewer",
+			broadcaster: "host",
+			ann:         model.Announcement{SRC: "v1", DST: "v2", Type: "offer"},
+			allowed:     false,
+		},
+		{
+			name:        "viewer without broadcaster",
+			broadcaster: "",
+			ann:         model.Announcement{SRC: "v1", Type: "left"},
+			allowed:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, allowed := routeBroadcast(tt.ann, inst, tt.broadcaster)
+			if allowed != tt.allowed {
+				t.Fatalf("routeBroadcast() allowed = %v, want %v", allowed, tt.allowed)
+			}
+			if allowed && got != tt.want {
+				t.Errorf("routeBroadcast() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBroadcastWhileConnecting is meant to be run with race detector.
+func TestBroadcastWhileConnecting(t *testing.T) {
+	logger := zerolog.Nop()
+	sw := NewSwitch(Config{Logger: &logger, ForwardTimeout: time.Second})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connected := make(chan struct{})
+	go func() {
+		defer close(connected)
+		for i := 0; i < 50; i++ {
+			wire := model.NewWire()
+			go func() {
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-wire.TX:
+					}
+				}
+			}()
+			if err := sw.Connect(ctx, "room", fmt.Sprintf("user-%d", i), wire); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-connected:
+			return
+		default:
+			_ = sw.Broadcast(ctx, model.Announcement{Type: "roster"}, "room")
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+const (
+	defaultServiceName = "webrtc-playground"
+
+	// traceparent is W3C trace context header, it is also accepted as query
+	// parameter from websocket clients that cannot set request headers.
+	traceparent = "traceparent"
+)
+
+// Span attributes.
+const (
+	AttrRoomID           = attribute.Key("room.id")
+	AttrUserID           = attribute.Key("user.id")
+	AttrAnnouncementType = attribute.Key("announcement.type")
+	AttrAnnouncementSRC  = attribute.Key("announcement.src")
+	AttrAnnouncementDST  = attribute.Key("announcement.dst")
+
+	// AttrDelivered reports if announcement reached at least one endpoint.
+	AttrDelivered = attribute.Key("announcement.delivered")
+)
+
+var (
+	ErrUnknownExporter = errors.New("unknown trace exporter")
+)
+
+type Config struct {
+	// Exporter is one of none, otlp or stdout. Tracing is disabled with none.
+	Exporter string
+
+	// Endpoint is OTLP/HTTP collector address (host:port), if empty
+	// OTEL_EXPORTER_OTLP_ENDPOINT or exporter default is used.
+	Endpoint string
+	Insecure bool
+
+	// SampleRatio is fraction of traces that are sampled, unless parent is sampled.
+	SampleRatio float64
+	ServiceName string
+}
+
+// Setup configures global tracer provider and trace context propagation.
+// Returned func flushes and stops exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, ErrUnknownExporter
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Extract returns request context with remote trace context from request headers
+// or from traceparent query parameter.
+func Extract(r *http.Request) context.Context {
+	carrier := propagation.HeaderCarrier(r.Header)
+	if carrier.Get(traceparent) == "" {
+		if tp := r.URL.Query().Get(traceparent); tp != "" {
+			carrier = propagation.HeaderCarrier(r.Header.Clone())
+			carrier.Set(traceparent, tp)
+		}
+	}
+	return otel.GetTextMapPropagator().Extract(r.Context(), carrier)
+}
+
+// Traceparent returns W3C traceparent of span in context, so client could
+// pass it to subsequent requests. It is empty if context has no span.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get(traceparent)
+}
+
+// RecordError marks span as failed with err.
+func RecordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		wantErr  error
+	}{
+		{"default", "", nil},
+		{"none", ExporterNone, nil},
+		{"unknown", "jaeger", ErrUnknownExporter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), Config{Exporter: tt.exporter})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Setup() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err = shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	if _, err := Setup(context.Background(), Config{}); err != nil {
+		t.Fatal(err)
+	}
+	const other = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   string
+	}{
+		{"header", testTraceparent, "", testTraceparent},
+		{"query param", "", testTraceparent, testTraceparent},
+		{"header is preferred", testTraceparent, other, testTraceparent},
+		{"none", "", "", ""},
+		{"invalid", "", "garbage", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(traceparent, tt.header)
+			}
+			if tt.query != "" {
+				r.URL.RawQuery = traceparent + "=" + tt.query
+			}
+			if got := Traceparent(Extract(r)); got != tt.want {
+				t.Errorf("Traceparent(Extract()) = %q, want %q", got, tt.want)
+			}
+			if tt.header == "" && r.Header.Get(traceparent) != "" {
+				t.Error("request headers are modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,560 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/metrics"
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultTimeout     = 10 * time.Second
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+	defaultWorkers     = 4
+
+	// number of finished deliveries kept for inspection
+	historySize = 1000
+	// wait of dispatcher loop when there is nothing to deliver
+	idleWait = time.Minute
+	// max response body size read for error message
+	maxErrorBodySize = 256
+
+	idSize = 16
+
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrNotFound  = errors.New("webhook delivery is not found")
+	ErrNotFailed = errors.New("webhook delivery is not failed")
+	ErrQueue     = errors.New("unable to persist webhook delivery")
+
+	errNoEndpoint = errors.New("endpoint is not configured anymore")
+)
+
+// DefaultEvents are delivered to endpoints that do not specify events.
+var DefaultEvents = []string{
+	model.EventTypeRoomCreated,
+	model.EventTypeParticipantJoined,
+	model.EventTypeParticipantLeft,
+	model.EventTypeRoomEmpty,
+	model.EventTypeRoomClosed,
+}
+
+// Endpoint receives room events as POST requests with JSON body.
+type Endpoint struct {
+	URL string
+
+	// Secret is HMAC-SHA256 key of request signature.
+	Secret string
+
+	// Events are delivered event types, DefaultEvents are used if empty.
+	Events []string
+}
+
+func (ep *Endpoint) accepts(typ string) bool {
+	if len(ep.Events) == 0 {
+		return slices.Contains(DefaultEvents, typ)
+	}
+	return slices.Contains(ep.Events, typ)
+}
+
+// Dispatcher delivers room events to webhook endpoints. Every event is queued as
+// separate delivery per endpoint and retried with exponential backoff until it is
+// accepted with 2xx status or max attempts are made. Pending deliveries are kept
+// in queue directory, so they survive restart.
+type Dispatcher struct {
+	logger zerolog.Logger
+	client *http.Client
+	dir    string
+
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	workers     int
+
+	mx        *sync.Mutex
+	endpoints []Endpoint
+	pending   map[string]*model.WebhookDelivery
+	inFlight  map[string]bool
+	history   []*model.WebhookDelivery
+	wake      chan struct{}
+}
+
+type Config struct {
+	Logger    *zerolog.Logger
+	Endpoints []Endpoint
+
+	// QueueDir is directory of pending deliveries, if empty queue is kept in memory.
+	QueueDir string
+
+	MaxAttempts int
+
+	// Timeout is timeout of single delivery attempt.
+	Timeout time.Duration
+
+	// MinBackoff is delay before first retry, it doubles with every next retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Workers is max number of concurrent delivery attempts.
+	Workers int
+}
+
+// NewDispatcher creates dispatcher and loads pending deliveries from queue directory.
+func NewDispatcher(cfg Config) (*Dispatcher, error) {
+	d := &Dispatcher{
+		logger:      cfg.Logger.With().Str("component", "webhook").Logger(),
+		client:      &http.Client{Timeout: cfg.Timeout},
+		dir:         cfg.QueueDir,
+		maxAttempts: cfg.MaxAttempts,
+		minBackoff:  cfg.MinBackoff,
+		maxBackoff:  cfg.MaxBackoff,
+		workers:     cfg.Workers,
+		mx:          &sync.Mutex{},
+		endpoints:   cfg.Endpoints,
+		pending:     make(map[string]*model.WebhookDelivery),
+		inFlight:    make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+	}
+	if d.client.Timeout == 0 {
+		d.client.Timeout = defaultTimeout
+	}
+	if d.maxAttempts == 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.minBackoff == 0 {
+		d.minBackoff = defaultMinBackoff
+	}
+	if d.maxBackoff == 0 {
+		d.maxBackoff = defaultMaxBackoff
+	}
+	if d.workers == 0 {
+		d.workers = defaultWorkers
+	}
+	if d.dir != "" {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// SetEndpoints replaces webhook endpoints. Pending deliveries to removed endpoints fail.
+func (d *Dispatcher) SetEndpoints(endpoints []Endpoint) {
+	d.mx.Lock()
+	d.endpoints = endpoints
+	d.mx.Unlock()
+	d.signal()
+}
+
+// Publish queues event for every endpoint that accepts its type.
+func (d *Dispatcher) Publish(ev model.Event) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	queued := false
+	for _, ep := range d.endpoints {
+		if !ep.accepts(ev.Type) {
+			continue
+		}
+		id, err := newID()
+		if err != nil {
+			d.logger.Error().Err(err).Msg("cannot generate delivery id")
+			continue
+		}
+		now := time.Now().UTC()
+		dl := &model.WebhookDelivery{
+			ID:            id,
+			URL:           ep.URL,
+			Event:         ev,
+			Status:        model.WebhookPending,
+			CreatedAt:     now,
+			NextAttemptAt: now,
+		}
+		if err = d.persist(dl); err != nil {
+			// delivery is still attempted, it is lost only if restart happens
+			d.logger.Error().Err(err).Str("id", id).Msg("cannot persist webhook delivery")
+		}
+		d.pending[id] = dl
+		queued = true
+	}
+	if queued {
+		d.signal()
+	}
+}
+
+// Run delivers queued events until context is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var (
+		sem   = make(chan struct{}, d.workers)
+		wg    = &sync.WaitGroup{}
+		timer = time.NewTimer(0)
+	)
+	defer func() {
+		timer.Stop()
+		wg.Wait()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+		due, wait := d.due(time.Now())
+		for _, id := range due {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(id string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				d.attempt(ctx, id)
+				d.signal()
+			}(id)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// Deliveries returns up to limit latest deliveries with status, or with any status if it is empty.
+func (d *Dispatcher) Deliveries(status string, limit int) []model.WebhookDelivery {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	deliveries := make([]model.WebhookDelivery, 0, len(d.pending)+len(d.history))
+	for _, dl := range d.pending {
+		deliveries = append(deliveries, *dl)
+	}
+	for _, dl := range d.history {
+		deliveries = append(deliveries, *dl)
+	}
+	deliveries = slices.DeleteFunc(deliveries, func(dl model.WebhookDelivery) bool {
+		return status != "" && dl.Status != status
+	})
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries
+}
+
+// Delivery returns delivery by id.
+func (d *Dispatcher) Delivery(id string) (*model.WebhookDelivery, error) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	if dl, ok := d.pending[id]; ok {
+		cp := *dl
+		return &cp, nil
+	}
+	for _, dl := range d.history {
+		if dl.ID == id {
+			cp := *dl
+			return &cp, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Retry queues failed delivery again with reset attempts.
+func (d *Dispatcher) Retry(id string) error {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	i := slices.IndexFunc(d.history, func(dl *model.WebhookDelivery) bool {
+		return dl.ID == id
+	})
+	if i < 0 {
+		if _, ok := d.pending[id]; ok {
+			return ErrNotFailed
+		}
+		return ErrNotFound
+	}
+	dl := d.history[i]
+	if dl.Status != model.WebhookFailed {
+		return ErrNotFailed
+	}
+	d.history = slices.Delete(d.history, i, i+1)
+	dl.Status = model.WebhookPending
+	dl.Attempts = 0
+	dl.NextAttemptAt = time.Now().UTC()
+	dl.FinishedAt = nil
+	if err := d.persist(dl); err != nil {
+		d.logger.Error().Err(err).Str("id", id).Msg("cannot persist webhook delivery")
+	}
+	d.pending[id] = dl
+	d.signal()
+	return nil
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due marks deliveries that should be attempted now as in flight and returns their ids
+// with wait time until next delivery is due.
+func (d *Dispatcher) due(now time.Time) ([]string, time.Duration) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	var (
+		ids  []string
+		wait = idleWait
+	)
+	for id, dl := range d.pending {
+		if d.inFlight[id] {
+			continue
+		}
+		if until := dl.NextAttemptAt.Sub(now); until > 0 {
+			wait = min(wait, until)
+			continue
+		}
+		d.inFlight[id] = true
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return d.pending[ids[i]].CreatedAt.Before(d.pending[ids[j]].CreatedAt)
+	})
+	return ids, wait
+}
+
+// attempt sends delivery once and updates its status.
+func (d *Dispatcher) attempt(ctx context.Context, id string) {
+	d.mx.Lock()
+	dl := *d.pending[id]
+	idx := slices.IndexFunc(d.endpoints, func(ep Endpoint) bool {
+		return ep.URL == dl.URL
+	})
+	var ep Endpoint
+	if idx >= 0 {
+		ep = d.endpoints[idx]
+	}
+	d.mx.Unlock()
+
+	var (
+		code int
+		err  = errNoEndpoint
+	)
+	if idx >= 0 {
+		code, err = d.send(ctx, &ep, &dl)
+	}
+	if ctx.Err() != nil {
+		// interrupted attempt is not counted, delivery stays in queue
+		return
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+
+	delete(d.inFlight, id)
+	cur, ok := d.pending[id]
+	if !ok {
+		return
+	}
+	cur.Attempts++
+	cur.LastStatusCode = code
+	cur.LastError = ""
+	now := time.Now().UTC()
+	logEvent := d.logger.Debug()
+	switch {
+	case err == nil:
+		cur.Status = model.WebhookDelivered
+	case cur.Attempts >= d.maxAttempts || errors.Is(err, errNoEndpoint):
+		cur.Status = model.WebhookFailed
+		cur.LastError = err.Error()
+		logEvent = d.logger.Warn().Err(err)
+	default:
+		cur.LastError = err.Error()
+		cur.NextAttemptAt = now.Add(d.backoff(cur.Attempts))
+		if errP := d.persist(cur); errP != nil {
+			d.logger.Error().Err(errP).Str("id", id).Msg("cannot persist webhook delivery")
+		}
+		d.logger.Debug().Err(err).
+			Str("id", id).
+			Str("url", cur.URL).
+			Int("attempts", cur.Attempts).
+			Time("next_attempt", cur.NextAttemptAt).
+			Msg("webhook delivery attempt failed")
+		return
+	}
+	cur.FinishedAt = &now
+	delete(d.pending, id)
+	d.history = append(d.history, cur)
+	if extra := len(d.history) - historySize; extra > 0 {
+		d.history = append(d.history[:0:0], d.history[extra:]...)
+	}
+	d.remove(id)
+	metrics.WebhookDeliveries.WithLabelValues(cur.Status).Inc()
+	logEvent.Str("id", id).
+		Str("url", cur.URL).
+		Str("event", cur.Event.Type).
+		Str("status", cur.Status).
+		Int("attempts", cur.Attempts).
+		Msg("webhook delivery finished")
+}
+
+// send posts event to endpoint. Body is signed together with timestamp,
+// signature is hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func (d *Dispatcher) send(ctx context.Context, ep *Endpoint, dl *model.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(&struct {
+		ID string `json:"id"`
+		model.Event
+	}{
+		ID:    dl.ID,
+		Event: dl.Event,
+	})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, dl.ID)
+	req.Header.Set(HeaderEvent, dl.Event.Type)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(ep.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+// Sign returns hex encoded request signature, receivers should compute it
+// the same way and compare with signature header value.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.minBackoff
+	for i := 1; i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, d.maxBackoff)
+}
+
+// load reads pending deliveries from queue directory.
+func (d *Dispatcher) load() error {
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return errors.Join(ErrQueue, err)
+	}
+	files, err := filepath.Glob(filepath.Join(d.dir, "*.json"))
+	if err != nil {
+		return errors.Join(ErrQueue, err)
+	}
+	for _, file := range files {
+		b, errR := os.ReadFile(file)
+		if errR != nil {
+			return errors.Join(ErrQueue, errR)
+		}
+		var dl model.WebhookDelivery
+		if errR = json.Unmarshal(b, &dl); errR != nil || dl.ID == "" {
+			d.logger.Warn().Err(errR).Str("file", file).Msg("skipping invalid webhook delivery file")
+			continue
+		}
+		d.pending[dl.ID] = &dl
+	}
+	if len(d.pending) > 0 {
+		d.logger.Info().Int("deliveries", len(d.pending)).Msg("pending webhook deliveries are loaded")
+	}
+	return nil
+}
+
+// persist writes delivery to queue directory, file is replaced atomically.
+func (d *Dispatcher) persist(dl *model.WebhookDelivery) error {
+	if d.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(dl)
+	if err != nil {
+		return errors.Join(ErrQueue, err)
+	}
+	tmp := d.path(dl.ID) + ".tmp"
+	if err = os.WriteFile(tmp, b, 0o600); err != nil {
+		return errors.Join(ErrQueue, err)
+	}
+	if err = os.Rename(tmp, d.path(dl.ID)); err != nil {
+		return errors.Join(ErrQueue, err)
+	}
+	return nil
+}
+
+func (d *Dispatcher) remove(id string) {
+	if d.dir == "" {
+		return
+	}
+	if err := os.Remove(d.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		d.logger.Error().Err(err).Str("id", id).Msg("cannot remove webhook delivery file")
+	}
+}
+
+func (d *Dispatcher) path(id string) string {
+	return filepath.Join(d.dir, id+".json")
+}
+
+func newID() (string, error) {
+	b := make([]byte, idSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
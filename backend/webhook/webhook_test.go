@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adwski/webrtc-playground/backend/model"
+	"github.com/rs/zerolog"
+)
+
+func newTestDispatcher(t *testing.T, cfg Config) *Dispatcher {
+	t.Helper()
+	logger := zerolog.Nop()
+	cfg.Logger = &logger
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = time.Millisecond
+	}
+	d, err := NewDispatcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// run starts dispatcher and stops it when test ends.
+func run(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitFinished waits until delivery is finished and returns it.
+func waitFinished(t *testing.T, d *Dispatcher) model.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, dl := range d.Deliveries("", 0) {
+			if dl.Status != model.WebhookPending {
+				return dl
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery is not finished")
+	return model.WebhookDelivery{}
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"reference", "secret", "1700000000", `{"id":"x"}`, "2f7852138f9dbd8d61c07c2cfb0b8ac96a46a32d78d4527788fb42fcb409a493"},
+		{"other secret", "other", "1700000000", `{"id":"x"}`, ""},
+		{"other timestamp", "secret", "1700000001", `{"id":"x"}`, ""},
+		{"other body", "secret", "1700000000", `{"id":"y"}`, ""},
+	}
+	ref := Sign(tests[0].secret, tests[0].timestamp, []byte(tests[0].body))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sign(tt.secret, tt.timestamp, []byte(tt.body))
+			if tt.want != "" && got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+			if tt.want == "" && got == ref {
+				t.Errorf("Sign() = %s, want signature different from reference", got)
+			}
+		})
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+	}{
+		{"accepted", []int{http.StatusNoContent}, 3, model.WebhookDelivered, 1, http.StatusNoContent},
+		{"retried", []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK}, 3, model.WebhookDelivered, 3, http.StatusOK},
+		{"attempts exhausted", []int{http.StatusInternalServerError}, 3, model.WebhookFailed, 3, http.StatusInternalServerError},
+		{"redirect is not accepted", []int{http.StatusNotModified}, 1, model.WebhookFailed, 1, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls  atomic.Int32
+				badSig atomic.Bool
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				sig := "sha256=" + Sign("secret", r.Header.Get(HeaderTimestamp), body)
+				if r.Header.Get(HeaderSignature) != sig || r.Header.Get(HeaderEvent) != model.EventTypeRoomCreated {
+					badSig.Store(true)
+				}
+				n := int(calls.Add(1))
+				w.WriteHeader(tt.statuses[min(n, len(tt.statuses))-1])
+			}))
+			defer srv.Close()
+
+			d := newTestDispatcher(t, Config{
+				Endpoints:   []Endpoint{{URL: srv.URL, Secret: "secret"}},
+				MaxAttempts: tt.maxAttempts,
+			})
+			run(t, d)
+			d.Publish(model.NewEvent(model.EventTypeRoomCreated, "room", "", nil))
+
+			dl := waitFinished(t, d)
+			if dl.Status != tt.wantStatus || dl.Attempts != tt.wantAttempts || dl.LastStatusCode != tt.wantCode {
+				t.Errorf("delivery = %s after %d attempts with code %d, want %s after %d attempts with code %d",
+					dl.Status, dl.Attempts, dl.LastStatusCode, tt.wantStatus, tt.wantAttempts, tt.wantCode)
+			}
+			if badSig.Load() {
+				t.Error("request has invalid signature headers")
+			}
+		})
+	}
+}
+
+func TestPublishFiltersEvents(t *testing.T) {
+	d := newTestDispatcher(t, Config{Endpoints: []Endpoint{
+		{URL: "http://default.invalid"},
+		{URL: "http://chat.invalid", Events: []string{model.EventTypeRoomClosed}},
+	}})
+
+	tests := []struct {
+		typ  string
+		want int
+	}{
+		{model.EventTypeRoomCreated, 1},
+		{model.EventTypeRoomClosed, 2},
+		{model.EventTypeSignalingConnected, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			before := len(d.Deliveries("", 0))
+			d.Publish(model.NewEvent(tt.typ, "room", "", nil))
+			if n := len(d.Deliveries(model.WebhookPending, 0)) - before; n != tt.want {
+				t.Errorf("Publish() queued %d deliveries, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := newTestDispatcher(t, Config{MinBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{20, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestQueuePersistence(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "queue")
+	endpoints := []Endpoint{{URL: srv.URL}}
+
+	// first dispatcher is not running, so delivery stays in queue
+	d := newTestDispatcher(t, Config{Endpoints: endpoints, QueueDir: dir})
+	d.Publish(model.NewEvent(model.EventTypeRoomCreated, "room", "", nil))
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("queue has %d files, want 1", len(files))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	d = newTestDispatcher(t, Config{Endpoints: endpoints, QueueDir: dir})
+	if n := len(d.Deliveries(model.WebhookPending, 0)); n != 1 {
+		t.Fatalf("loaded %d pending deliveries, want 1", n)
+	}
+	run(t, d)
+	if dl := waitFinished(t, d); dl.Status != model.WebhookDelivered {
+		t.Errorf("delivery status = %s, want %s", dl.Status, model.WebhookDelivered)
+	}
+	if _, err := os.Stat(files[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("delivered delivery file is kept: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("endpoint is called %d times, want 1", calls.Load())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	d := newTestDispatcher(t, Config{Endpoints: []Endpoint{{URL: srv.URL}}, MaxAttempts: 1})
+	d.Publish(model.NewEvent(model.EventTypeRoomCreated, "room", "", nil))
+	run(t, d)
+	failed := waitFinished(t, d)
+	fail.Store(false)
+
+	if err := d.Retry("unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Retry() of unknown error = %v, want %v", err, ErrNotFound)
+	}
+	if err := d.Retry(failed.ID); err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if dl := waitFinished(t, d); dl.Status != model.WebhookDelivered || dl.Attempts != 1 {
+		t.Errorf("retried delivery = %s after %d attempts, want delivered after 1", dl.Status, dl.Attempts)
+	}
+	if err := d.Retry(failed.ID); !errors.Is(err, ErrNotFailed) {
+		t.Errorf("Retry() of delivered error = %v, want %v", err, ErrNotFailed)
+	}
+}
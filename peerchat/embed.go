@@ -0,0 +1,7 @@
+// Package peerchat holds peerchat web client assets, so they could be served by backend.
+package peerchat
+
+import "embed"
+
+//go:embed index.html index.css index.js icons
+var Assets embed.FS
@@ -1,6 +1,7 @@
 const Config = {
     APIEndpoint: "/api/room",
-    SignalingEndpoint: "wss://"+ location.host +"/signal",
+    SignalingEndpoint: (location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/signal",
+    SSESignalingEndpoint: location.origin + "/signal",
     RTCConfig: {
         iceServers: [
             {
@@ -8,6 +9,8 @@ const Config = {
             }
         ]
     },
+    // call quality report interval, ms
+    StatsInterval: 10000,
     UserMediaConfig: {
         video: {
             width: {min:640, max:1920},
@@ -20,6 +23,11 @@ const Config = {
 function init() {
     const joinForm = document.getElementById("join-form")
 
+    const linkRoom = new URLSearchParams(location.search).get("room")
+    if (linkRoom) {
+        document.getElementById("room-code").value = linkRoom
+    }
+
     const cameraBtn = document.getElementById("camera-btn")
     const cameraOffBtn = document.getElementById("camera-off-btn")
 
@@ -34,6 +42,9 @@ function init() {
     const videoElementLocal = document.getElementById("user-1")
     const videoElementRemote = document.getElementById("user-2")
 
+    const chatForm = document.getElementById("chat-form")
+    const chatText = document.getElementById("chat-text")
+
     let signaling;
 
     joinForm.addEventListener("submit", async (e) => {
@@ -47,10 +58,14 @@ function init() {
 
             const [localStream, remoteStream] = await createStreams(videoElementLocal, videoElementRemote)
 
+            const mediaState = {muted: false, camera_off: false}
+
             const toggleCamera = async (e) => {
                 const track = localStream.getTracks().find(track => track.kind === "video")
                 if (track) {
                     track.enabled = !track.enabled
+                    mediaState.camera_off = !track.enabled
+                    signaling?.sendState(mediaState)
                 }
             }
 
@@ -58,6 +73,8 @@ function init() {
                 const track = localStream.getTracks().find(track => track.kind === "audio")
                 if (track) {
                     track.enabled = !track.enabled
+                    mediaState.muted = !track.enabled
+                    signaling?.sendState(mediaState)
                 }
             }
 
@@ -87,9 +104,19 @@ function init() {
         }
     })
 
+    chatForm.addEventListener("submit", (e) => {
+        e.preventDefault()
+
+        if (signaling && chatText.value.trim() !== "") {
+            signaling.sendChat(chatText.value)
+            chatText.value = ""
+        }
+    })
+
     leaveBtn.addEventListener("click", (e) => {
         lobby.style.display = 'block'
         room.style.display = 'none'
+        document.getElementById("chat-messages").replaceChildren()
 
         if (signaling) {
             signaling.stop()
@@ -97,6 +124,16 @@ function init() {
     })
 }
 
+function showChatMessage(msg) {
+    const item = document.createElement("li")
+    item.textContent = `${msg.user_id}: ${msg.text}`
+    item.title = new Date(msg.sent_at).toLocaleString()
+
+    const list = document.getElementById("chat-messages")
+    list.appendChild(item)
+    list.scrollTop = list.scrollHeight
+}
+
 function showRemoteVideo(yes) {
     if (yes) {
         document.getElementById("user-2").style.display = 'block';
@@ -107,7 +144,9 @@ function showRemoteVideo(yes) {
 
 async function prepareCall() {
     const myID = document.getElementById("user-name").value
-    if (myID === "") {
+    // access token identifies user if server requires authentication
+    const token = new URLSearchParams(location.search).get("token") || ""
+    if (myID === "" && token === "") {
         console.log("empty username")
         alert("empty username")
         return
@@ -119,18 +158,37 @@ async function prepareCall() {
         return
     }
 
-    const resp = await joinRoom(myID, roomID)
+    const passcode = document.getElementById("passcode").value
+    // invite link has room and invite query params
+    const invite = new URLSearchParams(location.search).get("invite") || ""
+    // viewers only receive media
+    const role = new URLSearchParams(location.search).get("role") || ""
+    // room type is applied if room is created, broadcast room has single broadcaster
+    const type = new URLSearchParams(location.search).get("type") || ""
+
+    const resp = await joinRoom(myID, roomID, passcode, invite, token, role, type)
     if (resp.message !== "OK") {
         alert("unable to join room: " + resp.error)
         console.log("unable to join the room", resp.error)
         return
     }
-    console.log("successfully joined the room")
-    return {userID: myID, roomID: roomID}
+    if (resp.data && resp.data.status === "waiting") {
+        console.log("waiting for host admission")
+    } else {
+        console.log("successfully joined the room")
+    }
+    if (resp.data && resp.data.ice_servers) {
+        Config.RTCConfig.iceServers = resp.data.ice_servers
+    }
+    // signaling session continues trace of join request
+    const traceparent = (resp.data && resp.data.traceparent) || ""
+    const userID = (resp.data && resp.data.user_id) || myID
+    const joinedRole = (resp.data && resp.data.role) || role
+    return {userID: userID, roomID: roomID, traceparent: traceparent, token: token, role: joinedRole}
 }
 
 async function startCall(params, localStream, remoteStream, videoElementLocal) {
-    const signaling = buildSignaling(params.roomID, params.userID, localStream, remoteStream, videoElementLocal)
+    const signaling = buildSignaling(params.roomID, params.userID, params.traceparent, params.token, params.role, localStream, remoteStream, videoElementLocal)
     await signaling.start()
     return signaling
 }
@@ -154,27 +212,82 @@ async function createStreams(videoElementLocal, videoElementRemote) {
     return [localStream, remoteStream]
 }
 
-async function joinRoom(myID, roomID) {
+async function joinRoom(myID, roomID, passcode, invite, token, role, type) {
     const joinParams = {
         "room_id": roomID,
         "user_id": myID,
+        "passcode": passcode,
+        "invite": invite,
+        "role": role,
+        "type": type,
+        "profile": {
+            "display_name": myID,
+            "client": navigator.userAgent,
+            "capabilities": {
+                "audio": true,
+                "video": true,
+                "screen": !!(navigator.mediaDevices && navigator.mediaDevices.getDisplayMedia),
+                "datachannel": true,
+            },
+        },
+    }
+    const headers = {
+        "Content-Type": "application/json",
+    }
+    if (token) {
+        headers["Authorization"] = "Bearer " + token
     }
     const response = await fetch(Config.APIEndpoint, {
         method: "POST",
         cache: "no-cache",
-        headers: {
-            "Content-Type": "application/json",
-        },
+        headers: headers,
         body: JSON.stringify(joinParams),
     })
     return response.json()
 }
 
-const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLocal) => {
-    const logPref = `[signaling][${roomID}]`;
-    const wsPath = Config.SignalingEndpoint + "/room/" + roomID + "/user/" + myID;
+const buildSignaling = (roomID, myID, traceparent, token, role, localStream, remoteStream, videoElementLocal) => {
+    let logPref = `[signaling][${roomID}]`;
+    // websocket and event source cannot set headers, token is passed in query
+    const wsQuery = new URLSearchParams()
+    const sseQuery = new URLSearchParams()
+    if (traceparent) {
+        wsQuery.set("traceparent", traceparent)
+    }
+    if (token) {
+        wsQuery.set("access_token", token)
+        sseQuery.set("access_token", token)
+    }
+    const query = (q) => q.toString() ? "?" + q.toString() : ""
+    let wsPath, ssePath;
+    const setRoom = (room) => {
+        roomID = room
+        logPref = `[signaling][${roomID}]`
+        wsPath = Config.SignalingEndpoint + "/room/" + roomID + "/user/" + myID + query(wsQuery);
+        ssePath = Config.SSESignalingEndpoint + "/room/" + roomID + "/user/" + myID + "/events" + query(sseQuery);
+    }
+    setRoom(roomID)
     let transport;
+    let connect;
     let peers = {};
+    // signaling sessions of viewers in broadcast room
+    let sessions = {};
+    let statsTimer;
+    let prevStats = {};
+
+    const reportStats = async () => {
+        for (const remoteUserID in peers) {
+            const summary = await summarizeStats(peers[remoteUserID], prevStats[remoteUserID])
+            prevStats[remoteUserID] = summary.totals
+            if (summary.report) {
+                summary.report.peer_id = remoteUserID
+                transport.send({
+                    type: "stats",
+                    payload: summary.report,
+                });
+            }
+        }
+    }
 
     const createPeerConnection = async (localStream, remoteStream, onicecandidate) => {
         const pc = new RTCPeerConnection(Config.RTCConfig)
@@ -187,9 +300,15 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
             })
         }
     
-        localStream.getTracks().forEach((track) => {
-            pc.addTrack(track, localStream)
-        })
+        if (role === "viewer") {
+            // server rejects offers of viewers that send media
+            pc.addTransceiver("audio", {direction: "recvonly"})
+            pc.addTransceiver("video", {direction: "recvonly"})
+        } else {
+            localStream.getTracks().forEach((track) => {
+                pc.addTrack(track, localStream)
+            })
+        }
 
         pc.onicecandidate = onicecandidate
 
@@ -205,6 +324,48 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
         return offer
     }
 
+    // applyRole starts or stops sending local media after role change
+    // and renegotiates with every peer
+    const applyRole = async () => {
+        for (const remoteUserID in peers) {
+            const pc = peers[remoteUserID]
+            for (const transceiver of pc.getTransceivers()) {
+                const kind = transceiver.receiver.track.kind
+                const track = role === "viewer" ? null : localStream.getTracks().find(track => track.kind === kind)
+                await transceiver.sender.replaceTrack(track || null)
+                if (track) {
+                    transceiver.sender.setStreams(localStream)
+                }
+                transceiver.direction = track ? "sendrecv" : "recvonly"
+            }
+            transport.send({
+                dst: remoteUserID,
+                session: sessions[remoteUserID],
+                type: "offer",
+                payload: await createOffer(pc),
+            });
+        }
+    }
+
+    // moveTo reconnects signaling to another room, i.e. to breakout room and back,
+    // peer connections of current room are closed
+    const moveTo = (room) => {
+        for (const remoteUserID in peers) {
+            peers[remoteUserID].close();
+            delete peers[remoteUserID];
+        }
+        sessions = {}
+        prevStats = {}
+        remoteStream.getTracks().forEach((track)=>{
+            track.stop()
+            remoteStream.removeTrack(track)
+        })
+        showRemoteVideo(false)
+        transport.disconnect()
+        setRoom(room)
+        connect()
+    }
+
     const createAnswer = async(peerConnection, offer) => {
         await peerConnection.setRemoteDescription(offer);
         const answer = await peerConnection.createAnswer();
@@ -214,12 +375,16 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
 
     return {
         async start(){
-            transport = buildWebSocketTransport(roomID);
-            window.addEventListener('beforeunload', transport.disconnect)
-            transport.addListener(async function(announcement){
+            const listener = async function(announcement){
                 console.log(`${logPref} got announcement:`, announcement)
 
                 const remoteUserID = announcement.src;
+                if (announcement.session && sessions[remoteUserID] !== announcement.session) {
+                    // viewer of broadcast room has reconnected, its peer connection is stale
+                    peers[remoteUserID]?.close()
+                    delete peers[remoteUserID]
+                    sessions[remoteUserID] = announcement.session
+                }
                 let pc = peers[remoteUserID]
 
                 switch (announcement.type) {
@@ -238,6 +403,7 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                                     if (event.candidate) {
                                         transport.send({
                                             dst: remoteUserID,
+                                            session: sessions[remoteUserID],
                                             type: "candidate",
                                             payload: event.candidate,
                                         });
@@ -248,6 +414,7 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                             const offer = await createOffer(pc)
                             transport.send({
                                 dst: remoteUserID,
+                                session: sessions[remoteUserID],
                                 type: "offer",
                                 payload: offer,
                             });
@@ -266,6 +433,7 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                             showRemoteVideo(false)
                             pc.close();
                             delete peers[remoteUserID]
+                            delete sessions[remoteUserID]
                         }
                         break;
 
@@ -278,6 +446,7 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                                 if (event.candidate) {
                                     transport.send({
                                         dst: remoteUserID,
+                                        session: sessions[remoteUserID],
                                         type: "candidate",
                                         payload: event.candidate,
                                     });
@@ -290,6 +459,7 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                             const answer = await createAnswer(pc, announcement.payload)
                             transport.send({
                                 dst: remoteUserID,
+                                session: sessions[remoteUserID],
                                 type: "answer",
                                 payload: answer,
                             });
@@ -312,14 +482,105 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
                             console.log(`${logPref} got ice candidate for unknown peer: ${remoteUserID}`)
                         }
 
+                        break;
+                    case "knock":
+                        // user is waiting in lobby, we are host
+                        transport.send({
+                            type: confirm(`${announcement.payload.user_id} wants to join`) ? "admit" : "deny",
+                            payload: {user_id: announcement.payload.user_id},
+                        });
+                        break;
+
+                    case "admitted":
+                        console.log(`${logPref} admitted to the room`)
+                        break;
+
+                    case "roster":
+                        console.log(`${logPref} participants:`, announcement.payload.participants)
+                        break;
+
+                    case "profile":
+                        console.log(`${logPref} profile of ${remoteUserID} is updated:`, announcement.payload)
+                        break;
+
+                    case "state":
+                        console.log(`${logPref} media state of ${remoteUserID} is updated:`, announcement.payload)
+                        break;
+
+                    case "chat":
+                        showChatMessage(announcement.payload)
+                        break;
+
+                    case "chat_history":
+                        // sent once after connect, older messages can be fetched with REST api
+                        announcement.payload.messages.forEach(showChatMessage)
+                        break;
+
+                    case "role":
+                        console.log(`${logPref} role of ${announcement.payload.user_id} is ${announcement.payload.role}`)
+                        if (announcement.payload.user_id === myID && announcement.payload.role !== role) {
+                            role = announcement.payload.role
+                            await applyRole()
+                        }
+                        break;
+
+                    case "move":
+                        // host started or ended breakout rooms
+                        console.log(`${logPref} moving to room ${announcement.payload.room_id}`)
+                        moveTo(announcement.payload.room_id)
+                        break;
+
+                    case "room_state":
+                        console.log(`${logPref} room host: ${announcement.payload.host}, locked: ${announcement.payload.locked}`)
+                        if (announcement.payload.host === myID && role === "viewer") {
+                            // host always publishes
+                            role = "host"
+                            await applyRole()
+                        }
+                        break;
+
+                    case "error":
+                        console.log(`${logPref} request rejected: ${announcement.payload.error}`)
+                        break;
+
+                    case "notice":
+                        // server notice from administrator
+                        console.log(`${logPref} server notice: ${announcement.payload.text}`)
                         break;
                     default:
                         console.log(`${logPref} unknown announcement type: ${announcement.type}`)
                 }
-            })
-            transport.connect(wsPath)
+            }
+            connect = () => {
+                transport = buildWebSocketTransport(roomID, () => {
+                    // websocket is not available, fall back to server-sent events
+                    console.log(`${logPref} falling back to sse transport`)
+                    transport = buildSSETransport(roomID);
+                    transport.addListener(listener)
+                    transport.connect(ssePath)
+                });
+                transport.addListener(listener)
+                transport.connect(wsPath)
+            }
+            statsTimer = setInterval(reportStats, Config.StatsInterval)
+            window.addEventListener('beforeunload', () => transport.disconnect())
+            connect()
+        },
+        sendChat(text) {
+            transport.send({
+                type: "chat",
+                payload: {text: text},
+            });
+        },
+        sendState(state) {
+            // server records state and forwards it to other participants
+            transport.send({
+                type: "state",
+                payload: state,
+            });
         },
         async stop() {
+            clearInterval(statsTimer)
             showRemoteVideo(false)
             remoteStream.getTracks().forEach((track)=>{
                 console.log("removing track", track)
@@ -340,10 +601,57 @@ const buildSignaling = (roomID, myID, localStream, remoteStream, videoElementLoc
     }
 }
 
-const buildWebSocketTransport = (name) => {
+// summarizeStats makes call quality report from peer connection stats,
+// bitrates are calculated from byte counters of previous summary.
+const summarizeStats = async (pc, prev) => {
+    const stats = await pc.getStats()
+    const totals = {time: performance.now(), bytesReceived: 0, bytesSent: 0}
+    let pair, jitter = 0, lost = 0, received = 0
+
+    stats.forEach((s) => {
+        switch (s.type) {
+            case "candidate-pair":
+                if (s.nominated && s.state === "succeeded") {
+                    pair = s
+                }
+                break;
+            case "inbound-rtp":
+                jitter = Math.max(jitter, s.jitter || 0)
+                lost += s.packetsLost || 0
+                received += s.packetsReceived || 0
+                totals.bytesReceived += s.bytesReceived || 0
+                break;
+            case "outbound-rtp":
+                totals.bytesSent += s.bytesSent || 0
+                break;
+        }
+    })
+    if (!pair) {
+        return {totals: totals}
+    }
+
+    const report = {
+        rtt_ms: (pair.currentRoundTripTime || 0) * 1000,
+        jitter_ms: jitter * 1000,
+        packet_loss: lost + received > 0 ? Math.max(lost, 0) / (lost + received) : 0,
+        inbound_bitrate: 0,
+        outbound_bitrate: 0,
+        local_candidate_type: stats.get(pair.localCandidateId)?.candidateType || "",
+        remote_candidate_type: stats.get(pair.remoteCandidateId)?.candidateType || "",
+    }
+    if (prev) {
+        const seconds = (totals.time - prev.time) / 1000
+        report.inbound_bitrate = Math.max(totals.bytesReceived - prev.bytesReceived, 0) * 8 / seconds
+        report.outbound_bitrate = Math.max(totals.bytesSent - prev.bytesSent, 0) * 8 / seconds
+    }
+    return {totals: totals, report: report}
+}
+
+const buildWebSocketTransport = (name, onUnavailable) => {
     let socket = null;
     let callback = null;
     let address = null;
+    let opened = false;
 
     const logPref = `[websocket][${name}]`;
 
@@ -356,14 +664,27 @@ const buildWebSocketTransport = (name) => {
             socket = new WebSocket(addr);
 
             socket.addEventListener("open", (event) => {
+                opened = true
                 console.log(`${logPref} connected to ${addr}`)
             });
 
+            socket.addEventListener("error", (event) => {
+                if (!opened && onUnavailable) {
+                    socket = null
+                    onUnavailable()
+                }
+            });
+
             socket.addEventListener("message", async (event) => {
                 if (callback) {
                     callback.call(this, JSON.parse(event.data))
                 }
             });
+
+            socket.addEventListener("close", (event) => {
+                console.log(`${logPref} session closed: ${event.code} ${event.reason}`)
+                showCloseReason(event.code, event.reason)
+            });
         },
         send: (message) => {
             if (socket) {
@@ -380,4 +701,73 @@ const buildWebSocketTransport = (name) => {
     }
 }
 
+// CloseMessages explain application close codes that user can act on,
+// other codes are shown with server reason.
+const CloseMessages = {
+    4001: "room is full, try again later",
+}
+
+const showCloseReason = (code, reason) => {
+    if (code < 4000 || code > 4999) {
+        // not ended by application
+        return
+    }
+    alert("session closed: " + (CloseMessages[code] || reason))
+}
+
+const buildSSETransport = (name) => {
+    let source = null;
+    let callback = null;
+    let address = null;
+
+    const logPref = `[sse][${name}]`;
+
+    return {
+        addListener: (cb) => {
+            callback = cb;
+        },
+        connect: (addr) => {
+            address = addr;
+            // EventSource reconnects with Last-Event-ID automatically
+            source = new EventSource(addr);
+
+            source.addEventListener("open", (event) => {
+                console.log(`${logPref} connected to ${addr}`)
+            });
+
+            source.addEventListener("message", async (event) => {
+                if (callback) {
+                    callback.call(this, JSON.parse(event.data))
+                }
+            });
+
+            source.addEventListener("close", (event) => {
+                console.log(`${logPref} session closed by server:`, event.data)
+                source.close()
+                source = null
+                const reason = JSON.parse(event.data)
+                showCloseReason(reason.code, reason.reason)
+            });
+        },
+        send: (message) => {
+            if (source) {
+                fetch(address, {
+                    method: "POST",
+                    headers: {
+                        "Content-Type": "application/json",
+                    },
+                    body: JSON.stringify(message),
+                })
+            }
+        },
+        disconnect: () => {
+            if (source) {
+                source.close()
+                source = null
+                console.log(`${logPref} connection with ${address} is closed!`)
+            }
+        }
+    }
+}
+
 document.addEventListener("DOMContentLoaded", init)